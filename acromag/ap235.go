@@ -9,9 +9,13 @@ import "C"
 import (
 	"errors"
 	"fmt"
+	"log"
 	"reflect"
 	"sync"
+	"time"
 	"unsafe"
+
+	"github.com/nasa-jpl/golaborate/generichttp/daq"
 )
 
 // AP235 is an acromag 16-bit DAC of the same type
@@ -43,8 +47,42 @@ type AP235 struct {
 	// isWaveform is a fast check for whether each channel is used
 	// for waveform playback
 	isWaveform [16]bool
+
+	// disabled marks channels which are not in use and must not be written
+	disabled [16]bool
+
+	// lastVoltage is the last voltage commanded on each channel, valid if
+	// the corresponding element of commanded is true
+	lastVoltage [16]float64
+	commanded   [16]bool
+
+	// waveformDivider is the TimerDivider in effect when each channel's
+	// waveform was populated
+	waveformDivider [16]C.uint32_t
+
+	// syncSlave marks a board whose waveforms are clocked by another board's
+	// trigger output, see StartWaveformSynced
+	syncSlave bool
+
+	// uncalibrated marks channels whose DN outputs bypass calibration, see
+	// SetCalibrated
+	uncalibrated [16]bool
+
+	// stopService is closed to tell serviceInterrupts to exit, and
+	// serviceDone is closed by serviceInterrupts when it has
+	stopService chan struct{}
+	serviceDone chan struct{}
 }
 
+// MinInterruptTimeout is the shortest time serviceInterrupts waits for an
+// interrupt before deciding the driver has hung.  The actual limit is ten
+// times the time to play half of the FIFO, if that is longer
+var MinInterruptTimeout = time.Second
+
+// serviceExitTimeout is how long StopWaveform waits for serviceInterrupts to
+// exit
+const serviceExitTimeout = time.Second
+
 // NewAP235 creates a new instance and opens the connection to the DAC
 func NewAP235(deviceIndex int) (*AP235, error) {
 	var (
@@ -97,6 +135,11 @@ func NewAP235(deviceIndex int) (*AP235, error) {
 func (dac *AP235) SetRange(channel int, rngS string) error {
 	dac.Lock()
 	defer dac.Unlock()
+	return dac.setRange(channel, rngS)
+}
+
+// setRange is SetRange without locking.  The caller must hold the lock.
+func (dac *AP235) setRange(channel int, rngS string) error {
 	rng, err := ValidateOutputRange(rngS)
 	if err != nil {
 		return err
@@ -107,10 +150,50 @@ func (dac *AP235) SetRange(channel int, rngS string) error {
 	return nil
 }
 
+// SetRangePreservingOutput changes the output range of a channel and
+// re-commands the last voltage written to it in the new range, so that the
+// physical output does not jump.  An error is generated if no voltage has been
+// commanded, the channel is used for waveform playback, or the voltage is not
+// representable in the new range, in which case the range is not changed.
+func (dac *AP235) SetRangePreservingOutput(channel int, rngS string) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	if _, err := ValidateOutputRange(rngS); err != nil {
+		return err
+	}
+	if dac.isWaveform[channel] {
+		return ErrIncompatibleWaveform
+	}
+	if !dac.commanded[channel] {
+		return fmt.Errorf("channel %d: no output has been commanded, nothing to preserve", channel)
+	}
+	v := dac.lastVoltage[channel]
+	min, max, err := RangeToMinMax(rngS)
+	if err != nil {
+		return err
+	}
+	if v < min || v > max {
+		return fmt.Errorf("channel %d: output %f V is not representable in range %s", channel, v, rngS)
+	}
+	err = dac.setRange(channel, rngS)
+	if err != nil {
+		return err
+	}
+	vB := []float64{v}
+	vU := []uint16{0}
+	dac.calibrateData(channel, vB, vU)
+	return dac.outputDN16(channel, vU[0])
+}
+
 // GetRange returns the output range of the DAC in volts.
-// The error value is always nil; the API looks
-// this way for symmetry with Set
+// The error is only non-nil if the channel is out of range
 func (dac *AP235) GetRange(channel int) (string, error) {
+	if err := checkChannel(channel); err != nil {
+		return "", err
+	}
 	Crng := dac.cfg.opts._chan[C.int(channel)].Range
 	return FormatOutputRange(OutputRange(Crng)), nil
 }
@@ -193,8 +276,11 @@ func (dac *AP235) SetOverRange(channel int, allowed bool) error {
 }
 
 // GetOverRange returns true if the DAC output is allowed to exceed nominal by 5%
-// the error is always nil
+// the error is only non-nil if the channel is out of range
 func (dac *AP235) GetOverRange(channel int) (bool, error) {
+	if err := checkChannel(channel); err != nil {
+		return false, err
+	}
 	Cint := dac.cfg.opts._chan[C.int(channel)].OverRange
 	return Cint == 1, nil
 }
@@ -202,6 +288,9 @@ func (dac *AP235) GetOverRange(channel int) (bool, error) {
 // SetTriggerMode configures the DAC for a given triggering mode
 // the error is only non-nil if the trigger mode is invalid
 func (dac *AP235) SetTriggerMode(channel int, triggerMode string) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
 	dac.Lock()
 	defer dac.Unlock()
 	tm, err := ValidateTriggerMode(triggerMode)
@@ -236,10 +325,36 @@ func (dac *AP235) SetTriggerDirection(b bool) error {
 		i = 1
 	}
 	dac.cfg.TriggerDirection = C.uint32_t(i)
-	// dac.sendCfgToBoard() TODO: need to send to board?
+	C.set_trigger_direction(dac.cfg)
+	return nil
+}
+
+// SetSyncSlave makes the board a slave (true) whose waveforms are clocked by
+// the trigger output of a master board, or a standalone board (false) clocked
+// by its own timer.  The trigger direction becomes input for a slave.
+// Waveforms populated afterwards use the external trigger instead of the
+// timer, so this must be called before PopulateWaveform.  See
+// StartWaveformSynced.
+func (dac *AP235) SetSyncSlave(b bool) error {
+	dac.Lock()
+	defer dac.Unlock()
+	if dac.playingBack {
+		return errors.New("AP235 cannot change sync role during playback")
+	}
+	dac.syncSlave = b
+	if b {
+		dac.cfg.TriggerDirection = 0
+		C.set_trigger_direction(dac.cfg)
+	}
 	return nil
 }
 
+// GetSyncSlave returns true if the board is a sync slave
+// the error is always nil
+func (dac *AP235) GetSyncSlave() (bool, error) {
+	return dac.syncSlave, nil
+}
+
 // GetTriggerDirection returns true if the DAC's trigger is output, false if it is input
 // the error is always nil
 func (dac *AP235) GetTriggerDirection() (bool, error) {
@@ -261,6 +376,9 @@ func (dac *AP235) GetTriggerDirection() (bool, error) {
 // err should be checked on the later of the two calls to
 // SetOperatingMode and SetTriggerMode
 func (dac *AP235) SetOperatingMode(channel int, mode string) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
 	dac.Lock()
 	defer dac.Unlock()
 	o, err := ValidateOperatingMode(mode)
@@ -275,6 +393,7 @@ func (dac *AP235) SetOperatingMode(channel int, mode string) error {
 		if (trigger != "external") && (trigger != "timer") {
 			return ErrIncompatibleOperatingTrigger
 		}
+		return nil
 	}
 	dac.isWaveform[channel] = false
 	return nil
@@ -336,25 +455,49 @@ func (dac *AP235) GetOutputSimultaneous(channel int) (bool, error) {
 // SetTimerPeriod sets the timer period,
 // the time between repetitions of the timer clock
 //
-// there are two threshholds: 9920 ns, below which
+// the board has a single timer which clocks every waveform channel, so
+// all channels play back at the same sample rate.  StartWaveform returns
+// ErrSharedTimer if the period was changed after some, but not all, of the
+// waveforms were populated.
+//
+// there are two threshholds: MinTimerPeriod (9920 ns), below which
 // the DAC cannot settle to better than 1LSB
-// before the next command and 19840 ns, below which
-// the DAC cannot be fed data for all sixteen channels
-// in parallel.
+// before the next command, and MinTimerPeriodAllChannels (19840 ns), below
+// which the DAC cannot be fed data for all sixteen channels
+// in parallel.  ErrTimerTooFast or ErrTimerTooFastAllChannels are returned
+// respectively, but the period is still set.
 func (dac *AP235) SetTimerPeriod(nanoseconds uint32) error {
+	tdiv := nanoseconds / 32
+	if tdiv == 0 {
+		return fmt.Errorf("%w: timer period %d ns is shorter than one 32 ns tick", daq.ErrInvalidSetting, nanoseconds)
+	}
 	dac.Lock()
 	defer dac.Unlock()
-	tdiv := nanoseconds / 32
 	dac.cfg.TimerDivider = C.uint32_t(tdiv)
-	if tdiv < 310 { // minimum recommended value from Acromag, based on DAC settling time
+	if tdiv < MinTimerPeriod/32 { // minimum recommended value from Acromag, based on DAC settling time
 		return ErrTimerTooFast
 	}
-	if tdiv < 620 {
-		return errors.New("timer too fast for transfer to DAC to keep up if all channels used; still accepted")
+	if tdiv < MinTimerPeriodAllChannels/32 {
+		return ErrTimerTooFastAllChannels
 	}
 	return nil
 }
 
+// CheckTimerPeriod checks a timer period for waveform playback without
+// setting it.  Periods shorter than MinTimerPeriod are rejected, since the DAC
+// cannot settle between samples.  Periods shorter than
+// MinTimerPeriodAllChannels give the warning ErrTimerTooFastAllChannels
+func (dac *AP235) CheckTimerPeriod(nanoseconds uint32) (warning, err error) {
+	if nanoseconds < MinTimerPeriod {
+		return nil, fmt.Errorf("%w: period %d ns is shorter than the %d ns the DAC needs to settle between samples",
+			daq.ErrInvalidSetting, nanoseconds, MinTimerPeriod)
+	}
+	if nanoseconds < MinTimerPeriodAllChannels {
+		return ErrTimerTooFastAllChannels, nil
+	}
+	return nil, nil
+}
+
 // GetTimerPeriod retrieves the timer period in nanoseconds
 //
 // the error is always nil
@@ -362,6 +505,114 @@ func (dac *AP235) GetTimerPeriod() (uint32, error) {
 	return uint32(dac.cfg.TimerDivider) * 32, nil
 }
 
+// SetChannelEnabled marks a channel as in use (enabled == true) or not.
+// Output, OutputMulti, and PopulateWaveform reject disabled channels.
+// All channels are enabled when the DAC is opened.
+func (dac *AP235) SetChannelEnabled(channel int, enabled bool) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	dac.disabled[channel] = !enabled
+	return nil
+}
+
+// GetChannelEnabled returns true if the channel is in use
+func (dac *AP235) GetChannelEnabled(channel int) (bool, error) {
+	if err := checkChannel(channel); err != nil {
+		return false, err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	return !dac.disabled[channel], nil
+}
+
+// ExportConfig returns the configuration of every channel, the trigger
+// direction, and the timer period
+func (dac *AP235) ExportConfig() (daq.DACConfig, error) {
+	cfg := daq.DACConfig{Model: "AP235", Channels: make([]daq.ChannelConfig, 16)}
+	// the getters read the config block and never return an error
+	for ch := range cfg.Channels {
+		c := &cfg.Channels[ch]
+		c.Range, _ = dac.GetRange(ch)
+		pwr, _ := dac.GetPowerUpVoltage(ch)
+		clr, _ := dac.GetClearVoltage(ch)
+		c.PowerUpScale, c.ClearScale = int(pwr), int(clr)
+		c.OverTempShutdown, _ = dac.GetOverTempBehavior(ch)
+		c.OverRange, _ = dac.GetOverRange(ch)
+		c.Simultaneous, _ = dac.GetOutputSimultaneous(ch)
+		c.Enabled, _ = dac.GetChannelEnabled(ch)
+		c.OperatingMode, _ = dac.GetOperatingMode(ch)
+		c.TriggerMode, _ = dac.GetTriggerMode(ch)
+		c.ClearOnUnderflow, _ = dac.GetClearOnUnderflow(ch)
+	}
+	cfg.TriggerDirection, _ = dac.GetTriggerDirection()
+	cfg.TimerPeriod, _ = dac.GetTimerPeriod()
+	return cfg, nil
+}
+
+// ImportConfig applies a configuration from ExportConfig to the DAC.
+// It is not possible to import a configuration during waveform playback.
+// The timer period is applied last, and a warning about its speed is returned
+// after the rest of the configuration is applied.
+func (dac *AP235) ImportConfig(cfg daq.DACConfig) error {
+	if err := checkConfig(cfg, "AP235"); err != nil {
+		return err
+	}
+	dac.Lock()
+	playing := dac.playingBack
+	dac.Unlock()
+	if playing {
+		return errors.New("cannot import a configuration during waveform playback")
+	}
+	for ch, c := range cfg.Channels {
+		err := dac.importChannel(ch, c)
+		if err != nil {
+			return fmt.Errorf("channel %d: %w", ch, err)
+		}
+	}
+	err := dac.SetTriggerDirection(cfg.TriggerDirection)
+	if err != nil {
+		return err
+	}
+	if cfg.TimerPeriod != 0 {
+		return dac.SetTimerPeriod(cfg.TimerPeriod)
+	}
+	return nil
+}
+
+func (dac *AP235) importChannel(ch int, c daq.ChannelConfig) error {
+	err := dac.SetRange(ch, c.Range)
+	if err != nil {
+		return err
+	}
+	err = dac.SetPowerUpVoltage(ch, OutputScale(c.PowerUpScale))
+	if err != nil {
+		return err
+	}
+	err = dac.SetClearVoltage(ch, OutputScale(c.ClearScale))
+	if err != nil {
+		return err
+	}
+	// the remaining setters only fail on invalid input
+	dac.SetOverTempBehavior(ch, c.OverTempShutdown)
+	dac.SetOverRange(ch, c.OverRange)
+	dac.SetOutputSimultaneous(ch, c.Simultaneous)
+	dac.SetClearOnUnderflow(ch, c.ClearOnUnderflow)
+	dac.SetChannelEnabled(ch, c.Enabled)
+	if c.OperatingMode == "" || c.TriggerMode == "" {
+		return nil
+	}
+	// the operating mode and trigger may be transiently incompatible,
+	// only the later of the two calls is checked
+	err = dac.SetOperatingMode(ch, c.OperatingMode)
+	if err != nil && !errors.Is(err, ErrIncompatibleOperatingTrigger) {
+		return err
+	}
+	return dac.SetTriggerMode(ch, c.TriggerMode)
+}
+
 // sendCfgToBoard updates the configuration on the board
 func (dac *AP235) sendCfgToBoard(channel int) {
 	C.cnfg235(dac.cfg, C.int(channel))
@@ -371,6 +622,9 @@ func (dac *AP235) sendCfgToBoard(channel int) {
 // Output writes a voltage to a channel.
 // the error is only non-nil if the value is out of range
 func (dac *AP235) Output(channel int, voltage float64) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
 	// TODO: look into cd235 C function
 	// this is a hack to improve code reuse, no need to allocate slices here
 	vB := []float64{voltage}
@@ -379,6 +633,27 @@ func (dac *AP235) Output(channel int, voltage float64) error {
 	return dac.OutputDN16(channel, vU[0])
 }
 
+// GetOutput returns the voltage last commanded on a channel by Output,
+// OutputDN16, or OutputMulti.  It is the commanded value, not a measurement;
+// the board has no readback.  An error wrapping daq.ErrNotCommanded is
+// returned if nothing has been commanded since the DAC was opened or the
+// channel was cleared or reset, and ErrIncompatibleWaveform if the channel is
+// used for waveform playback
+func (dac *AP235) GetOutput(channel int) (float64, error) {
+	if err := checkChannel(channel); err != nil {
+		return 0, err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	if dac.isWaveform[channel] {
+		return 0, fmt.Errorf("channel %d: %w", channel, ErrIncompatibleWaveform)
+	}
+	if !dac.commanded[channel] {
+		return 0, fmt.Errorf("channel %d: %w", channel, daq.ErrNotCommanded)
+	}
+	return dac.lastVoltage[channel], nil
+}
+
 // OutputDN16 writes a value to the board in DN.
 //
 // if the channel is set up for waveform mode, an error is generated.
@@ -386,16 +661,20 @@ func (dac *AP235) Output(channel int, voltage float64) error {
 func (dac *AP235) OutputDN16(channel int, value uint16) error {
 	dac.Lock()
 	defer dac.Unlock()
+	return dac.outputDN16(channel, value)
+}
+
+// outputDN16 is OutputDN16 without locking.  The caller must hold the lock.
+func (dac *AP235) outputDN16(channel int, value uint16) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
+	if dac.disabled[channel] {
+		return fmt.Errorf("channel %d: %w", channel, ErrChannelDisabled)
+	}
 	if dac.isWaveform[channel] {
-		return ErrIncompatibleWaveform
+		return fmt.Errorf("channel %d: %w", channel, ErrIncompatibleWaveform)
 	}
-	// going to round trip, since we want to use the DAC in calibrated mode
-	// convert value to a f64
-	rng, _ := dac.GetRange(channel)
-	min, max := RangeToMinMax(rng)
-	step := (max - min) / 65535
-	fV := []float64{min + step*float64(value)}
-
 	// set FIFO configuration for this channel to 1 sample
 	cCh := C.int(channel)
 	dac.cfg.SampleCount[cCh] = 1
@@ -404,16 +683,69 @@ func (dac *AP235) OutputDN16(channel int, value uint16) error {
 	dac.cfg.current_ptr[cCh] = ptr
 	dac.cfg.head_ptr[cCh] = ptr
 	dac.cfg.tail_ptr[cCh] = ptr2
-	C.cd235(dac.cfg, C.int(channel), (*C.double)(&fV[0]))
+	var v float64
+	if dac.uncalibrated[channel] {
+		// the FIFO holds straight binary DN, so the value goes in as is.
+		// The voltage is only recorded for SetRangePreservingOutput
+		rng := dac.cfg.opts._chan[cCh].Range & 0x7
+		min, max := idealCode[rng][endpointLo], idealCode[rng][endpointHi]
+		v = min + (max-min)/65535*float64(value)
+		*ptr = C.short(value)
+	} else {
+		// going to round trip, since we want to use the DAC in calibrated mode
+		// convert value to a f64
+		rng, _ := dac.GetRange(channel)
+		min, max, err := RangeToMinMax(rng)
+		if err != nil {
+			return err
+		}
+		step := (max - min) / 65535
+		fV := []float64{min + step*float64(value)}
+		C.cd235(dac.cfg, C.int(channel), (*C.double)(&fV[0]))
+		v = fV[0]
+	}
 	C.fifowro235(dac.cfg, cCh)
+	dac.lastVoltage[channel] = v
+	dac.commanded[channel] = true
+	return nil
+}
+
+// SetCalibrated selects whether OutputDN16 applies the board's calibration
+// on a channel.  Channels are calibrated when the DAC is opened.
+//
+// When calibrated, the DN is converted to volts over the ideal range and
+// back to DN through the gain and offset correction read from the board,
+// which costs a float round trip on every write.  When not, the DN is
+// written to the FIFO as is, which is the fastest path to the output but
+// leaves the factory-measured gain and offset errors of the channel
+// uncorrected.  Waveforms are not affected
+func (dac *AP235) SetCalibrated(channel int, calibrated bool) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	dac.uncalibrated[channel] = !calibrated
 	return nil
 }
 
+// GetCalibrated returns true if OutputDN16 applies the board's calibration
+// on a channel
+func (dac *AP235) GetCalibrated(channel int) (bool, error) {
+	if err := checkChannel(channel); err != nil {
+		return false, err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	return !dac.uncalibrated[channel], nil
+}
+
 // OutputMulti writes voltages to multiple output channels.
 // the error is non-nil if any of these conditions occur:
 //  1. A blend of output modes (some simultaneous, some immediate)
 //  2. A command is out of range
 //  3. A channel is set up for waveform playback
+//  4. A channel is disabled
 //
 // if an error is encountered in case 2, the output buffer of the DAC may be
 // partially updated from proceeding valid commands.  No invalid values escape
@@ -430,6 +762,9 @@ func (dac *AP235) OutputMulti(channels []int, voltages []float64) error {
 	// 1.  software
 	// 2.  timer
 	// 3.  exterinal input
+	if err := checkChannels(channels); err != nil {
+		return err
+	}
 	// ensure channels are homogeneous
 	sim, _ := dac.GetOutputSimultaneous(channels[0])
 	for i := 0; i < len(channels); i++ { // old for is faster than range, this code may be hot
@@ -444,7 +779,10 @@ func (dac *AP235) OutputMulti(channels []int, voltages []float64) error {
 				channels[i], channels[0])
 		}
 		if dac.isWaveform[channels[i]] {
-			return ErrIncompatibleWaveform
+			return fmt.Errorf("channel %d: %w", channels[i], ErrIncompatibleWaveform)
+		}
+		if dac.disabled[channels[i]] {
+			return fmt.Errorf("channel %d: %w", channels[i], ErrChannelDisabled)
 		}
 	}
 
@@ -470,6 +808,9 @@ func (dac *AP235) OutputMultiDN16(channels []int, uint16s []uint16) error {
 	// 1.  software
 	// 2.  timer
 	// 3.  exterinal input
+	if err := checkChannels(channels); err != nil {
+		return err
+	}
 	// ensure channels are homogeneous
 	sim, _ := dac.GetOutputSimultaneous(channels[0])
 	for i := 0; i < len(channels); i++ { // old for is faster than range, this code may be hot
@@ -484,7 +825,10 @@ func (dac *AP235) OutputMultiDN16(channels []int, uint16s []uint16) error {
 				channels[i], channels[0])
 		}
 		if dac.isWaveform[channels[i]] {
-			return ErrIncompatibleWaveform
+			return fmt.Errorf("channel %d: %w", channels[i], ErrIncompatibleWaveform)
+		}
+		if dac.disabled[channels[i]] {
+			return fmt.Errorf("channel %d: %w", channels[i], ErrChannelDisabled)
 		}
 	}
 
@@ -506,32 +850,151 @@ func (dac *AP235) Flush() {
 }
 
 // StartWaveform starts waveform playback on all waveform channels
-// the error is only non-nil if playback is already occuring
+// the error is only non-nil if playback is already occuring or the waveforms
+// were not all populated at the current timer period
 func (dac *AP235) StartWaveform() error {
 	dac.Lock()
 	defer dac.Unlock()
 	if dac.playingBack {
-		return errors.New("AP235 is already playing back a waveform")
+		return fmt.Errorf("AP235: %w", daq.ErrAlreadyPlaying)
 	}
-	go dac.serviceInterrupts()
+	if err := dac.checkSharedTimer(); err != nil {
+		return err
+	}
+	dac.stopService = make(chan struct{})
+	dac.serviceDone = make(chan struct{})
+	go dac.serviceInterrupts(dac.stopService, dac.serviceDone, dac.interruptTimeout())
 	dac.playingBack = true
 	C.start_waveform(dac.cfg)
 	return nil
 }
 
+// interruptTimeout returns the longest serviceInterrupts should block waiting
+// for an interrupt.  The caller must hold the lock.
+func (dac *AP235) interruptTimeout() time.Duration {
+	// an interrupt comes each time half of the FIFO has been played
+	half := time.Duration(dac.cfg.TimerDivider) * 32 * MAXSAMPLES / 2
+	if 10*half > MinInterruptTimeout {
+		return 10 * half
+	}
+	return MinInterruptTimeout
+}
+
+// StartWaveformSynced starts waveform playback on several boards together.
+// boards[0] is the master, clocked by its own timer, and the rest are slaves
+// which were made so with SetSyncSlave before their waveforms were
+// populated.  The master's trigger direction is set to output, the slaves
+// are armed, then the master is started; every tick of the master's timer
+// then clocks one sample out of every board.  If any board fails to start,
+// those already started are stopped.
+//
+// Wiring: connect the external trigger line of the master to the external
+// trigger line of each slave, and tie the grounds of the boards together.
+// Only the master's timer period matters; slaves should still be given the
+// same period so that their waveforms can also be played back alone.
+func StartWaveformSynced(boards []*AP235) error {
+	if len(boards) == 0 {
+		return errors.New("no boards to start")
+	}
+	master, slaves := boards[0], boards[1:]
+	if slave, _ := master.GetSyncSlave(); slave {
+		return errors.New("the master board (boards[0]) is configured as a sync slave")
+	}
+	for i, b := range slaves {
+		if slave, _ := b.GetSyncSlave(); !slave {
+			return fmt.Errorf("board %d is not configured as a sync slave", i+1)
+		}
+	}
+	err := master.SetTriggerDirection(true)
+	if err != nil {
+		return err
+	}
+	for i, b := range slaves {
+		err = b.StartWaveform()
+		if err != nil {
+			for j := 0; j < i; j++ {
+				slaves[j].StopWaveform()
+			}
+			return fmt.Errorf("board %d: %w", i+1, err)
+		}
+	}
+	err = master.StartWaveform()
+	if err != nil {
+		for _, b := range slaves {
+			b.StopWaveform()
+		}
+		return fmt.Errorf("board 0: %w", err)
+	}
+	return nil
+}
+
+// checkSharedTimer returns ErrSharedTimer if any channel in waveform mode was
+// populated at a timer period other than the current one.  The caller must
+// hold the lock.
+func (dac *AP235) checkSharedTimer() error {
+	if dac.syncSlave {
+		return nil // clocked by the master's timer
+	}
+	for i := 0; i < 16; i++ {
+		if dac.sampleCount[i] == 0 || OperatingMode(dac.cfg.opts._chan[C.int(i)].OpMode) != OperatingWaveform {
+			continue
+		}
+		if dac.waveformDivider[i] != dac.cfg.TimerDivider {
+			return fmt.Errorf("channel %d was populated at %d ns, timer is %d ns: %w",
+				i, uint32(dac.waveformDivider[i])*32, uint32(dac.cfg.TimerDivider)*32, ErrSharedTimer)
+		}
+	}
+	return nil
+}
+
 // StopWaveform stops playback on all channels.
 // the error is non-nil only if playback is not occuring
+//
+// the goroutine servicing interrupts is signaled to exit, and StopWaveform
+// waits briefly for it to do so.
 func (dac *AP235) StopWaveform() error {
 	dac.Lock()
-	defer dac.Unlock()
 	if !dac.playingBack {
-		return errors.New("AP235 is not playing back a waveform")
+		dac.Unlock()
+		return fmt.Errorf("AP235: %w", daq.ErrNotPlaying)
 	}
 	dac.playingBack = false
+	close(dac.stopService)
+	done := dac.serviceDone
+	// also unblocks fetch_status
 	C.stop_waveform(dac.cfg)
+	dac.Unlock()
+	// the lock is released while waiting, since serviceInterrupts takes it
+	// to transfer data
+	select {
+	case <-done:
+	case <-time.After(serviceExitTimeout):
+		log.Printf("acromag: AP235 interrupt service did not exit within %v of stopping playback", serviceExitTimeout)
+	}
 	return nil
 }
 
+// PlaybackStatus reports whether a waveform is playing back and, for each
+// channel with a waveform loaded, how many of its samples have been
+// transferred to the board.  Samples are transferred ahead of the output, so
+// this leads the playback by up to a FIFO's worth of samples
+// the error is always nil
+func (dac *AP235) PlaybackStatus() (daq.PlaybackStatus, error) {
+	dac.Lock()
+	defer dac.Unlock()
+	status := daq.PlaybackStatus{PlayingBack: dac.playingBack, Channels: []daq.ChannelProgress{}}
+	for i := 0; i < 16; i++ {
+		if !dac.isWaveform[i] || dac.sampleCount[i] == 0 {
+			continue
+		}
+		status.Channels = append(status.Channels, daq.ChannelProgress{
+			Channel:     i,
+			Samples:     dac.sampleCount[i],
+			Transferred: dac.cursor[i]})
+	}
+	return status, nil
+}
+
 // need software reset?  drvr235.c, L475
 
 // calibrateData converts a f64 value to uint16.  This is basically cd235
@@ -560,8 +1023,84 @@ func (dac *AP235) calibrateData(channel int, volts []float64, buffer []uint16) {
 	}
 }
 
+// uncalibrateData converts DN from the waveform buffer back to volts,
+// the inverse of calibrateData up to the clipping and rounding it does
+func (dac *AP235) uncalibrateData(channel int, buffer []uint16) []float64 {
+	cCh := C.int(channel)
+	rngS, _ := dac.GetRange(channel)    // err always nil
+	rng, _ := ValidateOutputRange(rngS) // err always nil
+	gainCoef := 1 + float64(dac.cfg.ogc235[cCh][rng][gain])/(65535*16)
+	slopeCoef := float64(dac.cfg.pIdealCode[rng][idealSlope])
+	off := float64(dac.cfg.pIdealCode[rng][idealZeroBTC]) + float64(dac.cfg.ogc235[channel][rng][offset])/16
+	gain := gainCoef * slopeCoef
+	volts := make([]float64, len(buffer))
+	for i := 0; i < len(buffer); i++ {
+		volts[i] = (float64(buffer[i]) - 0x8000 - off) / gain
+	}
+	return volts
+}
+
+// VoltsToDN converts a voltage to the DN the board would be sent for it on
+// a channel, using the channel's range and the calibration read from the
+// board.  This is the same conversion used for waveforms, so a waveform
+// built from it matches one populated with volts.  Voltages outside the
+// range are clipped
+func (dac *AP235) VoltsToDN(channel int, v float64) (uint16, error) {
+	if err := checkChannel(channel); err != nil {
+		return 0, err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	buf := []uint16{0}
+	dac.calibrateData(channel, []float64{v}, buf)
+	return buf[0], nil
+}
+
+// DNToVolts is the inverse of VoltsToDN
+func (dac *AP235) DNToVolts(channel int, dn uint16) (float64, error) {
+	if err := checkChannel(channel); err != nil {
+		return 0, err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	return dac.uncalibrateData(channel, []uint16{dn})[0], nil
+}
+
+// PreviewWaveform returns the waveform loaded on a channel in volts,
+// decimated to points samples.  If points is zero or at least the length of
+// the waveform, all of it is returned.
+func (dac *AP235) PreviewWaveform(channel, points int) ([]float64, error) {
+	if err := checkChannel(channel); err != nil {
+		return nil, err
+	}
+	if points < 0 {
+		return nil, fmt.Errorf("points must be non-negative, got %d", points)
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	n := dac.sampleCount[channel]
+	if n == 0 || dac.buffer[channel] == nil {
+		return nil, fmt.Errorf("no waveform loaded on channel %d", channel)
+	}
+	buf := dac.buffer[channel][:n]
+	if points != 0 && points < n {
+		dec := make([]uint16, points)
+		for i := range dec {
+			dec[i] = buf[i*n/points]
+		}
+		buf = dec
+	}
+	return dac.uncalibrateData(channel, buf), nil
+}
+
 // PopulateWaveform populates the waveform table for a given channel
 // the error is only non-nil if the DAC is currently playing back a waveform
+// or the channel is disabled
+//
+// the waveform is played back at the timer period set by SetTimerPeriod,
+// which is shared by all channels.  Set the period before populating; every
+// waveform channel must be populated at the same period or StartWaveform
+// returns ErrSharedTimer.
 func (dac *AP235) PopulateWaveform(channel int, data []float64) error {
 	// need to:
 	// 1) convert f64 => uint16
@@ -577,12 +1116,19 @@ func (dac *AP235) PopulateWaveform(channel int, data []float64) error {
 	if dac.playingBack {
 		return errors.New("AP235 cannot change waveform table during playback")
 	}
+	if enabled, _ := dac.GetChannelEnabled(channel); !enabled {
+		return fmt.Errorf("channel %d: %w", channel, ErrChannelDisabled)
+	}
 
 	err := dac.SetOperatingMode(channel, "waveform")
 	if err != nil {
 		return err // err is beneign, but force users to reconfigure DAC first
 	}
-	err = dac.SetTriggerMode(channel, "timer")
+	trigger := "timer"
+	if dac.syncSlave {
+		trigger = "external"
+	}
+	err = dac.SetTriggerMode(channel, trigger)
 	if err != nil {
 		return err // err is beneign, but force users to reconfigure DAC first
 	}
@@ -614,6 +1160,7 @@ func (dac *AP235) PopulateWaveform(channel int, data []float64) error {
 	dac.calibrateData(channel, data, buf) // "moves" data->buf
 	dac.sampleCount[channel] = l
 	dac.cursor[channel] = 0
+	dac.waveformDivider[channel] = dac.cfg.TimerDivider
 	dac.buffer[channel] = buf
 	dac.cfg.head_ptr[channel] = (*C.short)(unsafe.Pointer(&dac.buffer[channel][0]))
 	C.set_DAC_sample_addresses(dac.cfg, C.int(channel))
@@ -623,7 +1170,13 @@ func (dac *AP235) PopulateWaveform(channel int, data []float64) error {
 
 // serviceInterrupts should be run as a background goroutine; it handles
 // interrupts from the DAC to keep it fed
-func (dac *AP235) serviceInterrupts() {
+//
+// it exits when stop is closed, when the board reports no pending channels,
+// or when no interrupt arrives within timeout, closing done as it does.  In
+// the last case the blocked wait is terminated and the error is logged;
+// playback is not stopped, but the FIFOs are no longer refilled.
+func (dac *AP235) serviceInterrupts(stop, done chan struct{}, timeout time.Duration) {
+	defer close(done)
 	// the minimum recommended timer period is 0x136
 	// which is (310 * 32 ns) = 9.9us
 	// so this loop could happen as frequently as
@@ -654,7 +1207,22 @@ func (dac *AP235) serviceInterrupts() {
 		// deadlock.  I don't know how to solve this problem in a way that makes
 		// the waveform and any real time needs happy simultaneously.
 		// TODO
+		//
+		// as a guard against the driver hanging, the wait is terminated if
+		// it runs longer than timeout
+		watchdog := time.AfterFunc(timeout, func() {
+			log.Printf("acromag: AP235 waited more than %v for an interrupt, abandoning waveform servicing", timeout)
+			C.APTerminateBlockedStart(dac.cfg.nHandle)
+		})
 		Cstatus := C.fetch_status(dac.cfg)
+		if !watchdog.Stop() {
+			return
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
 		status := uint(Cstatus)
 
 		if status == 0 {
@@ -685,6 +1253,7 @@ func (dac *AP235) Clear(channel int) error {
 	dac.cfg.head_ptr[C.int(channel)] = nil
 	dac.cfg.tail_ptr[C.int(channel)] = nil
 	dac.sendCfgToBoard(channel)
+	dac.commanded[channel] = false
 	// C.Teardown_board_corrected_buffer(dac.cfg, dac.cScatterInfo)
 	return nil
 }
@@ -697,6 +1266,7 @@ func (dac *AP235) Reset(channel int) error {
 	dac.cfg.opts._chan[C.int(channel)].FullReset = C.int(1)
 	dac.sendCfgToBoard(channel)
 	dac.cfg.opts._chan[C.int(channel)].FullReset = C.int(0)
+	dac.commanded[channel] = false
 	return nil
 }
 
@@ -723,6 +1293,53 @@ func (dac *AP235) Status(channel int) ChannelStatus {
 	return out
 }
 
+// SelfTest checks the health of the board, for use before blaming the
+// hardware and rebooting when the driver appears to have glitched.
+//
+// The flash ID and calibration coefficients are read again and compared to
+// those read when the board was opened, the firmware revision is read to
+// check the board responds, and the status of each enabled channel is read
+// with Status to check none are stuck busy.  The AP235 cannot read back its
+// outputs, so no loopback test is done.  The test cannot be run during
+// playback.
+func (dac *AP235) SelfTest() error {
+	dac.Lock()
+	if dac.playingBack {
+		dac.Unlock()
+		return errors.New("AP235 cannot self test during playback")
+	}
+	var id [32]C.uchar
+	C.ReadFlashID235(dac.cfg, &id[0])
+	idb := C.GoBytes(unsafe.Pointer(&id[0]), C.int(len(id)))
+	if err := checkFlashID(idb, "AP235"); err != nil {
+		dac.Unlock()
+		return err
+	}
+	saved := dac.cfg.ogc235
+	C.rcc235(dac.cfg)
+	changed := dac.cfg.ogc235 != saved
+	dac.cfg.ogc235 = saved
+	if changed {
+		dac.Unlock()
+		return errors.New("calibration coefficients read back differ from those read at open")
+	}
+	C.rsts235(dac.cfg)
+	rev := uint32(dac.cfg.revision)
+	dac.Unlock()
+	if err := checkRevision(rev); err != nil {
+		return err
+	}
+	for i := 0; i < 16; i++ {
+		if enabled, _ := dac.GetChannelEnabled(i); !enabled {
+			continue
+		}
+		if stat := dac.Status(i); stat.Busy {
+			return fmt.Errorf("channel %d reports busy while idle: %+v", i, stat)
+		}
+	}
+	return nil
+}
+
 func (dac *AP235) doTransfer(channel int) {
 	head := dac.cursor[channel]
 	tailOffset := MaxXferSize
@@ -768,3 +1385,18 @@ func cMkarrayU16(size int) ([]uint16, *C.short, error) {
 	hdr.Data = uintptr(unsafe.Pointer(cptr))
 	return slc, cptr, nil
 }
+
+// GetModel returns the model of the DAC
+func (dac *AP235) GetModel() (string, error) {
+	return "AP235", nil
+}
+
+// GetSerialNumber returns an empty string; the card does not report one
+func (dac *AP235) GetSerialNumber() (string, error) {
+	return "", nil
+}
+
+// GetFirmwareVersion returns an empty string; the card does not report one
+func (dac *AP235) GetFirmwareVersion() (string, error) {
+	return "", nil
+}
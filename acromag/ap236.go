@@ -9,12 +9,31 @@ import "C"
 import (
 	"errors"
 	"fmt"
+	"math"
+	"sync"
 	"unsafe"
+
+	"github.com/nasa-jpl/golaborate/generichttp/daq"
 )
 
 // AP236 is an acromag 16-bit DAC of the same type
 type AP236 struct {
+	sync.Mutex
+
 	cfg *C.struct_cblk236
+
+	// disabled marks channels which are not in use and must not be written
+	disabled [16]bool
+
+	// lastVoltage is the last voltage commanded on each channel, valid if
+	// the corresponding element of commanded is true
+	lastVoltage [16]float64
+	commanded   [16]bool
+
+	// staged holds voltages accumulated by Stage and written by Commit,
+	// valid if the corresponding element of isStaged is true
+	staged   [16]float64
+	isStaged [16]bool
 }
 
 // NewAP236 creates a new instance and opens the connection to the DAC
@@ -58,6 +77,13 @@ func NewAP236(deviceIndex int) (*AP236, error) {
 // this function only returns an error if the range is not allowed
 // rngS is specified as in ValidateOutputRange
 func (dac *AP236) SetRange(channel int, rngS string) error {
+	dac.Lock()
+	defer dac.Unlock()
+	return dac.setRange(channel, rngS)
+}
+
+// setRange is SetRange without locking.  The caller must hold the lock.
+func (dac *AP236) setRange(channel int, rngS string) error {
 	rng, err := ValidateOutputRange(rngS)
 	if err != nil {
 		return err
@@ -68,10 +94,44 @@ func (dac *AP236) SetRange(channel int, rngS string) error {
 	return nil
 }
 
+// SetRangePreservingOutput changes the output range of a channel and
+// re-commands the last voltage written to it in the new range, so that the
+// physical output does not jump.  An error is generated if no voltage has been
+// commanded or the voltage is not representable in the new range, in which
+// case the range is not changed.
+func (dac *AP236) SetRangePreservingOutput(channel int, rngS string) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	if _, err := ValidateOutputRange(rngS); err != nil {
+		return err
+	}
+	if !dac.commanded[channel] {
+		return fmt.Errorf("channel %d: no output has been commanded, nothing to preserve", channel)
+	}
+	v := dac.lastVoltage[channel]
+	min, max, err := RangeToMinMax(rngS)
+	if err != nil {
+		return err
+	}
+	if v < min || v > max {
+		return fmt.Errorf("channel %d: output %f V is not representable in range %s", channel, v, rngS)
+	}
+	err = dac.setRange(channel, rngS)
+	if err != nil {
+		return err
+	}
+	return dac.output(channel, v)
+}
+
 // GetRange returns the output range of the DAC in volts.
-// The error value is always nil; the API looks
-// this way for symmetry with Set
+// The error is only non-nil if the channel is out of range
 func (dac *AP236) GetRange(channel int) (string, error) {
+	if err := checkChannel(channel); err != nil {
+		return "", err
+	}
 	Crng := dac.cfg.opts._chan[C.int(channel)].Range
 	return FormatOutputRange(OutputRange(Crng)), nil
 }
@@ -146,8 +206,11 @@ func (dac *AP236) SetOverRange(channel int, allowed bool) error {
 }
 
 // GetOverRange returns true if the DAC output is allowed to exceed nominal by 5%
-// the error is always nil
+// the error is only non-nil if the channel is out of range
 func (dac *AP236) GetOverRange(channel int) (bool, error) {
+	if err := checkChannel(channel); err != nil {
+		return false, err
+	}
 	Cint := dac.cfg.opts._chan[C.int(channel)].OverRange
 	return Cint == 1, nil
 }
@@ -172,6 +235,81 @@ func (dac *AP236) GetOutputSimultaneous(channel int) (bool, error) {
 	return i == 1, nil
 }
 
+// SetChannelEnabled marks a channel as in use (enabled == true) or not.
+// Output and OutputMulti reject disabled channels.
+// All channels are enabled when the DAC is opened.
+func (dac *AP236) SetChannelEnabled(channel int, enabled bool) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	dac.disabled[channel] = !enabled
+	return nil
+}
+
+// GetChannelEnabled returns true if the channel is in use
+func (dac *AP236) GetChannelEnabled(channel int) (bool, error) {
+	if err := checkChannel(channel); err != nil {
+		return false, err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	return !dac.disabled[channel], nil
+}
+
+// ExportConfig returns the configuration of every channel
+func (dac *AP236) ExportConfig() (daq.DACConfig, error) {
+	cfg := daq.DACConfig{Model: "AP236", Channels: make([]daq.ChannelConfig, 16)}
+	// the getters read the config block and never return an error
+	for ch := range cfg.Channels {
+		c := &cfg.Channels[ch]
+		c.Range, _ = dac.GetRange(ch)
+		pwr, _ := dac.GetPowerUpVoltage(ch)
+		clr, _ := dac.GetClearVoltage(ch)
+		c.PowerUpScale, c.ClearScale = int(pwr), int(clr)
+		c.OverTempShutdown, _ = dac.GetOverTempBehavior(ch)
+		c.OverRange, _ = dac.GetOverRange(ch)
+		c.Simultaneous, _ = dac.GetOutputSimultaneous(ch)
+		c.Enabled, _ = dac.GetChannelEnabled(ch)
+	}
+	return cfg, nil
+}
+
+// ImportConfig applies a configuration from ExportConfig to the DAC
+func (dac *AP236) ImportConfig(cfg daq.DACConfig) error {
+	if err := checkConfig(cfg, "AP236"); err != nil {
+		return err
+	}
+	for ch, c := range cfg.Channels {
+		err := dac.importChannel(ch, c)
+		if err != nil {
+			return fmt.Errorf("channel %d: %w", ch, err)
+		}
+	}
+	return nil
+}
+
+func (dac *AP236) importChannel(ch int, c daq.ChannelConfig) error {
+	err := dac.SetRange(ch, c.Range)
+	if err != nil {
+		return err
+	}
+	err = dac.SetPowerUpVoltage(ch, OutputScale(c.PowerUpScale))
+	if err != nil {
+		return err
+	}
+	err = dac.SetClearVoltage(ch, OutputScale(c.ClearScale))
+	if err != nil {
+		return err
+	}
+	// the remaining setters only fail on invalid input
+	dac.SetOverTempBehavior(ch, c.OverTempShutdown)
+	dac.SetOverRange(ch, c.OverRange)
+	dac.SetOutputSimultaneous(ch, c.Simultaneous)
+	return dac.SetChannelEnabled(ch, c.Enabled)
+}
+
 // sendCfgToBoard updates the configuration on the board
 func (dac *AP236) sendCfgToBoard(channel int) {
 	C.cnfg236(dac.cfg, C.int(channel))
@@ -179,24 +317,111 @@ func (dac *AP236) sendCfgToBoard(channel int) {
 }
 
 // Output writes a voltage to a channel.
-// the error is only non-nil if the value is out of range
+// the error is only non-nil if the channel is out of range or disabled
 func (dac *AP236) Output(channel int, voltage float64) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	return dac.output(channel, voltage)
+}
+
+// output is Output without locking.  The caller must hold the lock.
+func (dac *AP236) output(channel int, voltage float64) error {
+	if dac.disabled[channel] {
+		return fmt.Errorf("channel %d: %w", channel, ErrChannelDisabled)
+	}
 	// TODO: look into cd236 C function
 	C.cd236(dac.cfg, C.int(channel), C.double(voltage))
 	C.wro236(dac.cfg, C.int(channel), (C.word)(dac.cfg.cor_buf[channel]))
+	dac.lastVoltage[channel] = voltage
+	dac.commanded[channel] = true
 	return nil
 	// return dac.OutputDN16(channel, dac.calibrateData(channel, voltage))
 }
 
+// GetOutput returns the voltage last commanded on a channel by Output,
+// OutputDN16, OutputMulti, or Commit.  It is the commanded value, not a
+// measurement; the board has no readback.  An error wrapping
+// daq.ErrNotCommanded is returned if nothing has been commanded since the DAC
+// was opened or the channel was cleared or reset
+func (dac *AP236) GetOutput(channel int) (float64, error) {
+	if err := checkChannel(channel); err != nil {
+		return 0, err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	if !dac.commanded[channel] {
+		return 0, fmt.Errorf("channel %d: %w", channel, daq.ErrNotCommanded)
+	}
+	return dac.lastVoltage[channel], nil
+}
+
+// VoltsToDN converts a voltage to the DN the board is sent for it on a
+// channel, using the channel's range and the calibration read from the
+// board.  This is the conversion done by cd236 for Output, and the DN is in
+// the two's complement format of the board's output registers.  Voltages
+// outside the range are clipped
+func (dac *AP236) VoltsToDN(channel int, v float64) (uint16, error) {
+	if err := checkChannel(channel); err != nil {
+		return 0, err
+	}
+	slope, zero := dac.calibration(channel)
+	rng := dac.cfg.opts._chan[C.int(channel)].Range & 0x7
+	out := math.Round(slope*v + zero)
+	out = math.Min(out, float64(dac.cfg.pIdealCode[rng][clipHi]))
+	out = math.Max(out, float64(dac.cfg.pIdealCode[rng][clipLo]))
+	return uint16(int16(out)), nil
+}
+
+// DNToVolts is the inverse of VoltsToDN
+func (dac *AP236) DNToVolts(channel int, dn uint16) (float64, error) {
+	if err := checkChannel(channel); err != nil {
+		return 0, err
+	}
+	slope, zero := dac.calibration(channel)
+	return (float64(int16(dn)) - zero) / slope, nil
+}
+
+// calibration returns the slope (DN/V) and zero (DN) of a channel in its
+// present range, the same coefficients used by cd236
+func (dac *AP236) calibration(channel int) (float64, float64) {
+	cCh := C.int(channel)
+	rng := dac.cfg.opts._chan[cCh].Range & 0x7
+	gainCoef := 1 + float64(dac.cfg.ogc236[cCh][rng][gain])/1048576
+	slope := gainCoef * float64(dac.cfg.pIdealCode[rng][idealSlope])
+	zero := float64(dac.cfg.pIdealCode[rng][idealZeroBTC]) + float64(dac.cfg.ogc236[cCh][rng][offset])/16
+	return slope, zero
+}
+
 // OutputDN16 writes a value to the board in DN.
-// the error is always nil
+// the error is only non-nil if the channel is out of range or disabled
 func (dac *AP236) OutputDN16(channel int, value uint16) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	return dac.outputDN16(channel, value)
+}
+
+// outputDN16 is OutputDN16 without locking.  The caller must hold the lock.
+func (dac *AP236) outputDN16(channel int, value uint16) error {
+	if dac.disabled[channel] {
+		return fmt.Errorf("channel %d: %w", channel, ErrChannelDisabled)
+	}
 	rng, _ := dac.GetRange(channel)
-	min, max := RangeToMinMax(rng)
+	min, max, err := RangeToMinMax(rng)
+	if err != nil {
+		return err
+	}
 	step := (max - min) / 65535
 	fV := min + step*float64(value)
 	C.cd236(dac.cfg, C.int(channel), C.double(fV))
 	C.wro236(dac.cfg, C.int(channel), (C.word)(dac.cfg.cor_buf[channel]))
+	dac.lastVoltage[channel] = fV
+	dac.commanded[channel] = true
 	return nil
 }
 
@@ -204,6 +429,7 @@ func (dac *AP236) OutputDN16(channel int, value uint16) error {
 // the error is non-nil if any of these conditions occur:
 //	1.  A blend of output modes (some simultaneous, some immediate)
 //  2.  A command is out of range
+//  3.  A channel is disabled
 //
 // if an error is encountered in case 2, the output buffer of the DAC may be
 // partially updated from proceeding valid commands.  No invalid values escape
@@ -213,6 +439,11 @@ func (dac *AP236) OutputDN16(channel int, value uint16) error {
 //
 // passing zero length slices will cause a panic.  Slices must be of equal length.
 func (dac *AP236) OutputMulti(channels []int, voltages []float64) error {
+	if err := checkChannels(channels); err != nil {
+		return err
+	}
+	dac.Lock()
+	defer dac.Unlock()
 	// ensure channels are homogeneous
 	sim, _ := dac.GetOutputSimultaneous(channels[0])
 	for i := 0; i < len(channels); i++ { // old for is faster than range, this code may be hot
@@ -221,9 +452,12 @@ func (dac *AP236) OutputMulti(channels []int, voltages []float64) error {
 			return fmt.Errorf("mixture of output modes used, must be homogeneous.  Channel %d != channel %d",
 				channels[i], channels[0])
 		}
+		if dac.disabled[channels[i]] {
+			return fmt.Errorf("channel %d: %w", channels[i], ErrChannelDisabled)
+		}
 	}
 	for i := 0; i < len(channels); i++ {
-		err := dac.Output(channels[i], voltages[i])
+		err := dac.output(channels[i], voltages[i])
 		if err != nil {
 			return fmt.Errorf("channel %d voltage %f: %w", channels[i], voltages[i], err)
 		}
@@ -237,6 +471,11 @@ func (dac *AP236) OutputMulti(channels []int, voltages []float64) error {
 // OutputMultiDN16 is equivalent to OutputMulti, but with DNs instead of volts.
 // see the docstring of OutputMulti for more information.
 func (dac *AP236) OutputMultiDN16(channels []int, uint16s []uint16) error {
+	if err := checkChannels(channels); err != nil {
+		return err
+	}
+	dac.Lock()
+	defer dac.Unlock()
 	// ensure channels are homogeneous
 	sim, _ := dac.GetOutputSimultaneous(channels[0])
 	for i := 0; i < len(channels); i++ { // old for is faster than range, this code may be hot
@@ -245,9 +484,12 @@ func (dac *AP236) OutputMultiDN16(channels []int, uint16s []uint16) error {
 			return fmt.Errorf("mixture of output modes used, must be homogeneous.  Channel %d != channel %d",
 				channels[i], channels[0])
 		}
+		if dac.disabled[channels[i]] {
+			return fmt.Errorf("channel %d: %w", channels[i], ErrChannelDisabled)
+		}
 	}
 	for i := 0; i < len(channels); i++ {
-		err := dac.OutputDN16(channels[i], uint16s[i])
+		err := dac.outputDN16(channels[i], uint16s[i])
 		if err != nil {
 			return fmt.Errorf("channel %d DN %d: %w", channels[i], uint16s[i], err)
 		}
@@ -263,21 +505,123 @@ func (dac *AP236) Flush() {
 	C.simtrig236(dac.cfg)
 }
 
+// Stage queues a voltage to be written to a channel by the next Commit,
+// replacing any value already staged for it.  The channel must be enabled and
+// in simultaneous output mode, so that nothing reaches the output until the
+// batch is flushed, and the voltage must be within the channel's range
+func (dac *AP236) Stage(channel int, voltage float64) error {
+	if err := checkChannel(channel); err != nil {
+		return err
+	}
+	dac.Lock()
+	defer dac.Unlock()
+	if dac.disabled[channel] {
+		return fmt.Errorf("channel %d: %w", channel, ErrChannelDisabled)
+	}
+	sim, _ := dac.GetOutputSimultaneous(channel)
+	if !sim {
+		return fmt.Errorf("%w: channel %d is in immediate output mode and cannot be staged", daq.ErrInvalidSetting, channel)
+	}
+	rng, _ := dac.GetRange(channel)
+	min, max, err := RangeToMinMax(rng)
+	if err != nil {
+		return err
+	}
+	if voltage < min || voltage > max {
+		return fmt.Errorf("%w: channel %d voltage %f outside range %s", daq.ErrInvalidSetting, channel, voltage, rng)
+	}
+	dac.staged[channel] = voltage
+	dac.isStaged[channel] = true
+	return nil
+}
+
+// GetStaged returns the channels with a staged voltage, in ascending order,
+// and their voltages
+func (dac *AP236) GetStaged() ([]int, []float64, error) {
+	dac.Lock()
+	defer dac.Unlock()
+	chans, volts := dac.getStaged()
+	return chans, volts, nil
+}
+
+// getStaged is GetStaged without locking.  The caller must hold the lock.
+func (dac *AP236) getStaged() ([]int, []float64) {
+	var (
+		chans []int
+		volts []float64
+	)
+	for ch := range dac.isStaged {
+		if dac.isStaged[ch] {
+			chans = append(chans, ch)
+			volts = append(volts, dac.staged[ch])
+		}
+	}
+	return chans, volts
+}
+
+// Commit writes every staged voltage to the board and flushes once, so that
+// all of the staged channels update together.  The stage is emptied.
+// If a staged channel has since been disabled or moved to immediate output
+// mode, nothing is written and the stage is kept.  Committing an empty stage
+// does nothing
+func (dac *AP236) Commit() error {
+	dac.Lock()
+	defer dac.Unlock()
+	chans, volts := dac.getStaged()
+	if len(chans) == 0 {
+		return nil
+	}
+	for _, ch := range chans {
+		if dac.disabled[ch] {
+			return fmt.Errorf("channel %d: %w", ch, ErrChannelDisabled)
+		}
+		if sim, _ := dac.GetOutputSimultaneous(ch); !sim {
+			return fmt.Errorf("%w: staged channel %d is no longer in simultaneous output mode", daq.ErrInvalidSetting, ch)
+		}
+	}
+	for i, ch := range chans {
+		C.cd236(dac.cfg, C.int(ch), C.double(volts[i]))
+		C.wro236(dac.cfg, C.int(ch), (C.word)(dac.cfg.cor_buf[ch]))
+	}
+	dac.Flush()
+	for i, ch := range chans {
+		dac.lastVoltage[ch] = volts[i]
+		dac.commanded[ch] = true
+	}
+	dac.isStaged = [16]bool{}
+	return nil
+}
+
+// Discard empties the stage without writing anything.
+// the error is always nil
+func (dac *AP236) Discard() error {
+	dac.Lock()
+	defer dac.Unlock()
+	dac.isStaged = [16]bool{}
+	return nil
+}
+
 // Clear soft resets the DAC, clearing the output but not configuration
 // the error is always nil
 func (dac *AP236) Clear(channel int) error {
+	dac.Lock()
+	defer dac.Unlock()
 	dac.cfg.opts._chan[C.int(channel)].DataReset = C.int(1)
 	dac.sendCfgToBoard(channel)
 	dac.cfg.opts._chan[C.int(channel)].DataReset = C.int(0)
+	dac.commanded[channel] = false
 	return nil
 }
 
 // Reset completely clears both data and configuration for a channel
 // the error is always nil
 func (dac *AP236) Reset(channel int) error {
+	dac.Lock()
+	defer dac.Unlock()
 	dac.cfg.opts._chan[C.int(channel)].FullReset = C.int(1)
 	dac.sendCfgToBoard(channel)
 	dac.cfg.opts._chan[C.int(channel)].FullReset = C.int(0)
+	dac.commanded[channel] = false
 	return nil
 }
 
@@ -286,3 +630,43 @@ func (dac *AP236) Close() error {
 	errC := C.APClose(dac.cfg.nHandle)
 	return enrich(errC, "APClose")
 }
+
+// SelfTest checks the health of the board, for use before blaming the
+// hardware and rebooting when the driver appears to have glitched.
+//
+// The flash ID and calibration coefficients are read again and compared to
+// those read when the board was opened, and the firmware revision is read to
+// check the board responds.  The AP236 cannot read back its outputs, so no
+// loopback test is done.
+func (dac *AP236) SelfTest() error {
+	var id [32]C.uchar
+	C.ReadFlashID236(dac.cfg, &id[0])
+	idb := C.GoBytes(unsafe.Pointer(&id[0]), C.int(len(id)))
+	if err := checkFlashID(idb, "AP236"); err != nil {
+		return err
+	}
+	saved := dac.cfg.ogc236
+	C.rcc236(dac.cfg)
+	changed := dac.cfg.ogc236 != saved
+	dac.cfg.ogc236 = saved
+	if changed {
+		return errors.New("calibration coefficients read back differ from those read at open")
+	}
+	C.rsts236(dac.cfg)
+	return checkRevision(uint32(dac.cfg.revision))
+}
+
+// GetModel returns the model of the DAC
+func (dac *AP236) GetModel() (string, error) {
+	return "AP236", nil
+}
+
+// GetSerialNumber returns an empty string; the card does not report one
+func (dac *AP236) GetSerialNumber() (string, error) {
+	return "", nil
+}
+
+// GetFirmwareVersion returns an empty string; the card does not report one
+func (dac *AP236) GetFirmwareVersion() (string, error) {
+	return "", nil
+}
@@ -91,6 +91,15 @@ void start_waveform(struct cblk235 *cfg)
 	output_long(cfg->nHandle, (long *)&cfg->brd_ptr->CommonControl, (long)temp);
 }
 
+void set_trigger_direction(struct cblk235 *cfg)
+{
+	// same as cnfg235, without reconfiguring (and resetting) a channel
+	long temp = input_long(cfg->nHandle, (long *)&cfg->brd_ptr->CommonControl);
+	temp &= 0xFFFFFFF7;	/* clear trigger direction */
+	temp |= cfg->TriggerDirection << 3;
+	output_long(cfg->nHandle, (long *)&cfg->brd_ptr->CommonControl, (long)temp);
+}
+
 void stop_waveform(struct cblk235 *cfg)
 {
 	// see drvr235.c line 459
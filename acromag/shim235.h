@@ -18,6 +18,8 @@ void enable_interrupts(struct cblk235 *cfg);
 
 void start_waveform(struct cblk235 *cfg);
 
+void set_trigger_direction(struct cblk235 *cfg);
+
 void stop_waveform(struct cblk235 *cfg);
 
 unsigned long fetch_status(struct cblk235 *cfg);
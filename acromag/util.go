@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/nasa-jpl/golaborate/generichttp/daq"
 )
 
 // OutputScale is the output scale of the DAC at power up or clear
@@ -89,6 +91,15 @@ const (
 
 	// MaxXferSize is the (max) number of samples to send in one DMA transfer
 	MaxXferSize = MAXSAMPLES / 2
+
+	// MinTimerPeriod is the shortest timer period in nanoseconds at which the
+	// DAC settles to better than 1LSB before the next sample, per Acromag
+	MinTimerPeriod = 310 * 32
+
+	// MinTimerPeriodAllChannels is the shortest timer period in nanoseconds
+	// at which samples can be transferred fast enough to feed all sixteen
+	// channels
+	MinTimerPeriodAllChannels = 620 * 32
 )
 
 var (
@@ -105,6 +116,10 @@ var (
 	// ErrTimerTooFast is generated when a timer is running too fast
 	ErrTimerTooFast = errors.New("timer too fast: DAC cannot settle to < 1LSB before next value given.  Value accepted")
 
+	// ErrTimerTooFastAllChannels is generated when a timer is running too
+	// fast for samples to be transferred to all channels in waveform mode
+	ErrTimerTooFastAllChannels = errors.New("timer too fast for transfer to DAC to keep up if all channels used; still accepted")
+
 	// ErrIncompatibleOperatingTrigger is generated when the triggering and operating modes are incompatible
 	ErrIncompatibleOperatingTrigger = errors.New("operating mode and trigger source are incompatible, software+single or (external|timer)+waveform are the only valid combinations.  Change accepted, state inconsistent")
 
@@ -112,6 +127,15 @@ var (
 	// to a channel configured for waveform playback
 	ErrIncompatibleWaveform = errors.New("single output commands are not possible when channel is configured for waveform playback")
 
+	// ErrChannelDisabled is generated when output or waveform commands are sent
+	// to a channel that has been disabled
+	ErrChannelDisabled = errors.New("channel is disabled")
+
+	// ErrSharedTimer is generated when waveforms populated at different timer
+	// periods are played back together.  The AP235 has one timer for all
+	// channels, so waveform channels cannot have independent sample rates
+	ErrSharedTimer = errors.New("waveforms were populated at different timer periods, but all channels share one timer")
+
 	// IdealCode is the array from drvr236.c L60-L85
 	// its inner elements, by index:
 	// 0 - zero value DN, straight binary
@@ -193,7 +217,7 @@ func ValidateOutputRange(s string) (OutputRange, error) {
 	case "0,20":
 		return TwentyVPos, nil
 	default:
-		return -1, errors.New("invalid output range")
+		return -1, fmt.Errorf("%w: output range %q is not supported", daq.ErrInvalidSetting, s)
 	}
 }
 
@@ -222,14 +246,21 @@ func FormatOutputRange(o OutputRange) string {
 }
 
 // RangeToMinMax converts a range string, <min,max> to floats.
-// the input is assumed to be well formed; 0,0 is returned for badly formed inputs,
-// or a panic occurs for inputs not containing a 0
-func RangeToMinMax(rangeS string) (float64, float64) {
-	// assume well-formed input,
+// an error is returned if the input is not two numbers separated by a comma
+func RangeToMinMax(rangeS string) (float64, float64, error) {
 	pieces := strings.Split(rangeS, ",")
-	f1, _ := strconv.ParseFloat(pieces[0], 64)
-	f2, _ := strconv.ParseFloat(pieces[1], 64)
-	return f1, f2
+	if len(pieces) != 2 {
+		return 0, 0, fmt.Errorf("range %q must be formatted as min,max", rangeS)
+	}
+	f1, err := strconv.ParseFloat(pieces[0], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("range %q: %w", rangeS, err)
+	}
+	f2, err := strconv.ParseFloat(pieces[1], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("range %q: %w", rangeS, err)
+	}
+	return f1, f2, nil
 }
 
 // ValidateTriggerMode ensures that a triggering mode is valid
@@ -243,7 +274,7 @@ func ValidateTriggerMode(s string) (TriggerMode, error) {
 	case "external":
 		return TriggerExternal, nil
 	default:
-		return -1, errors.New("triggering mode must be a member of {software, timer, external}")
+		return -1, fmt.Errorf("%w: triggering mode must be a member of {software, timer, external}", daq.ErrInvalidSetting)
 	}
 }
 
@@ -271,7 +302,7 @@ func ValidateOperatingMode(s string) (OperatingMode, error) {
 	case "waveform":
 		return OperatingWaveform, nil
 	default:
-		return -1, errors.New("operating mode must be a member of {single, waveform}")
+		return -1, fmt.Errorf("%w: operating mode must be a member of {single, waveform}", daq.ErrInvalidSetting)
 	}
 }
 
@@ -337,3 +368,53 @@ func cMkCopyOfIdealData(idealCodes [8][7]float64) *[8][7]C.double {
 	}
 	return cArr
 }
+
+// checkChannel returns an error if channel is not a valid index into a
+// 16-channel board
+func checkChannel(channel int) error {
+	if channel < 0 || channel > 15 {
+		return fmt.Errorf("%w: channel %d out of range [0,15]", daq.ErrInvalidSetting, channel)
+	}
+	return nil
+}
+
+// checkChannels is checkChannel for each of a list of channels
+func checkChannels(channels []int) error {
+	for _, ch := range channels {
+		if err := checkChannel(ch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkConfig verifies a configuration was taken from the given model of DAC
+// and has an entry for every channel
+func checkConfig(cfg daq.DACConfig, model string) error {
+	if cfg.Model != model {
+		return fmt.Errorf("configuration is for model %q, not %s", cfg.Model, model)
+	}
+	if len(cfg.Channels) != 16 {
+		return fmt.Errorf("configuration has %d channels, expected 16", len(cfg.Channels))
+	}
+	return nil
+}
+
+// checkFlashID verifies the ID string read from a board's flash, which may
+// be NUL padded, names the given model
+func checkFlashID(id []byte, model string) error {
+	s := strings.TrimRight(string(id), "\x00")
+	if !strings.Contains(s, model) {
+		return fmt.Errorf("flash ID %q does not identify an %s; the flash could not be read", s, model)
+	}
+	return nil
+}
+
+// checkRevision returns an error if the firmware revision read from a board
+// is all ones, which is what reads of a board that is not responding return
+func checkRevision(rev uint32) error {
+	if rev == 0xFFFFFFFF {
+		return errors.New("firmware revision read as 0xFFFFFFFF; the board is not responding")
+	}
+	return nil
+}
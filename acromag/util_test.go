@@ -0,0 +1,60 @@
+package acromag
+
+import "testing"
+
+var allRanges = []OutputRange{
+	TenVSymm, TenVPos, FiveVSymm, FiveVPos,
+	N2_5To7_5V, ThreeVSymm, SixteenVPos, TwentyVPos,
+}
+
+func TestOutputRangeRoundTrip(t *testing.T) {
+	for _, rng := range allRanges {
+		s := FormatOutputRange(rng)
+		if s == "" {
+			t.Errorf("range %d has no string form", rng)
+			continue
+		}
+		rng2, err := ValidateOutputRange(s)
+		if err != nil {
+			t.Errorf("range %d formatted as %q does not validate: %v", rng, s, err)
+			continue
+		}
+		if rng2 != rng {
+			t.Errorf("range %q round tripped from %d to %d", s, rng, rng2)
+		}
+	}
+}
+
+func TestRangeToMinMaxMatchesIdealCode(t *testing.T) {
+	for _, rng := range allRanges {
+		s := FormatOutputRange(rng)
+		min, max, err := RangeToMinMax(s)
+		if err != nil {
+			t.Errorf("range %q: %v", s, err)
+			continue
+		}
+		lo, hi := idealCode[rng][endpointLo], idealCode[rng][endpointHi]
+		if min != lo || max != hi {
+			t.Errorf("range %q parsed to %f,%f, ideal code endpoints are %f,%f", s, min, max, lo, hi)
+		}
+	}
+}
+
+func TestValidateOutputRangeRejectsInvalid(t *testing.T) {
+	for _, s := range []string{"", "10", "-10, 10", "10,-10", "-1,1", "abc"} {
+		if _, err := ValidateOutputRange(s); err == nil {
+			t.Errorf("range %q validated, expected an error", s)
+		}
+	}
+	if s := FormatOutputRange(OutputRange(-1)); s != "" {
+		t.Errorf("range -1 formatted as %q, expected empty", s)
+	}
+}
+
+func TestRangeToMinMaxRejectsMalformed(t *testing.T) {
+	for _, s := range []string{"", "10", "a,b", "1,2,3", "-10,", ",10"} {
+		if _, _, err := RangeToMinMax(s); err == nil {
+			t.Errorf("range %q parsed, expected an error", s)
+		}
+	}
+}
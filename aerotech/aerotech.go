@@ -7,6 +7,7 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/nasa-jpl/golaborate/comm"
@@ -48,6 +49,15 @@ type Ensemble struct {
 	velocities map[string]float64
 
 	asyncMode *bool
+
+	// mu guards program and pso, which are changed by concurrent requests
+	mu sync.Mutex
+
+	// program is the program last run on ProgramTask
+	program string
+
+	// pso holds the PSO configuration of the axes, which the controller does not report
+	pso map[string]PSOConfig
 }
 
 // NewEnsemble returns a new Ensemble instance
@@ -59,6 +69,7 @@ func NewEnsemble(addr string, connectSerial bool) *Ensemble {
 	return &Ensemble{
 		pool:       pool,
 		velocities: map[string]float64{},
+		pso:        map[string]PSOConfig{},
 		timeout:    300 * time.Second}
 }
 
@@ -325,3 +336,20 @@ func (e *Ensemble) GetVelocity(axis string) (float64, error) {
 func (e *Ensemble) Raw(s string) (string, error) {
 	return e.writeRead(s)
 }
+
+// GetModel returns the model of the controller
+func (e *Ensemble) GetModel() (string, error) {
+	return "Ensemble", nil
+}
+
+// GetSerialNumber returns an empty string; the ASCII interface does not
+// expose the serial number
+func (e *Ensemble) GetSerialNumber() (string, error) {
+	return "", nil
+}
+
+// GetFirmwareVersion returns an empty string; the ASCII interface does not
+// expose the firmware version
+func (e *Ensemble) GetFirmwareVersion() (string, error) {
+	return "", nil
+}
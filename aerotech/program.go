@@ -0,0 +1,257 @@
+package aerotech
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// ProgramTask is the controller task AeroBasic programs are run on.  The
+// ASCII interface itself is served by another task, so commands such as
+// PFBK and AXISSTATUS continue to work while a program runs
+const ProgramTask = 1
+
+// TaskState is the state of a controller task, as returned by TASKSTATE
+type TaskState int
+
+const (
+	// TaskUnavailable means the task is not available on the controller
+	TaskUnavailable TaskState = iota
+
+	// TaskInactive means the task is disabled
+	TaskInactive
+
+	// TaskIdle means the task has no program loaded
+	TaskIdle
+
+	// TaskProgramReady means a program is loaded but not running
+	TaskProgramReady
+
+	// TaskProgramRunning means a program is running
+	TaskProgramRunning
+
+	// TaskProgramFeedhold means a program is running, but motion is held
+	TaskProgramFeedhold
+
+	// TaskProgramPaused means a program is paused
+	TaskProgramPaused
+
+	// TaskProgramComplete means a program ran to its end
+	TaskProgramComplete
+
+	// TaskError means a program stopped on an error
+	TaskError
+)
+
+var taskStateNames = map[TaskState]string{
+	TaskUnavailable:     "Unavailable",
+	TaskInactive:        "Inactive",
+	TaskIdle:            "Idle",
+	TaskProgramReady:    "ProgramReady",
+	TaskProgramRunning:  "ProgramRunning",
+	TaskProgramFeedhold: "ProgramFeedhold",
+	TaskProgramPaused:   "ProgramPaused",
+	TaskProgramComplete: "ProgramComplete",
+	TaskError:           "Error",
+}
+
+func (t TaskState) String() string {
+	if s, ok := taskStateNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("TaskState(%d)", int(t))
+}
+
+// programName is the set of file names programs may be uploaded as.  It keeps
+// names from escaping the quotes they are sent in
+var programName = regexp.MustCompile(`^[A-Za-z0-9_\-]+(\.[A-Za-z0-9]+)?$`)
+
+func checkProgramName(name string) error {
+	if !programName.MatchString(name) {
+		return fmt.Errorf("program name %q may contain only letters, digits, _, and -, with an optional extension", name)
+	}
+	return nil
+}
+
+// quote returns s as an AeroBasic string literal
+func quote(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
+
+// ProgramStatus describes the program on ProgramTask
+type ProgramStatus struct {
+	// Name is the program last run by RunProgram, if any
+	Name string `json:"name"`
+
+	Task int `json:"task"`
+
+	// State is the TaskState as a string, and Code its numeric value
+	State string `json:"state"`
+	Code  int    `json:"code"`
+}
+
+// UploadProgram writes AeroBasic source to a file named name on the
+// controller, replacing any file with that name
+func (e *Ensemble) UploadProgram(name string, src string) error {
+	if err := checkProgramName(name); err != nil {
+		return err
+	}
+	err := e.writeOnly(fmt.Sprintf("FILEOPENWRITE %s", quote(name)))
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.Replace(src, "\r\n", "\n", -1), "\n") {
+		err = e.writeOnly(fmt.Sprintf("FILEWRITELINE %s", quote(line)))
+		if err != nil {
+			// close the file, but report the error that interrupted the upload
+			e.writeOnly("FILECLOSE")
+			return fmt.Errorf("uploading %s: %w", name, err)
+		}
+	}
+	return e.writeOnly("FILECLOSE")
+}
+
+// RunProgram loads and runs the program named name on ProgramTask
+func (e *Ensemble) RunProgram(name string) error {
+	if err := checkProgramName(name); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	err := e.writeOnly(fmt.Sprintf("PROGRAM RUN %d, %s", ProgramTask, quote(name)))
+	if err == nil {
+		e.program = name
+	}
+	return err
+}
+
+// StopProgram stops the program named name.  It is an error if it is not the
+// program last run on ProgramTask
+func (e *Ensemble) StopProgram(name string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if name != e.program {
+		return fmt.Errorf("program %q is not running, task %d was last used by %q", name, ProgramTask, e.program)
+	}
+	return e.writeOnly(fmt.Sprintf("PROGRAM STOP %d", ProgramTask))
+}
+
+// GetTaskState returns the state of a controller task
+func (e *Ensemble) GetTaskState(task int) (TaskState, error) {
+	resp, err := e.writeRead(fmt.Sprintf("TASKSTATE(%d)", task))
+	if err != nil {
+		return TaskUnavailable, err
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(resp))
+	return TaskState(i), err
+}
+
+// GetProgramStatus returns the state of ProgramTask and the program last run
+// on it
+func (e *Ensemble) GetProgramStatus() (ProgramStatus, error) {
+	state, err := e.GetTaskState(ProgramTask)
+	e.mu.Lock()
+	name := e.program
+	e.mu.Unlock()
+	return ProgramStatus{
+		Name:  name,
+		Task:  ProgramTask,
+		State: state.String(),
+		Code:  int(state)}, err
+}
+
+// ProgramRunner can upload and run programs on the controller
+type ProgramRunner interface {
+	UploadProgram(name string, src string) error
+	RunProgram(name string) error
+	StopProgram(name string) error
+	GetProgramStatus() (ProgramStatus, error)
+}
+
+// UploadProgram returns an HTTP handler func which uploads the request body
+// as the program named in the URL
+func UploadProgram(p ProgramRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if err := checkProgramName(name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		src, err := ioutil.ReadAll(r.Body)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = p.UploadProgram(name, string(src))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// RunProgram returns an HTTP handler func which runs the program named in
+// the URL
+func RunProgram(p ProgramRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		if err := checkProgramName(name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err := p.RunProgram(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// StopProgram returns an HTTP handler func which stops the program named in
+// the URL
+func StopProgram(p ProgramRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := p.StopProgram(chi.URLParam(r, "name"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// GetProgramStatus returns an HTTP handler func which responds with the
+// ProgramStatus as JSON
+func GetProgramStatus(p ProgramRunner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := p.GetProgramStatus()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(status)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// HTTPPrograms adds routes for ProgramRunner to the route table
+func HTTPPrograms(p ProgramRunner, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/program/{name}"}] = UploadProgram(p)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/program/{name}/run"}] = RunProgram(p)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/program/{name}/stop"}] = StopProgram(p)
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/program"}] = GetProgramStatus(p)
+}
@@ -0,0 +1,209 @@
+package aerotech
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// PSOPulseTime and PSOPulseOnTime are the period and on time of each PSO
+// output pulse, in microseconds
+const (
+	PSOPulseTime   = 20
+	PSOPulseOnTime = 10
+)
+
+// ErrInvalidPSO is generated when a PSO setting is out of range
+var ErrInvalidPSO = errors.New("invalid PSO setting")
+
+// axisName is the set of axis names accepted by the PSO commands
+var axisName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// PSOConfig is the Position Synchronized Output configuration of an axis.
+// The controller does not report it, so it is as last set through this
+// package
+type PSOConfig struct {
+	// Distance is the travel between pulses, in axis units
+	Distance float64 `json:"distance"`
+
+	// Enabled is true if the output is armed
+	Enabled bool `json:"enabled"`
+}
+
+func checkPSOAxis(axis string) error {
+	if !axisName.MatchString(axis) {
+		return fmt.Errorf("%w: axis %q is not a valid axis name", ErrInvalidPSO, axis)
+	}
+	return nil
+}
+
+// ConfigurePSO configures the Position Synchronized Output of an axis to emit
+// a pulse every distance of travel, in either direction.  The output is left
+// disarmed; use EnablePSO to start it
+func (e *Ensemble) ConfigurePSO(axis string, distance float64) error {
+	if err := checkPSOAxis(axis); err != nil {
+		return err
+	}
+	if !(distance > 0) || math.IsInf(distance, 0) {
+		return fmt.Errorf("%w: distance must be positive and finite, got %v", ErrInvalidPSO, distance)
+	}
+	dist := strconv.FormatFloat(distance, 'G', -1, 64)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, cmd := range []string{
+		fmt.Sprintf("PSOCONTROL %s RESET", axis),
+		fmt.Sprintf("PSOPULSE %s TIME %d,%d", axis, PSOPulseTime, PSOPulseOnTime),
+		fmt.Sprintf("PSOOUTPUT %s PULSE", axis),
+		fmt.Sprintf("PSODISTANCE %s FIXED %s UNITS", axis, dist),
+	} {
+		if err := e.writeOnly(cmd); err != nil {
+			return err
+		}
+	}
+	e.pso[axis] = PSOConfig{Distance: distance}
+	return nil
+}
+
+// EnablePSO arms the Position Synchronized Output of an axis.  It must have
+// been configured with ConfigurePSO
+func (e *Ensemble) EnablePSO(axis string) error {
+	if err := checkPSOAxis(axis); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	cfg, ok := e.pso[axis]
+	if !ok {
+		return fmt.Errorf("%w: PSO is not configured on axis %s, use ConfigurePSO first", ErrInvalidPSO, axis)
+	}
+	err := e.writeOnly(fmt.Sprintf("PSOCONTROL %s ARM", axis))
+	if err == nil {
+		cfg.Enabled = true
+		e.pso[axis] = cfg
+	}
+	return err
+}
+
+// DisablePSO disarms the Position Synchronized Output of an axis.  An axis
+// which was not configured with ConfigurePSO is disarmed, but remains
+// unconfigured
+func (e *Ensemble) DisablePSO(axis string) error {
+	if err := checkPSOAxis(axis); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	err := e.writeOnly(fmt.Sprintf("PSOCONTROL %s OFF", axis))
+	if cfg, ok := e.pso[axis]; ok && err == nil {
+		cfg.Enabled = false
+		e.pso[axis] = cfg
+	}
+	return err
+}
+
+// GetPSO returns the PSO configuration of an axis
+func (e *Ensemble) GetPSO(axis string) (PSOConfig, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	cfg, ok := e.pso[axis]
+	if !ok {
+		return cfg, fmt.Errorf("PSO is not configured on axis %s", axis)
+	}
+	return cfg, nil
+}
+
+// PSOController can configure position synchronized trigger output
+type PSOController interface {
+	ConfigurePSO(axis string, distance float64) error
+	EnablePSO(axis string) error
+	DisablePSO(axis string) error
+	GetPSO(axis string) (PSOConfig, error)
+}
+
+func psoErrorCode(err error) int {
+	if errors.Is(err, ErrInvalidPSO) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// SetPSO returns an HTTP handler func which configures PSO on an axis from a
+// PSOConfig in the body, and arms or disarms it
+func SetPSO(p PSOController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		axis := chi.URLParam(r, "axis")
+		cfg := PSOConfig{}
+		err := json.NewDecoder(r.Body).Decode(&cfg)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = p.ConfigurePSO(axis, cfg.Distance)
+		if err == nil && cfg.Enabled {
+			err = p.EnablePSO(axis)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), psoErrorCode(err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// GetPSO returns an HTTP handler func which responds with the PSOConfig of an
+// axis as JSON
+func GetPSO(p PSOController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg, err := p.GetPSO(chi.URLParam(r, "axis"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// SetPSOEnabled returns an HTTP handler func which arms or disarms PSO on an
+// axis from a BoolT in the body
+func SetPSOEnabled(p PSOController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		axis := chi.URLParam(r, "axis")
+		boolT := generichttp.BoolT{}
+		err := json.NewDecoder(r.Body).Decode(&boolT)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if boolT.Bool {
+			err = p.EnablePSO(axis)
+		} else {
+			err = p.DisablePSO(axis)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), psoErrorCode(err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// HTTPPSO adds routes for PSOController to the route table
+func HTTPPSO(p PSOController, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/axis/{axis}/pso"}] = GetPSO(p)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/axis/{axis}/pso"}] = SetPSO(p)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/axis/{axis}/pso/enabled"}] = SetPSOEnabled(p)
+}
@@ -0,0 +1,196 @@
+package aerotech
+
+import (
+	"bufio"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// fakeController acknowledges every command with OKCode and records it
+type fakeController struct {
+	ln net.Listener
+
+	mu   sync.Mutex
+	cmds []string
+}
+
+func newFakeController(t *testing.T) *fakeController {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeController{ln: ln}
+	go f.serve()
+	t.Cleanup(func() { ln.Close() })
+	return f
+}
+
+func (f *fakeController) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			scan := bufio.NewScanner(conn)
+			for scan.Scan() {
+				f.mu.Lock()
+				f.cmds = append(f.cmds, scan.Text())
+				f.mu.Unlock()
+				if _, err := conn.Write([]byte{OKCode, Terminator}); err != nil {
+					return
+				}
+			}
+		}(conn)
+	}
+}
+
+func (f *fakeController) commands() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.cmds...)
+}
+
+func newTestEnsemble(t *testing.T) (*Ensemble, *fakeController) {
+	t.Helper()
+	f := newFakeController(t)
+	return NewEnsemble(f.ln.Addr().String(), false), f
+}
+
+func TestConfigurePSORejectsInvalid(t *testing.T) {
+	e, f := newTestEnsemble(t)
+	cases := []struct {
+		axis string
+		dist float64
+	}{
+		{"X", 0},
+		{"X", -1},
+		{"X", math.Inf(1)},
+		{"", 1},
+		{"X Y", 1},
+		{"X;RESET", 1},
+	}
+	for _, c := range cases {
+		err := e.ConfigurePSO(c.axis, c.dist)
+		if !errors.Is(err, ErrInvalidPSO) {
+			t.Errorf("ConfigurePSO(%q, %v): expected ErrInvalidPSO, got %v", c.axis, c.dist, err)
+		}
+	}
+	if cmds := f.commands(); len(cmds) != 0 {
+		t.Errorf("expected no commands to be sent, got %q", cmds)
+	}
+}
+
+func TestConfigureAndEnablePSO(t *testing.T) {
+	e, f := newTestEnsemble(t)
+	if err := e.ConfigurePSO("X", 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.EnablePSO("X"); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := e.GetPSO("X")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Distance != 0.5 || !cfg.Enabled {
+		t.Errorf("expected {0.5 true}, got %+v", cfg)
+	}
+	cmds := f.commands()
+	if len(cmds) == 0 || cmds[len(cmds)-1] != "PSOCONTROL X ARM" {
+		t.Errorf("expected PSOCONTROL X ARM to be sent last, got %q", cmds)
+	}
+}
+
+func TestDisablePSOUnconfiguredAxis(t *testing.T) {
+	e, _ := newTestEnsemble(t)
+	if err := e.DisablePSO("Y"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.GetPSO("Y"); err == nil {
+		t.Error("expected Y to remain unconfigured after DisablePSO")
+	}
+	if err := e.EnablePSO("Y"); !errors.Is(err, ErrInvalidPSO) {
+		t.Errorf("EnablePSO on unconfigured axis: expected ErrInvalidPSO, got %v", err)
+	}
+}
+
+func TestPSOConcurrent(t *testing.T) {
+	e, _ := newTestEnsemble(t)
+	var wg sync.WaitGroup
+	for _, axis := range []string{"X", "Y", "Z", "U"} {
+		wg.Add(1)
+		go func(axis string) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if err := e.ConfigurePSO(axis, 1); err != nil {
+					t.Error(err)
+					return
+				}
+				e.GetPSO(axis)
+				e.DisablePSO(axis)
+			}
+		}(axis)
+	}
+	wg.Wait()
+}
+
+func servePSO(t *testing.T, e *Ensemble) *httptest.Server {
+	t.Helper()
+	table := generichttp.RouteTable{}
+	HTTPPSO(e, table)
+	r := chi.NewRouter()
+	table.Bind(r)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPSORoutes(t *testing.T) {
+	e, _ := newTestEnsemble(t)
+	srv := servePSO(t, e)
+	cases := []struct {
+		method, path, body string
+		code               int
+	}{
+		{http.MethodGet, "/axis/X/pso", "", http.StatusNotFound},
+		{http.MethodPost, "/axis/X/pso/enabled", `{"bool": true}`, http.StatusBadRequest},
+		{http.MethodPost, "/axis/X/pso", `{"distance": -1}`, http.StatusBadRequest},
+		{http.MethodPost, "/axis/X/pso", `{"distance": `, http.StatusBadRequest},
+		{http.MethodPost, "/axis/X/pso", `{"distance": 2, "enabled": true}`, http.StatusOK},
+		{http.MethodGet, "/axis/X/pso", "", http.StatusOK},
+		{http.MethodPost, "/axis/X/pso/enabled", `{"bool": false}`, http.StatusOK},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest(c.method, srv.URL+c.path, strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.code {
+			t.Errorf("%s %s %s: expected %d, got %d", c.method, c.path, c.body, c.code, resp.StatusCode)
+		}
+	}
+	cfg, err := e.GetPSO("X")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Distance != 2 || cfg.Enabled {
+		t.Errorf("expected {2 false}, got %+v", cfg)
+	}
+}
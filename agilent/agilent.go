@@ -33,13 +33,14 @@ type FunctionGenerator struct {
 // NewFunctionGenerator creates a new FunctionGenerator instance with
 // the communuication set up
 func NewFunctionGenerator(addr string, connectSerial bool) *FunctionGenerator {
-	var maker comm.CreationFunc
+	var pool comm.Leaser
 	if connectSerial {
-		maker = comm.SerialConnMaker(makeSerConf(addr))
+		conf := makeSerConf(addr)
+		pool = comm.NewSerialConn(comm.SerialConnMaker(conf), conf.ReadTimeout)
 	} else {
-		maker = comm.BackingOffTCPConnMaker(addr, time.Second)
+		maker := comm.BackingOffTCPConnMaker(addr, time.Second)
+		pool = comm.NewPool(1, time.Hour, maker)
 	}
-	pool := comm.NewPool(1, time.Hour, maker)
 	return &FunctionGenerator{scpi.SCPI{Pool: pool, Handshaking: true}}
 }
 
@@ -29,6 +29,7 @@ import (
 	"image"
 	"log"
 	"reflect"
+	"sort"
 	"strconv"
 	"time"
 	"unsafe"
@@ -61,6 +62,19 @@ type Camera struct {
 	shutterSpeed    *time.Duration
 	adchannel       *int
 	frameTransfer   *bool
+
+	// frameShutter is the FrameShutter mode, "" is FrameShutterManual
+	frameShutter string
+
+	// exposedShutter is the state of the shutter during the last frame, if
+	// it is known
+	exposedShutter *bool
+
+	// frameTiming is the timing of the last frame from GetFrame
+	frameTiming *camera.FrameTiming
+
+	// Env, if not nil, is read for the TAMB and RHUMID FITS cards
+	Env camera.EnvironmentProvider
 }
 
 func boolOptionHelper() map[string]interface{} {
@@ -131,6 +145,57 @@ func (c *Camera) ShutDown() {
 	C.ShutDown()
 }
 
+var (
+	// SafeShutDownTemperature is the temperature, in Celsius, the detector
+	// must be above before SafeShutDown calls ShutDown
+	SafeShutDownTemperature = -20.
+
+	// SafeShutDownTimeout bounds how long SafeShutDown waits for the detector
+	// to warm up
+	SafeShutDownTimeout = 10 * time.Minute
+)
+
+// SafeShutDown warms the detector before shutting down the camera.  If the
+// detector is at or below SafeShutDownTemperature, the setpoint is raised to
+// 0C (or the warmest the camera allows) and SafeShutDown waits for the
+// detector to rise above SafeShutDownTemperature with the cooler regulating
+// the warm-up.  The cooler is then turned off and ShutDown is called.
+//
+// If the temperature cannot be read or does not rise within
+// SafeShutDownTimeout, the camera is shut down anyway, since the caller is
+// exiting, and the error is returned
+func (c *Camera) SafeShutDown() error {
+	defer c.ShutDown()
+	t, err := c.GetTemperature()
+	if err != nil {
+		return fmt.Errorf("andor/sdk2: reading temperature before shutdown: %w", err)
+	}
+	if t > SafeShutDownTemperature {
+		return c.SetCooling(false)
+	}
+	setpoint := 0
+	if _, max, err := c.GetTemperatureRange(); err == nil && max < setpoint {
+		setpoint = max
+	}
+	err = c.SetTemperatureSetpoint(strconv.Itoa(setpoint))
+	if err != nil {
+		return fmt.Errorf("andor/sdk2: raising setpoint before shutdown: %w", err)
+	}
+	log.Printf("detector at %.0f C, warming above %.0f C before shutdown\n", t, SafeShutDownTemperature)
+	deadline := time.Now().Add(SafeShutDownTimeout)
+	for t <= SafeShutDownTemperature {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("andor/sdk2: detector still at %.0f C after %v, shut down while cold", t, SafeShutDownTimeout)
+		}
+		time.Sleep(time.Second)
+		t, err = c.GetTemperature()
+		if err != nil {
+			return fmt.Errorf("andor/sdk2: reading temperature before shutdown: %w", err)
+		}
+	}
+	return c.SetCooling(false)
+}
+
 // GetDetector gets the detector
 func (c *Camera) GetDetector() (int, int, error) { // need another return type
 	var x, y C.int
@@ -167,6 +232,57 @@ func (c *Camera) GetSoftwareVersion() (SoftwareVersion, error) {
 	return s, Error(errCode)
 }
 
+// GetComponentVersions returns the versions of this wrapper (WRAPVER), the
+// SDK library, the driver, and the camera firmware
+func (c *Camera) GetComponentVersions() (map[string]string, error) {
+	out := map[string]string{"wrapper": strconv.Itoa(WRAPVER)}
+	var errs []error
+	sw, err := c.GetSoftwareVersion()
+	if err != nil {
+		errs = append(errs, util.KeyedError{Key: "software", Err: err})
+	} else {
+		out["sdk"] = fmt.Sprintf("%d.%d", sw.DLLVersion, sw.DLLRevision)
+		out["driver"] = fmt.Sprintf("%d.%d", sw.DriverVersion, sw.DriverRevision)
+	}
+	hw, err := c.GetHardwareVersion()
+	if err != nil {
+		errs = append(errs, util.KeyedError{Key: "hardware", Err: err})
+	} else {
+		out["firmware"] = fmt.Sprintf("%d.%d", hw.CameraFirmwareVersion, hw.CameraFirmwareBuild)
+	}
+	return out, util.MergeErrors(errs)
+}
+
+// DeviceInfo adapts a Camera to generichttp.DeviceInfo, which the Camera does
+// not satisfy itself because its serial number is an int
+type DeviceInfo struct {
+	C *Camera
+}
+
+// GetModel returns an empty string; SDK2 does not report the model
+func (d DeviceInfo) GetModel() (string, error) {
+	return "", nil
+}
+
+// GetSerialNumber returns the serial number of the camera as a string
+func (d DeviceInfo) GetSerialNumber() (string, error) {
+	sn, err := d.C.GetSerialNumber()
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(sn), nil
+}
+
+// GetFirmwareVersion returns the version and build of the camera firmware as
+// version.build
+func (d DeviceInfo) GetFirmwareVersion() (string, error) {
+	hw, err := d.C.GetHardwareVersion()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d.%d", hw.CameraFirmwareVersion, hw.CameraFirmwareBuild), nil
+}
+
 // GetNumberVSSpeeds gets the number of vertical shift register speeds available
 func (c *Camera) GetNumberVSSpeeds() (int, error) {
 	var speeds C.int
@@ -311,6 +427,73 @@ func (c *Camera) SetHSSpeed(idx int) error {
 	return c.SetHSSpeedIndex(*c.adchannel, idx)
 }
 
+// SelectSlowestReadout sets the slowest horizontal shift speed of the present
+// AD channel and the slowest vertical shift speed, for the lowest read noise,
+// and returns the speeds chosen
+func (c *Camera) SelectSlowestReadout() (camera.ShiftSpeeds, error) {
+	return c.selectReadout(false)
+}
+
+// SelectFastestReadout sets the fastest horizontal shift speed of the present
+// AD channel and the fastest vertical shift speed which does not require the
+// vertical clock voltage to be raised, see GetFastestRecommendedVSSpeed, and
+// returns the speeds chosen
+func (c *Camera) SelectFastestReadout() (camera.ShiftSpeeds, error) {
+	return c.selectReadout(true)
+}
+
+func (c *Camera) selectReadout(fastest bool) (camera.ShiftSpeeds, error) {
+	var s camera.ShiftSpeeds
+	ch, err := c.GetADChannel()
+	if err != nil {
+		return s, err
+	}
+	s.ADChannel = ch
+	n, err := c.GetNumberHSSpeeds(ch)
+	if err != nil {
+		return s, err
+	}
+	if n < 1 {
+		return s, fmt.Errorf("andor/sdk2: AD channel %d has no horizontal shift speeds", ch)
+	}
+	for i := 0; i < n; i++ {
+		f, err := c.GetHSSpeedOption(ch, 0, i)
+		if err != nil {
+			return s, err
+		}
+		if i == 0 || (fastest && f > s.HSSpeed) || (!fastest && f < s.HSSpeed) {
+			s.HSSpeedIndex, s.HSSpeed = i, f
+		}
+	}
+	if fastest {
+		s.VSSpeedIndex, s.VSSpeed, err = c.GetFastestRecommendedVSSpeed()
+		if err != nil {
+			return s, err
+		}
+	} else {
+		n, err = c.GetNumberVSSpeeds()
+		if err != nil {
+			return s, err
+		}
+		for i := 0; i < n; i++ {
+			f, err := c.GetVSSpeed(i)
+			if err != nil {
+				return s, err
+			}
+			// VS speeds are the time per row; slower is longer
+			if i == 0 || f > s.VSSpeed {
+				s.VSSpeedIndex, s.VSSpeed = i, f
+			}
+		}
+	}
+	// output amplifier 0, as in GetHSSpeed
+	err = c.SetHSSpeedIndex(0, s.HSSpeedIndex)
+	if err != nil {
+		return s, err
+	}
+	return s, c.SetVSSpeed(s.VSSpeedIndex)
+}
+
 // SetVSAmplitudeIndex sets the VS Amplitude by index
 func (c *Camera) SetVSAmplitudeIndex(idx int) error {
 	errCode := uint(C.SetVSAmplitude(C.int(idx)))
@@ -343,7 +526,7 @@ func (c *Camera) SetVSAmplitude(v string) error {
 // 2. When closing down the program via ShutDown, you must ensure that the
 //    temperature of the detector is above -20C, otherwise calling ShutDown
 //    while the detector is still cooled will cause the temperature to rise
-//    faster than certified.
+//    faster than certified.  SafeShutDown does this.
 func (c *Camera) SetCooling(b bool) error {
 	var cerr C.uint
 	if b {
@@ -354,11 +537,14 @@ func (c *Camera) SetCooling(b bool) error {
 	return Error(uint(cerr))
 }
 
-// GetCooling gets if the cooler is currently engaged
+// GetCooling gets if the cooler is currently engaged.
+// Transient errors are retried according to Retry
 func (c *Camera) GetCooling() (bool, error) {
 	var ret C.int
-	errCode := uint(C.IsCoolerOn(&ret))
-	return int(ret) == 1, Error(errCode)
+	err := retry(func() error {
+		return Error(uint(C.IsCoolerOn(&ret)))
+	})
+	return int(ret) == 1, err
 }
 
 // GetTemperatureRange gets the valid range of temperatures
@@ -370,16 +556,18 @@ func (c *Camera) GetTemperatureRange() (int, int, error) {
 	return int(min), int(max), Error(errCode)
 }
 
-// GetTemperature gets the current temperature in degrees celcius.  The real type is int, but we use float for SD3 compatibility
+// GetTemperature gets the current temperature in degrees celcius.  The real type is int, but we use float for SD3 compatibility.
+// Transient errors, such as during a temperature cycle, are retried according to Retry
 func (c *Camera) GetTemperature() (float64, error) {
 	var temp C.int
-	errCode := uint(C.GetTemperature(&temp))
-	err := Error(errCode)
-	ret := float64(int(temp))
-	if BeneignThermal(err) {
-		return ret, nil
-	}
-	return ret, err
+	err := retry(func() error {
+		err := Error(uint(C.GetTemperature(&temp)))
+		if BeneignThermal(err) {
+			return nil
+		}
+		return err
+	})
+	return float64(int(temp)), err
 }
 
 // SetTemperatureSetpoint assigns a setpoint to the camera's TEC
@@ -437,6 +625,29 @@ func (c *Camera) GetTemperatureStatus() (string, error) {
 	return "", errors.New("andor/sdk2: GetTemperatureStatus not implemented on iXON EMCCD")
 }
 
+// GetTemperatureDiagnostics reads the sensor temperature and setpoint.  The
+// SDK does not report a cooling status, and the setpoint is continuous, so
+// Status and Options are left empty
+func (c *Camera) GetTemperatureDiagnostics() (camera.TemperatureDiagnostics, error) {
+	var diag camera.TemperatureDiagnostics
+	t, err := c.GetTemperature()
+	if err != nil {
+		return diag, err
+	}
+	setpt, err := c.GetTemperatureSetpoint()
+	if err != nil {
+		return diag, err
+	}
+	sp, err := strconv.ParseFloat(setpt, 64)
+	if err != nil {
+		return diag, fmt.Errorf("unable to parse temperature setpoint %q: %w", setpt, err)
+	}
+	diag.Temperature = t
+	diag.Setpoint = sp
+	diag.Deviation = t - sp
+	return diag, nil
+}
+
 // SetFan allows the fan to be turned on or off.
 // this is not a 1:1 mimic of SDk2, since it is binary
 // on (HIGH) or off (OFF)
@@ -594,10 +805,12 @@ func (c *Camera) AbortAcquisition() error {
 	return Error(errCode)
 }
 
-// StartAcquisition starts the camera acquiring charge for an image
+// StartAcquisition starts the camera acquiring charge for an image.
+// Transient errors are retried according to Retry
 func (c *Camera) StartAcquisition() error {
-	errCode := uint(C.StartAcquisition())
-	return Error(errCode)
+	return retry(func() error {
+		return Error(uint(C.StartAcquisition()))
+	})
 }
 
 // GetStatus gets the status while the camera is acquiring data
@@ -625,11 +838,18 @@ func (c *Camera) GetAcquiredData() ([]int32, error) {
 	return buf, Error(errCode)
 }
 
+// ErrAcquisitionTimeout is generated when WaitForAcquisition times out.  It
+// wraps camera.ErrAcquisitionTimeout, so it is served as 504 Gateway Timeout
+var ErrAcquisitionTimeout = fmt.Errorf("andor/sdk2: %w", camera.ErrAcquisitionTimeout)
+
 // WaitForAcquisition sleeps while waiting for the acquisition completed signal
 // from the SDK
 func (c *Camera) WaitForAcquisition(t time.Duration) error {
 	i64 := t.Milliseconds()
 	errCode := uint(C.WaitForAcquisitionTimeOut(C.int(i64)))
+	if errCode == 20024 { // DRV_NO_NEW_DATA
+		return fmt.Errorf("%w after %v", ErrAcquisitionTimeout, t)
+	}
 	return Error(errCode)
 }
 
@@ -942,7 +1162,44 @@ func (c *Camera) GetFrameSize() (int, int, error) {
 	return aoi.Width, aoi.Height, nil
 }
 
-// GetFrame returns a frame from the camera
+// FrameShutter modes coordinate the shutter with each frame taken by GetFrame
+const (
+	// FrameShutterManual leaves the shutter as set with SetShutter or
+	// SetShutterAuto
+	FrameShutterManual = "Manual"
+
+	// FrameShutterLight opens the shutter before each exposure and closes it
+	// after
+	FrameShutterLight = "Light"
+
+	// FrameShutterDark closes the shutter before each exposure, for darks
+	FrameShutterDark = "Dark"
+)
+
+// SetFrameShutter sets how GetFrame coordinates the shutter with exposures,
+// one of FrameShutterManual, FrameShutterLight, or FrameShutterDark.  Light
+// and Dark take manual control of the shutter, overriding SetShutterAuto
+func (c *Camera) SetFrameShutter(mode string) error {
+	switch mode {
+	case FrameShutterManual, FrameShutterLight, FrameShutterDark:
+		c.frameShutter = mode
+		return nil
+	default:
+		return fmt.Errorf("frame shutter mode %q is not one of %s, %s, %s",
+			mode, FrameShutterManual, FrameShutterLight, FrameShutterDark)
+	}
+}
+
+// GetFrameShutter returns how GetFrame coordinates the shutter with exposures
+func (c *Camera) GetFrameShutter() (string, error) {
+	if c.frameShutter == "" {
+		return FrameShutterManual, nil
+	}
+	return c.frameShutter, nil
+}
+
+// GetFrame returns a frame from the camera.  The shutter is opened, closed,
+// or left alone according to the FrameShutter mode
 func (c *Camera) GetFrame() (image.Image, error) {
 	ret := &image.Gray16{}
 	c.AbortAcquisition() // always clear out in case of dangling acq
@@ -957,6 +1214,29 @@ func (c *Camera) GetFrame() (image.Image, error) {
 		return ret, err
 	}
 
+	c.exposedShutter = nil
+	switch c.frameShutter {
+	case FrameShutterLight:
+		err = c.SetShutter(true)
+		// close the shutter again however the frame ends
+		defer c.SetShutter(false)
+	case FrameShutterDark:
+		err = c.SetShutter(false)
+	default:
+		// in auto mode the camera opens the shutter as it sees fit
+		if auto, aerr := c.GetShutterAuto(); aerr != nil || !auto {
+			c.exposedShutter = c.shutter
+		}
+	}
+	if err != nil {
+		return ret, fmt.Errorf("setting shutter for %s frame: %w", c.frameShutter, err)
+	}
+	if c.frameShutter == FrameShutterLight || c.frameShutter == FrameShutterDark {
+		open := c.frameShutter == FrameShutterLight
+		c.exposedShutter = &open
+	}
+
+	start := time.Now()
 	err = c.StartAcquisition()
 	if err != nil {
 		return ret, err
@@ -966,6 +1246,7 @@ func (c *Camera) GetFrame() (image.Image, error) {
 	if err != nil {
 		return ret, err
 	}
+	c.frameTiming = &camera.FrameTiming{Start: start, Duration: time.Since(start)}
 	stat, err := c.GetStatus()
 	// sometimes the SDK frees you from sleep even though the camera is still in acq
 	// this block will spam the camera for if it is acquiring for up (tExp + 15 seconds)
@@ -1144,6 +1425,14 @@ func (c *Camera) CollectHeaderMetadata() []fitsio.Card {
 		bin = camera.Binning{}
 	}
 	binS := bin.HxV()
+	shutmode, _ := c.GetFrameShutter()
+	shutter := "unknown"
+	if c.exposedShutter != nil {
+		shutter = "closed"
+		if *c.exposedShutter {
+			shutter = "open"
+		}
+	}
 
 	var metaerr string
 	if err != nil {
@@ -1160,7 +1449,7 @@ func (c *Camera) CollectHeaderMetadata() []fitsio.Card {
 		now.Minute(),
 		now.Second())
 
-	return []fitsio.Card{
+	cards := []fitsio.Card{
 		/* andor-http header format includes:
 		- header format tag
 		- server version
@@ -1192,6 +1481,8 @@ func (c *Camera) CollectHeaderMetadata() []fitsio.Card {
 
 		// exposure parameters
 		{Name: "EXPTIME", Value: texp.Seconds(), Comment: "exposure time, seconds"},
+		{Name: "SHUTTER", Value: shutter, Comment: "shutter state during the exposure"},
+		{Name: "SHUTMODE", Value: shutmode, Comment: "frame shutter mode, Manual, Light, or Dark"},
 
 		// thermal parameters
 		{Name: "FAN", Value: fan, Comment: "on (true) or off"},
@@ -1203,12 +1494,24 @@ func (c *Camera) CollectHeaderMetadata() []fitsio.Card {
 		{Name: "AOIW", Value: aoi.Width, Comment: "AOI width, px"},
 		{Name: "AOIH", Value: aoi.Height, Comment: "AOI height, px"},
 		{Name: "AOIB", Value: binS, Comment: "AOI Binning, HxV"}}
+	if c.frameTiming != nil {
+		cards = append(cards, camera.FrameTimingCards(*c.frameTiming)...)
+	}
+	return append(cards, camera.EnvironmentCards(c.Env)...)
 }
-func (c *Camera) SetFeature(feature string, v interface{}) error {
-	type fStrErr func(string) error
-	type fBoolErr func(bool) error
-	type fIntErr func(int) error
-	strFuncs := map[string]fStrErr{
+
+// GetFrameTiming returns when the last frame from GetFrame was taken
+func (c *Camera) GetFrameTiming() (camera.FrameTiming, error) {
+	if c.frameTiming == nil {
+		return camera.FrameTiming{}, ErrParameterNotSet{"FrameTiming"}
+	}
+	return *c.frameTiming, nil
+}
+
+// setters returns the functions SetFeature calls, by the type of their
+// argument.  Nothing is sent to the camera
+func (c *Camera) setters() (map[string]func(string) error, map[string]func(bool) error, map[string]func(int) error) {
+	strFuncs := map[string]func(string) error{
 		"VSAmplitude":         c.SetVSAmplitude,
 		"AcquisitionMode":     c.SetAcquisitionMode,
 		"ReadoutMode":         c.SetReadoutMode,
@@ -1216,8 +1519,9 @@ func (c *Camera) SetFeature(feature string, v interface{}) error {
 		"FilterMode":          c.SetFilterMode,
 		"TriggerMode":         c.SetTriggerMode,
 		"EMGainMode":          c.SetEMGainMode,
+		"FrameShutter":        c.SetFrameShutter,
 	}
-	boolFuncs := map[string]fBoolErr{
+	boolFuncs := map[string]func(bool) error{
 		"ShutterOpen":       c.SetShutter,
 		"ShutterAuto":       c.SetShutterAuto,
 		"FanOn":             c.SetFan,
@@ -1226,27 +1530,96 @@ func (c *Camera) SetFeature(feature string, v interface{}) error {
 		"BaselineClamp":     c.SetBaselineClamp,
 		"FrameTransferMode": c.SetFrameTransferMode,
 	}
-	intFuncs := map[string]fIntErr{
+	intFuncs := map[string]func(int) error{
 		"ADChannel":  c.SetADChannel,
 		"EMGain":     c.SetEMGain,
 		"HSSpeed":    c.SetHSSpeed,
 		"VSSpeed":    c.SetVSSpeed,
 		"PreAmpGain": c.SetPreAmpGain,
 	}
+	return strFuncs, boolFuncs, intFuncs
+}
 
-	var err error
+// SetFeature sets a feature by name, as used by Configure
+func (c *Camera) SetFeature(feature string, v interface{}) error {
+	strFuncs, boolFuncs, intFuncs := c.setters()
 	if f, ok := strFuncs[feature]; ok {
-		err = f(v.(string))
+		s, ok := v.(string)
+		if !ok {
+			return ErrFeatureType{Feature: feature, Expected: "string", Value: v}
+		}
+		return f(s)
 	} else if f, ok := boolFuncs[feature]; ok {
-		err = f(v.(bool))
+		b, ok := v.(bool)
+		if !ok {
+			return ErrFeatureType{Feature: feature, Expected: "bool", Value: v}
+		}
+		return f(b)
 	} else if f, ok := intFuncs[feature]; ok {
-		i := int(v.(float64))
-		err = f(i)
-	} else {
-		return fmt.Errorf("Feature [%s] with value [%v] not understood or unavailble", feature, v)
+		i, ok := toInt(v)
+		if !ok {
+			return ErrFeatureType{Feature: feature, Expected: "int", Value: v}
+		}
+		return f(i)
 	}
+	return fmt.Errorf("Feature [%s] with value [%v] not understood or unavailble", feature, v)
+}
 
-	return err
+// ValidateConfig checks that every key of settings is a feature SetFeature
+// can set and that its value has the right type, without communicating with
+// the camera.  Problems are returned like those from Configure, so a config
+// can be rejected before any of it is applied
+func (c *Camera) ValidateConfig(settings map[string]interface{}) error {
+	strFuncs, boolFuncs, intFuncs := c.setters()
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var errs []error
+	for _, k := range keys {
+		err := checkFeature(k, settings[k], strFuncs, boolFuncs, intFuncs)
+		if err != nil {
+			errs = append(errs, util.KeyedError{Key: k, Err: err})
+		}
+	}
+	return util.NewMultiError(errs)
+}
+
+// ValidateSteps checks steps as ValidateConfig does, so that they can be
+// rejected before any is applied by ConfigureOrdered.  The error names the
+// first offending step
+func (c *Camera) ValidateSteps(steps []camera.FeatureValue) error {
+	strFuncs, boolFuncs, intFuncs := c.setters()
+	for i, step := range steps {
+		err := checkFeature(step.Feature, step.Value, strFuncs, boolFuncs, intFuncs)
+		if err != nil {
+			return fmt.Errorf("step %d (%s=%v): %w", i, step.Feature, step.Value, err)
+		}
+	}
+	return nil
+}
+
+// checkFeature returns an error if feature is not among the setters or v is
+// not of the type its setter takes
+func checkFeature(feature string, v interface{}, strFuncs map[string]func(string) error,
+	boolFuncs map[string]func(bool) error, intFuncs map[string]func(int) error) error {
+	if _, ok := strFuncs[feature]; ok {
+		if _, ok := v.(string); !ok {
+			return ErrFeatureType{Feature: feature, Expected: "string", Value: v}
+		}
+	} else if _, ok := boolFuncs[feature]; ok {
+		if _, ok := v.(bool); !ok {
+			return ErrFeatureType{Feature: feature, Expected: "bool", Value: v}
+		}
+	} else if _, ok := intFuncs[feature]; ok {
+		if _, ok := toInt(v); !ok {
+			return ErrFeatureType{Feature: feature, Expected: "int", Value: v}
+		}
+	} else {
+		return ErrFeatureNotFound{Feature: feature}
+	}
+	return nil
 }
 
 // GetFeatureInfo For numerical features, it returns the min and max values.  For enum
@@ -1342,50 +1715,34 @@ func (c *Camera) GetFeatureIdx(index int, feature string) (interface{}, error) {
 	return nil, fmt.Errorf("Feature [%s] unknown", feature)
 }
 
-// Configure sets many values for the camera at once
+// Configure sets many values for the camera at once.
+//
+// The settings are applied in no particular order.  Some features must be set
+// before others (for example, ADChannel before HSSpeed); use ConfigureOrdered
+// when the order matters.
+//
+// All settings are attempted; the errors from any which fail, including
+// those with values of the wrong type, are merged and returned together as a
+// util.MultiError of util.KeyedErrors, whose Keys are the features which
+// failed.
 func (c *Camera) Configure(settings map[string]interface{}) error {
-	type fStrErr func(string) error
-	type fBoolErr func(bool) error
-	type fIntErr func(int) error
-	strFuncs := map[string]fStrErr{
-		"VSAmplitude":         c.SetVSAmplitude,
-		"AcquisitionMode":     c.SetAcquisitionMode,
-		"ReadoutMode":         c.SetReadoutMode,
-		"TemperatureSetpoint": c.SetTemperatureSetpoint,
-		"FilterMode":          c.SetFilterMode,
-		"TriggerMode":         c.SetTriggerMode,
-		"EMGainMode":          c.SetEMGainMode,
-	}
-	boolFuncs := map[string]fBoolErr{
-		"ShutterOpen":       c.SetShutter,
-		"ShutterAuto":       c.SetShutterAuto,
-		"FanOn":             c.SetFan,
-		"EMGainAdvanced":    c.SetEMAdvanced,
-		"SensorCooling":     c.SetCooling,
-		"BaselineClamp":     c.SetBaselineClamp,
-		"FrameTransferMode": c.SetFrameTransferMode,
-	}
-	intFuncs := map[string]fIntErr{
-		"ADChannel":  c.SetADChannel,
-		"EMGain":     c.SetEMGain,
-		"HSSpeed":    c.SetHSSpeed,
-		"VSSpeed":    c.SetVSSpeed,
-		"PreAmpGain": c.SetPreAmpGain,
-	}
 	var errs []error
 	for k, v := range settings {
-		var err error
-		if f, ok := strFuncs[k]; ok {
-			err = f(v.(string))
-		} else if f, ok := boolFuncs[k]; ok {
-			err = f(v.(bool))
-		} else if f, ok := intFuncs[k]; ok {
-			i := int(v.(float64))
-			err = f(i)
-		} else {
-			return fmt.Errorf("Configuration parameter %s with value %v not understood or unavailble", k, v)
+		if err := c.SetFeature(k, v); err != nil {
+			errs = append(errs, util.KeyedError{Key: k, Err: err})
+		}
+	}
+	return util.NewMultiError(errs)
+}
+
+// ConfigureOrdered calls SetFeature for each step in the order given,
+// stopping at the first error.  The error names the offending step.
+func (c *Camera) ConfigureOrdered(steps []camera.FeatureValue) error {
+	for i, step := range steps {
+		err := c.SetFeature(step.Feature, step.Value)
+		if err != nil {
+			return fmt.Errorf("step %d (%s=%v): %w", i, step.Feature, step.Value, err)
 		}
-		errs = append(errs, err)
 	}
-	return util.MergeErrors(errs)
+	return nil
 }
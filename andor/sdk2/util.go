@@ -3,7 +3,10 @@ package sdk2
 import (
 	"errors"
 	"fmt"
+	"math"
+	"time"
 
+	"github.com/cenkalti/backoff"
 	"github.com/nasa-jpl/golaborate/util"
 )
 
@@ -22,6 +25,23 @@ func (e ErrFeatureNotFound) Error() string {
 	return fmt.Sprintf("feature %s not found in Features map, see golab/andor/sdk3#Features for known features", e.Feature)
 }
 
+// ErrFeatureType is generated when a feature is set with a value of the wrong type
+type ErrFeatureType struct {
+	// Feature is the feature being set
+	Feature string
+
+	// Expected is the type the feature requires
+	Expected string
+
+	// Value is the value which was given
+	Value interface{}
+}
+
+// Error satisfies the error interface
+func (e ErrFeatureType) Error() string {
+	return fmt.Sprintf("feature %s expects a value of type %s, got %v of type %T", e.Feature, e.Expected, e.Value, e.Value)
+}
+
 type ErrParameterNotSet struct {
 	Parameter string
 }
@@ -196,8 +216,53 @@ var (
 		20002, // success
 		20073, // idle
 	}
+
+	// TransientErrorCodes is a sequence of error codes which a short retry
+	// may clear, such as the camera finishing an acquisition or a
+	// temperature cycle
+	TransientErrorCodes = []uint{
+		20072, // acquiring
+		20074, // temperature cycle
+	}
+
+	// Retry is the policy for retrying StartAcquisition and temperature
+	// reads which return one of TransientErrorCodes
+	Retry = RetryPolicy{Retries: 3, Interval: 50 * time.Millisecond}
 )
 
+// RetryPolicy controls how calls which return transient errors are retried
+type RetryPolicy struct {
+	// Retries is the number of retries after the first attempt.  Zero
+	// disables retrying
+	Retries int
+
+	// Interval is the wait before the first retry, which doubles with each
+	// retry after it
+	Interval time.Duration
+}
+
+// retry calls f until it succeeds, returns an error not in
+// TransientErrorCodes, or has been retried as many times as Retry allows
+func retry(f func() error) error {
+	op := func() error {
+		err := f()
+		if drv, ok := err.(DRVError); ok && util.UintSliceContains(TransientErrorCodes, uint(drv)) {
+			return err
+		}
+		if err != nil {
+			return backoff.Permanent(err)
+		}
+		return nil
+	}
+	b := &backoff.ExponentialBackOff{
+		InitialInterval:     Retry.Interval,
+		RandomizationFactor: 0,
+		Multiplier:          2,
+		MaxInterval:         10 * time.Second,
+		Clock:               backoff.SystemClock}
+	return backoff.Retry(op, backoff.WithMaxRetries(b, uint64(Retry.Retries)))
+}
+
 // HardwareVersion is a struct holding hardware versions
 type HardwareVersion struct {
 	// PCB version
@@ -314,6 +379,36 @@ func BeneignThermal(err error) bool {
 	return false
 }
 
+// toInt converts integral numeric types, and floats as produced by the JSON
+// and YAML decoders, to int.  Floats with a fractional part are not converted,
+// so that a value such as 1.5 is reported as ErrFeatureType rather than
+// truncated
+func toInt(v interface{}) (int, bool) {
+	switch vv := v.(type) {
+	case int:
+		return vv, true
+	case int32:
+		return int(vv), true
+	case int64:
+		return int(vv), true
+	case uint:
+		return int(vv), true
+	case uint32:
+		return int(vv), true
+	case uint64:
+		return int(vv), true
+	case float32:
+		return toInt(float64(vv))
+	case float64:
+		if vv != math.Trunc(vv) || math.IsInf(vv, 0) {
+			return 0, false
+		}
+		return int(vv), true
+	default:
+		return 0, false
+	}
+}
+
 // enumKeys returns the keys of an enum with the minimum number of allocations possible
 func enumKeys(e Enum) []string {
 	out := make([]string, 0, len(e))
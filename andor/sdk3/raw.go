@@ -3,7 +3,9 @@ package sdk3
 import (
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/cenkalti/backoff"
 	cwch "github.com/lordadamson/cgo.wchar"
 )
 
@@ -20,6 +22,15 @@ var (
 	// ErrBufferNotOnQueue is generated before a catastrophic side effect is triggered
 	ErrBufferNotOnQueue = errors.New("no buffer placed on queue, this error saves you from memory corruption")
 
+	// ErrInitTimeout is generated when the SDK does not finish initializing
+	// before a timeout, which in practice means it has deadlocked
+	ErrInitTimeout = errors.New("andor/sdk3: SDK deadlocked, InitializeLibrary did not complete before the timeout.  Power cycle the camera")
+
+	// ErrBufferSize is generated when ImageSizeBytes has changed since the
+	// buffers were allocated and they cannot be reallocated because the camera
+	// is acquiring
+	ErrBufferSize = errors.New("andor/sdk3: image buffers do not match ImageSizeBytes; stop the acquisition and call Allocate")
+
 	// ErrCodes is a map of error codes (ints) to error strings
 	ErrCodes = map[int]string{
 		0:  "AT_SUCCESS",
@@ -65,8 +76,64 @@ var (
 
 		100: "AT_ERR_HARDWARE_OVERFLOW",
 	}
+
+	// TransientErrorCodes is a slice of error codes which a short retry may
+	// clear, such as a communication fault while the camera is busy
+	TransientErrorCodes = []int{
+		10, // AT_ERR_CONNECTION
+		17, // AT_ERR_COMM
+	}
+
+	// Retry is the policy for retrying AcquisitionStart and feature reads
+	// which return one of TransientErrorCodes
+	Retry = RetryPolicy{Retries: 3, Interval: 50 * time.Millisecond}
 )
 
+// RetryPolicy controls how calls which return transient errors are retried
+type RetryPolicy struct {
+	// Retries is the number of retries after the first attempt.  Zero
+	// disables retrying
+	Retries int
+
+	// Interval is the wait before the first retry, which doubles with each
+	// retry after it
+	Interval time.Duration
+}
+
+// isTransient returns true if err is a DRVError with a code in
+// TransientErrorCodes
+func isTransient(err error) bool {
+	drv, ok := err.(DRVError)
+	if !ok {
+		return false
+	}
+	for _, code := range TransientErrorCodes {
+		if drv.code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retry calls f until it succeeds, returns an error not in
+// TransientErrorCodes, or has been retried as many times as Retry allows
+func retry(f func() error) error {
+	op := func() error {
+		err := f()
+		if err != nil && !isTransient(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+	b := &backoff.ExponentialBackOff{
+		InitialInterval:     Retry.Interval,
+		RandomizationFactor: 0,
+		Multiplier:          2,
+		MaxInterval:         10 * time.Second,
+		Clock:               backoff.SystemClock}
+	return backoff.Retry(op, backoff.WithMaxRetries(b, uint64(Retry.Retries)))
+}
+
 // DRVError represents a driver error
 type DRVError struct {
 	// code is a member of ErrCodes
@@ -128,6 +195,23 @@ func InitializeLibrary() error {
 	return Error(int(C.AT_InitialiseLibrary()))
 }
 
+// InitializeLibraryWithTimeout calls InitializeLibrary, returning
+// ErrInitTimeout if it does not complete within d.  The SDK is known to
+// deadlock during initialization; when that happens the call can never be
+// cancelled, so the caller should treat the error as fatal and exit.
+func InitializeLibraryWithTimeout(d time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- InitializeLibrary()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-time.After(d):
+		return ErrInitTimeout
+	}
+}
+
 // FinalizeLibrary calls the function of the same name in the Andor SDK
 func FinalizeLibrary() {
 	C.AT_FinaliseLibrary()
@@ -271,6 +355,49 @@ func GetBool(handle int, feature string) (bool, error) {
 	return atToBool(b), enrich(Error(errCode), feature)
 }
 
+// isFeature calls one of the SDK's AT_Is* functions, which report a property
+// of a feature as a bool
+func isFeature(handle int, feature string, fcn func(C.AT_H, *C.AT_WC, *C.AT_BOOL) C.int) (bool, error) {
+	cstr, err := cwch.FromGoString(feature)
+	if err != nil {
+		return false, err
+	}
+	str := (*C.AT_WC)(cstr.Pointer())
+	var b C.AT_BOOL
+	errCode := int(fcn(C.AT_H(handle), str, &b))
+	return atToBool(b), enrich(Error(errCode), feature)
+}
+
+// IsImplemented returns true if the camera implements a feature
+func IsImplemented(handle int, feature string) (bool, error) {
+	return isFeature(handle, feature, func(h C.AT_H, f *C.AT_WC, b *C.AT_BOOL) C.int {
+		return C.AT_IsImplemented(h, f, b)
+	})
+}
+
+// IsReadable returns true if a feature can currently be read
+func IsReadable(handle int, feature string) (bool, error) {
+	return isFeature(handle, feature, func(h C.AT_H, f *C.AT_WC, b *C.AT_BOOL) C.int {
+		return C.AT_IsReadable(h, f, b)
+	})
+}
+
+// IsReadOnly returns true if a feature can never be written
+func IsReadOnly(handle int, feature string) (bool, error) {
+	return isFeature(handle, feature, func(h C.AT_H, f *C.AT_WC, b *C.AT_BOOL) C.int {
+		return C.AT_IsReadOnly(h, f, b)
+	})
+}
+
+// IsWritable returns true if a feature can currently be written.  This
+// depends on the state of the camera, for example many features are not
+// writable while it is acquiring
+func IsWritable(handle int, feature string) (bool, error) {
+	return isFeature(handle, feature, func(h C.AT_H, f *C.AT_WC, b *C.AT_BOOL) C.int {
+		return C.AT_IsWritable(h, f, b)
+	})
+}
+
 // SetString sets the value of a string
 func SetString(handle int, feature, value string) error {
 	cstr, err := cwch.FromGoString(feature)
@@ -15,7 +15,12 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"log"
+	"math"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 	"unsafe"
@@ -66,10 +71,47 @@ func (e ErrFeatureNotFound) Error() string {
 	return fmt.Sprintf("feature %s not found in Features map, see golab/andor/sdk3#Features for known features", e.Feature)
 }
 
+// ErrAcquisitionTimeout is generated when WaitBuffer times out.  It wraps
+// camera.ErrAcquisitionTimeout, so it is served as 504 Gateway Timeout
+var ErrAcquisitionTimeout = fmt.Errorf("andor/sdk3: %w", camera.ErrAcquisitionTimeout)
+
+// ErrNotStabilised is generated when an acquisition is attempted while the
+// sensor temperature is not stabilised and the camera requires it to be
+type ErrNotStabilised struct {
+	// Status is the TemperatureStatus at the time of the acquisition
+	Status string
+}
+
+// Error satisfies the error interface
+func (e ErrNotStabilised) Error() string {
+	return fmt.Sprintf("andor/sdk3: sensor temperature is not stabilised (status %s) and stabilization is required for acquisition", e.Status)
+}
+
+// ErrFeatureNotWritable is generated when a feature is set which cannot be
+// written in the current state of the camera
+type ErrFeatureNotWritable struct {
+	// Feature is the feature being set
+	Feature string
+
+	// Reason is what prevents the feature from being written, if it could be
+	// determined
+	Reason string
+}
+
+// Error satisfies the error interface
+func (e ErrFeatureNotWritable) Error() string {
+	s := fmt.Sprintf("andor/sdk3: feature %s is not writable in the current state", e.Feature)
+	if e.Reason != "" {
+		s += ": " + e.Reason
+	}
+	return s
+}
+
 var (
 	// Features maps features to "types" without using the types pkg, due to C enums
 	Features = map[string]string{
 		// ints
+		"AccumulateCount":         "int",
 		"AccumulatedCount":        "int",
 		"AOIHBin":                 "int",
 		"AOIVBin":                 "int",
@@ -160,7 +202,14 @@ var (
 	}
 )
 
-// Camera represents a camera from SDK3
+// Camera represents a camera from SDK3.
+//
+// The embedded mutex serializes everything which touches the image buffers or
+// changes the acquisition state: GetFrame, Burst, SoftReset, Allocate,
+// QueueBuffer, WaitBuffer, Flush, Buffer, Close, SetAOI, SetBinning,
+// SetExposureTime, SetFeature, and the readout rate methods.  Plain getters
+// are not serialized.  The lock is not reentrant, so code holding it must use
+// the unexported variants (allocate, flush, ...) of the buffer methods.
 type Camera struct {
 	sync.Mutex
 
@@ -176,11 +225,58 @@ type Camera struct {
 	// Handle holds the int that points to a specific camera
 	Handle int
 
+	// Index is the index the camera was opened at
+	Index int
+
 	// UseSpinner indicates whether to run a spinner in the command line when
 	// taking video
 	UseSpinner bool
+
+	// RequireStabilized causes GetFrame and Burst to return ErrNotStabilised
+	// unless the TemperatureStatus is Stabilised
+	RequireStabilized bool
+
+	// BurstRetries is the number of frames Burst may drop to WaitBuffer
+	// timeouts before it fails.  Each dropped frame is logged and waited for
+	// again, so the burst still delivers the number of frames asked for.  It
+	// is read by Burst under the embedded mutex; use SetBurstRetries to
+	// change it on a camera in use
+	BurstRetries int
+
+	// Orientation is the clockwise rotation of the image from the origin,
+	// in degrees.  It is written to the ORIENT FITS card.  It is guarded by
+	// metaMu
+	Orientation float64
+
+	// PixelScale is the plate scale in arcseconds per pixel.  It is written
+	// to the PIXSCALE FITS card when nonzero.  It is guarded by metaMu
+	PixelScale float64
+
+	// Env, if not nil, is read for the TAMB and RHUMID FITS cards
+	Env camera.EnvironmentProvider
+
+	// enumMu guards enumOpts, the cached options of enum features, and
+	// enumGen, which counts the times the cache has been discarded
+	enumMu   sync.Mutex
+	enumOpts map[string][]string
+	enumGen  int
+
+	// requested holds the value last set for each of ModeFeatures, for
+	// CheckModeCompatibility.  It is guarded by the embedded mutex
+	requested map[string]interface{}
+
+	// metaMu guards Orientation, PixelScale, and frameTiming, so that header
+	// metadata can be collected while an acquisition holds the embedded mutex
+	metaMu sync.Mutex
+
+	// frameTiming is the timing of the last frame from GetFrame, nil if
+	// there has not been one
+	frameTiming *camera.FrameTiming
 }
 
+// DefaultOrientation is the orientation of a newly opened camera, in degrees
+const DefaultOrientation = -90
+
 // Open opens a connection to the camera.  Typically, a real camera
 // is index 0, and there are two simulator cameras at indices 1 and 2
 func Open(camIdx int) (*Camera, error) {
@@ -188,22 +284,147 @@ func Open(camIdx int) (*Camera, error) {
 	var hndle C.AT_H
 	err := enrich(Error(int(C.AT_Open(C.int(camIdx), &hndle))), "AT_OPEN")
 	c.Handle = int(hndle)
+	c.Index = camIdx
 	if err == nil {
 		c.Allocate()
 	}
 	c.UseSpinner = true
+	c.Orientation = DefaultOrientation
 	return &c, err
 }
 
 // Close closes a connection to the camera
 func (c *Camera) Close() error {
+	c.Lock()
+	defer c.Unlock()
 	return enrich(Error(int(C.AT_Close(C.AT_H(c.Handle)))), "AT_Close")
 }
 
+// Reopen closes the connection to the camera and opens the camera at camIdx
+// in its place, so that everything holding c drives the new camera.  If the
+// new camera cannot be opened, the old one is reopened.  The buffers are
+// reallocated, but no other settings are carried over
+func (c *Camera) Reopen(camIdx int) error {
+	c.Lock()
+	defer c.Unlock()
+	err := enrich(Error(int(C.AT_Close(C.AT_H(c.Handle)))), "AT_Close")
+	if err != nil {
+		return err
+	}
+	var hndle C.AT_H
+	err = enrich(Error(int(C.AT_Open(C.int(camIdx), &hndle))), "AT_OPEN")
+	if err != nil {
+		if Error(int(C.AT_Open(C.int(c.Index), &hndle))) == nil {
+			c.Handle = int(hndle)
+			c.allocate()
+		}
+		return err
+	}
+	c.Handle = int(hndle)
+	c.Index = camIdx
+	c.RefreshEnumCache()
+	return c.allocate()
+}
+
+// enumOptions returns the options of an enum feature, reading them from the
+// SDK only if they are not cached
+func (c *Camera) enumOptions(feature string) ([]string, error) {
+	c.enumMu.Lock()
+	opts, ok := c.enumOpts[feature]
+	gen := c.enumGen
+	c.enumMu.Unlock()
+	if ok {
+		return append([]string(nil), opts...), nil
+	}
+	opts, err := GetEnumStrings(c.Handle, feature)
+	if err != nil {
+		return opts, err
+	}
+	c.enumMu.Lock()
+	// if the cache was discarded while the SDK was read, opts may be stale
+	if gen == c.enumGen {
+		if c.enumOpts == nil {
+			c.enumOpts = make(map[string][]string)
+		}
+		c.enumOpts[feature] = opts
+	}
+	c.enumMu.Unlock()
+	return append([]string(nil), opts...), nil
+}
+
+// RefreshEnumCache discards the cached options of enum features, so that they
+// are read from the SDK again.  It is called when an enum feature is set with
+// SetFeature and when the camera is reopened; call it after changing modes
+// by other means
+func (c *Camera) RefreshEnumCache() {
+	c.enumMu.Lock()
+	defer c.enumMu.Unlock()
+	c.enumOpts = nil
+	c.enumGen++
+}
+
+// CameraInfo describes a camera found by the SDK
+type CameraInfo struct {
+	Index       int    `json:"index"`
+	Model       string `json:"model"`
+	Serial      string `json:"serial"`
+	IsSimulator bool   `json:"isSimulator"`
+}
+
+// IsSimulator returns true if the serial number belongs to one of the
+// software cameras in the SDK
+func IsSimulator(serial string) bool {
+	return strings.Contains(serial, "SFT")
+}
+
+// ListCameras describes each camera found by the SDK.  c, the camera in use,
+// is described from its own handle since a camera cannot be opened twice
+func (c *Camera) ListCameras() ([]CameraInfo, error) {
+	n, err := DeviceCount()
+	if err != nil {
+		return nil, err
+	}
+	c.Lock()
+	defer c.Unlock()
+	out := make([]CameraInfo, n)
+	for idx := range out {
+		hndle := c.Handle
+		if idx != c.Index {
+			var h C.AT_H
+			err = enrich(Error(int(C.AT_Open(C.int(idx), &h))), "AT_OPEN")
+			if err != nil {
+				return nil, err
+			}
+			hndle = int(h)
+		}
+		model, err := GetString(hndle, "CameraModel")
+		if err == nil {
+			out[idx].Serial, err = GetString(hndle, "SerialNumber")
+		}
+		if idx != c.Index {
+			C.AT_Close(C.AT_H(hndle))
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[idx].Index = idx
+		out[idx].Model = model
+		out[idx].IsSimulator = IsSimulator(out[idx].Serial)
+	}
+	return out, nil
+}
+
 // Allocate creates the buffer that will be populated by the SDK
 // it should be called at init, and whenever the AOI or encoding changes
 // AT_Flush is called to ensure stale buffers are not held by the SDK
 func (c *Camera) Allocate() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.allocate()
+}
+
+// allocate is Allocate without locking.  The caller must hold the lock.
+func (c *Camera) allocate() error {
 	sze, err := c.ImageSizeBytes()
 	if err != nil {
 		return err
@@ -215,7 +436,30 @@ func (c *Camera) Allocate() error {
 		}
 		c.bufs[i].Alloc(sze)
 	}
-	return c.Flush()
+	c.nextbuf = 0
+	c.recvdbuf = nil
+	return c.flush()
+}
+
+// SoftReset recovers the camera from a bad state, such as a dangling
+// acquisition or stuck buffers.  Acquisition is stopped, the SDK's buffer
+// queue is flushed, and the buffers are reallocated.  The new image size in
+// bytes is returned.  It is safe to call at any time, and waits for any
+// frame or burst in progress to finish.
+func (c *Camera) SoftReset() (int, error) {
+	c.Lock()
+	defer c.Unlock()
+	// AcquisitionStop errors if the camera is not acquiring, which is fine
+	IssueCommand(c.Handle, "AcquisitionStop")
+	err := c.flush()
+	if err != nil {
+		return 0, err
+	}
+	err = c.allocate()
+	if err != nil {
+		return 0, err
+	}
+	return c.ImageSizeBytes()
 }
 
 // ImageSizeBytes is the size of the image buffer in bytes.  This function
@@ -225,6 +469,42 @@ func (c *Camera) ImageSizeBytes() (int, error) {
 	return GetInt(c.Handle, "ImageSizeBytes")
 }
 
+// GetFrameInfo returns the layout of a frame in the buffer, as read from the
+// SDK.  This is what UnpadBuffer needs to strip the padding from a raw frame
+func (c *Camera) GetFrameInfo() (camera.FrameInfo, error) {
+	var (
+		info camera.FrameInfo
+		err  error
+	)
+	info.Width, err = c.GetAOIWidth()
+	if err != nil {
+		return info, err
+	}
+	info.Height, err = c.GetAOIHeight()
+	if err != nil {
+		return info, err
+	}
+	info.Stride, err = c.GetAOIStride()
+	if err != nil {
+		return info, err
+	}
+	info.BytesPerPixel, err = GetFloat(c.Handle, "BytesPerPixel")
+	if err != nil {
+		return info, err
+	}
+	info.PixelEncoding, err = GetEnumString(c.Handle, "PixelEncoding")
+	if err != nil {
+		return info, err
+	}
+	info.ImageSizeBytes, err = c.ImageSizeBytes()
+	if err != nil {
+		return info, err
+	}
+	rowBytes := int(math.Ceil(float64(info.Width) * info.BytesPerPixel))
+	info.Padded = info.Stride > rowBytes || info.ImageSizeBytes > info.Stride*info.Height
+	return info, nil
+}
+
 // GetSensorWidth gets the width of the sensor in pixels
 func (c *Camera) GetSensorWidth() (int, error) {
 	return GetInt(c.Handle, "SensorWidth")
@@ -270,6 +550,8 @@ func (c *Camera) GetAOITop() (int, error) {
 // calculated from the difference of the sensor dimensions and top-left if they
 // are zero
 func (c *Camera) SetAOI(aoi camera.AOI) error {
+	c.Lock()
+	defer c.Unlock()
 	var err error
 
 	err = SetInt(c.Handle, "AOIWidth", int64(aoi.Width))
@@ -291,7 +573,7 @@ func (c *Camera) SetAOI(aoi camera.AOI) error {
 	if err != nil {
 		return err
 	}
-	err = c.Allocate()
+	err = c.allocate()
 	return err
 }
 
@@ -325,12 +607,14 @@ func (c *Camera) GetBinning() (camera.Binning, error) {
 
 // SetBinning sets the AOIBinning feature
 func (c *Camera) SetBinning(b camera.Binning) error {
+	c.Lock()
+	defer c.Unlock()
 	str := b.HxV()
 	err := enrich(SetEnumString(c.Handle, "AOIBinning", str), "AOIBinning")
 	if err != nil {
 		return err
 	}
-	return c.Allocate()
+	return c.allocate()
 }
 
 // GetFirmwareVersion gets the firmware version of the camera
@@ -343,6 +627,27 @@ func (c *Camera) GetDriverVersion() (string, error) {
 	return GetString(c.Handle, "DriverVersion")
 }
 
+// GetComponentVersions returns the versions of this wrapper (WRAPVER), the
+// SDK, the driver, and the camera firmware.  Any which cannot be read are
+// left out and their errors returned together
+func (c *Camera) GetComponentVersions() (map[string]string, error) {
+	out := map[string]string{"wrapper": strconv.Itoa(WRAPVER)}
+	var errs []error
+	for k, f := range map[string]func() (string, error){
+		"sdk":      c.GetSDKVersion,
+		"driver":   c.GetDriverVersion,
+		"firmware": c.GetFirmwareVersion,
+	} {
+		v, err := f()
+		if err != nil {
+			errs = append(errs, util.KeyedError{Key: k, Err: err})
+			continue
+		}
+		out[k] = v
+	}
+	return out, util.MergeErrors(errs)
+}
+
 // GetModel returns the model string
 func (c *Camera) GetModel() (string, error) {
 	return GetString(c.Handle, "CameraModel")
@@ -355,13 +660,42 @@ func (c *Camera) GetSerialNumber() (string, error) {
 
 // QueueBuffer puts the Camera's internal buffer into the write queue for the SDK
 // only one buffer is supported in this wrapper, though the SDK supports
-// multiple buffers
+// multiple buffers.
+//
+// If ImageSizeBytes has changed since the buffers were allocated, for example
+// because AOIWidth was set with SetInt instead of SetAOI, the buffers are
+// reallocated first.  If the camera is acquiring they cannot be, and
+// ErrBufferSize is returned
 func (c *Camera) QueueBuffer() error {
+	c.Lock()
+	defer c.Unlock()
+	sze, err := c.ImageSizeBytes()
+	if err != nil {
+		return err
+	}
+	return c.queueBuffer(sze)
+}
+
+// queueBuffer is QueueBuffer without locking.  The caller must hold the lock.
+// sze is ImageSizeBytes, which callers queuing many buffers, such as Burst,
+// query once rather than for every buffer
+func (c *Camera) queueBuffer(sze int) error {
 	buf := c.bufs[c.nextbuf]
 	if !buf.allocated {
 		return fmt.Errorf("image buffer not allocated")
 	}
-	err := Error(int(C.AT_QueueBuffer(C.AT_H(c.Handle), buf.cptr, buf.cptrsize)))
+	var err error
+	if sze != buf.size {
+		if acq, err := GetBool(c.Handle, "CameraAcquiring"); err != nil || acq {
+			return fmt.Errorf("%w: buffers are %d bytes, frames are %d", ErrBufferSize, buf.size, sze)
+		}
+		err = c.allocate()
+		if err != nil {
+			return err
+		}
+		buf = c.bufs[c.nextbuf]
+	}
+	err = Error(int(C.AT_QueueBuffer(C.AT_H(c.Handle), buf.cptr, buf.cptrsize)))
 	err = enrich(err, "AT_QueueBuffer")
 	if err == nil {
 		// advance the buffer index and wrap if needed
@@ -373,13 +707,23 @@ func (c *Camera) QueueBuffer() error {
 // WaitBuffer waits for the camera to push a frame into the buffer
 // errors if Queue has not been called, on timeout, or on an SDK error
 func (c *Camera) WaitBuffer(timeout time.Duration) error {
+	c.Lock()
+	defer c.Unlock()
+	return c.waitBuffer(timeout)
+}
+
+// waitBuffer is WaitBuffer without locking.  The caller must hold the lock.
+func (c *Camera) waitBuffer(timeout time.Duration) error {
 	tout := C.uint(timeout.Milliseconds()) // 2020-03-04 nanoseconds/1e6 -> milliseconds, go1.13+
 	var (
 		size C.int
 		ptr  *C.AT_U8
 	)
-	err := Error(int(C.AT_WaitBuffer(C.AT_H(c.Handle), &ptr, &size, tout)))
-	err = enrich(err, "AT_WaitBuffer")
+	code := int(C.AT_WaitBuffer(C.AT_H(c.Handle), &ptr, &size, tout))
+	if code == 13 { // AT_ERR_TIMEDOUT
+		return fmt.Errorf("%w after %v", ErrAcquisitionTimeout, timeout)
+	}
+	err := enrich(Error(code), "AT_WaitBuffer")
 	if err == nil {
 		for i := 0; i < nbufs; i++ {
 			if c.bufs[i].cptr == ptr {
@@ -387,13 +731,20 @@ func (c *Camera) WaitBuffer(timeout time.Duration) error {
 				return nil
 			}
 		}
-		panic("recieved an unknown pointer from andor")
+		return errors.New("andor/sdk3: received an unknown pointer from andor")
 	}
 	return err
 }
 
 // Flush removes any pending buffers from the andor SDK's internal queue
 func (c *Camera) Flush() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.flush()
+}
+
+// flush is Flush without locking.  The caller must hold the lock.
+func (c *Camera) flush() error {
 	err := enrich(Error(int(C.AT_Flush(C.AT_H(c.Handle)))), "AT_Flush")
 	return err
 }
@@ -403,29 +754,44 @@ func (c *Camera) GetFrame() (image.Image, error) {
 	c.Lock()
 	defer c.Unlock()
 	var ret image.Gray16
+	err := c.checkStabilized()
+	if err != nil {
+		return &ret, err
+	}
 	// if we have to query hardware for exposure time, there may be an error
 	expT, err := c.GetExposureTime()
 	if err != nil {
 		return &ret, err
 	}
+	// the frame is not read out until every accumulation is exposed; not all
+	// cameras can accumulate
+	naccum, err := c.GetAccumulations()
+	if err != nil || naccum < 1 {
+		naccum = 1
+	}
 
-	c.Allocate()
+	c.allocate()
 
 	// injected here 2019-12-03, not writable on AcqStart happens because
 	// CameraAcquiring is true
 	IssueCommand(c.Handle, "AcquisitionStop") // gobble any errors from this
 
 	// do the big acquisition loop
-	err = c.QueueBuffer()
+	sze, err := c.ImageSizeBytes()
+	if err != nil {
+		return &ret, err
+	}
+	err = c.queueBuffer(sze)
 	if err != nil {
 		return &ret, err
 	}
 
-	err = IssueCommand(c.Handle, "AcquisitionStart")
+	start := time.Now()
+	err = retry(func() error { return IssueCommand(c.Handle, "AcquisitionStart") })
 	if err != nil {
 		return &ret, err
 	}
-	err = c.WaitBuffer(expT + 3*time.Second)
+	err = c.waitBuffer(time.Duration(naccum)*expT + 3*time.Second)
 	if err != nil {
 		err2 := IssueCommand(c.Handle, "AcquisitionStop")
 		if err2 != nil {
@@ -433,11 +799,14 @@ func (c *Camera) GetFrame() (image.Image, error) {
 		}
 		return &ret, err
 	}
+	c.metaMu.Lock()
+	c.frameTiming = &camera.FrameTiming{Start: start, Duration: time.Since(start)}
+	c.metaMu.Unlock()
 	err = IssueCommand(c.Handle, "AcquisitionStop")
 	if err != nil {
 		return &ret, err
 	}
-	err = c.Flush()
+	err = c.flush()
 	if err != nil {
 		return &ret, err
 	}
@@ -462,6 +831,10 @@ func (c *Camera) Burst(frames int, fps float64, ch chan<- image.Image) error {
 	defer c.Unlock()
 	spinning := c.UseSpinner
 	defer close(ch)
+	err := c.checkStabilized()
+	if err != nil {
+		return err
+	}
 	imgS, err := c.ImageSizeBytes()
 	if err != nil {
 		return err
@@ -484,7 +857,7 @@ func (c *Camera) Burst(frames int, fps float64, ch chan<- image.Image) error {
 		return err
 	}
 
-	c.Allocate()
+	c.allocate()
 
 	IssueCommand(c.Handle, "AcquisitionStop")
 
@@ -517,7 +890,7 @@ func (c *Camera) Burst(frames int, fps float64, ch chan<- image.Image) error {
 	waitT := expT + time.Second
 
 	// ensure buffer size is correct before bursting
-	c.Allocate()
+	c.allocate()
 	defer func() {
 		IssueCommand(c.Handle, "AcquisitionStop")
 		SetFloat(c.Handle, "FrameRate", prevFps)
@@ -540,21 +913,29 @@ func (c *Camera) Burst(frames int, fps float64, ch chan<- image.Image) error {
 		spinner.Start()
 	}
 
-	err = IssueCommand(c.Handle, "AcquisitionStart")
+	err = retry(func() error { return IssueCommand(c.Handle, "AcquisitionStart") })
 	if err != nil {
 		return err
 	}
 
+	dropped := 0
 	for idx := 0; idx < frames; idx++ {
-		err = c.QueueBuffer()
+		err = c.queueBuffer(imgS)
 		if err != nil {
 			return err
 		}
-		err := c.WaitBuffer(waitT)
+		err := c.waitBuffer(waitT)
+		// the buffer stays queued after a timeout, so it is waited on again
+		// rather than queued twice; it is filled by the next frame
+		for errors.Is(err, ErrAcquisitionTimeout) && dropped < c.BurstRetries {
+			dropped++
+			log.Printf("andor/sdk3: burst frame %d/%d timed out and was dropped, %d of %d retries used\n", idx+1, frames, dropped, c.BurstRetries)
+			err = c.waitBuffer(waitT)
+		}
 		if err != nil {
 			return err
 		}
-		buf := c.Buffer()
+		buf := c.buffer()
 		buf = UnpadBuffer(buf, stride, aoi.Width, aoi.Height)
 		ch <- &image.Gray16{Pix: buf, Stride: aoi.Width * 2, Rect: image.Rect(0, 0, aoi.Width, aoi.Height)}
 		if spinning {
@@ -565,7 +946,7 @@ func (c *Camera) Burst(frames int, fps float64, ch chan<- image.Image) error {
 }
 
 func (c *Camera) unpadBuffer() ([]byte, error) {
-	buf := c.Buffer()
+	buf := c.buffer()
 	stride, err := c.GetAOIStride()
 	if err != nil {
 		return []byte{}, err
@@ -590,8 +971,24 @@ func (c *Camera) GetExposureTime() (time.Duration, error) {
 	return dur, err
 }
 
+// GetExposureTimeLimits returns the shortest and longest exposure times the
+// camera allows in its present configuration
+func (c *Camera) GetExposureTimeLimits() (time.Duration, time.Duration, error) {
+	min, err := GetFloatMin(c.Handle, "ExposureTime")
+	if err != nil {
+		return 0, 0, err
+	}
+	max, err := GetFloatMax(c.Handle, "ExposureTime")
+	if err != nil {
+		return 0, 0, err
+	}
+	return time.Duration(min * 1e9), time.Duration(max * 1e9), nil
+}
+
 // SetExposureTime sets the exposure time as a duration
 func (c *Camera) SetExposureTime(d time.Duration) error {
+	c.Lock()
+	defer c.Unlock()
 	ts := d.Seconds()
 	return SetFloat(c.Handle, "ExposureTime", ts)
 }
@@ -606,15 +1003,21 @@ func (c *Camera) SetCooling(b bool) error {
 	return SetBool(c.Handle, "SensorCooling", b)
 }
 
-// GetTemperature gets the current temperature of the sensor in Celsius
+// GetTemperature gets the current temperature of the sensor in Celsius.
+// Transient errors are retried according to Retry
 func (c *Camera) GetTemperature() (float64, error) {
-	return GetFloat(c.Handle, "SensorTemperature")
+	var t float64
+	err := retry(func() (err error) {
+		t, err = GetFloat(c.Handle, "SensorTemperature")
+		return err
+	})
+	return t, err
 }
 
 // GetTemperatureSetpoints gets a list of strings representing the
 // temperatures the detector can currently be cooled to
 func (c *Camera) GetTemperatureSetpoints() ([]string, error) {
-	return GetEnumStrings(c.Handle, "TemperatureControl")
+	return c.enumOptions("TemperatureControl")
 }
 
 // GetTemperatureSetpoint gets the temp control setpoint as a string
@@ -628,6 +1031,103 @@ func (c *Camera) SetTemperatureSetpoint(s string) error {
 	return SetEnumString(c.Handle, "TemperatureControl", s)
 }
 
+// GetBaselineLevel returns the baseline offset of the sensor, in DN
+func (c *Camera) GetBaselineLevel() (int, error) {
+	return GetInt(c.Handle, "BaselineLevel")
+}
+
+// SetBaselineLevel sets the baseline offset of the sensor, in DN.
+// The value is checked against the feature's limits before it is sent
+func (c *Camera) SetBaselineLevel(level int) error {
+	min, err := GetIntMin(c.Handle, "BaselineLevel")
+	if err != nil {
+		return err
+	}
+	max, err := GetIntMax(c.Handle, "BaselineLevel")
+	if err != nil {
+		return err
+	}
+	if level < min || level > max {
+		return fmt.Errorf("andor/sdk3: BaselineLevel %d outside of limits [%d, %d]", level, min, max)
+	}
+	return SetInt(c.Handle, "BaselineLevel", int64(level))
+}
+
+// GetAccumulations returns the number of exposures the camera sums into
+// each frame before readout
+func (c *Camera) GetAccumulations() (int, error) {
+	return GetInt(c.Handle, "AccumulateCount")
+}
+
+// SetAccumulations sets the number of exposures the camera sums into each
+// frame before readout.  One disables accumulation.
+// The limits of the camera are checked before the value is sent.
+func (c *Camera) SetAccumulations(n int) error {
+	min, err := GetIntMin(c.Handle, "AccumulateCount")
+	if err != nil {
+		return err
+	}
+	max, err := GetIntMax(c.Handle, "AccumulateCount")
+	if err != nil {
+		return err
+	}
+	if n < min || n > max {
+		return fmt.Errorf("andor/sdk3: AccumulateCount %d outside of limits [%d, %d]", n, min, max)
+	}
+	return SetInt(c.Handle, "AccumulateCount", int64(n))
+}
+
+// GetDiagnostics reads the acquisition counters, which show if frames are
+// being dropped during high speed acquisitions
+func (c *Camera) GetDiagnostics() (camera.AcquisitionDiagnostics, error) {
+	var (
+		diag camera.AcquisitionDiagnostics
+		err  error
+	)
+	for _, f := range []struct {
+		feature string
+		dst     *int
+	}{
+		{"AccumulatedCount", &diag.AccumulatedCount},
+		{"FrameCount", &diag.FrameCount},
+		{"BufferOverflowEvent", &diag.BufferOverflowEvent},
+		{"EventsMissedEvent", &diag.EventsMissedEvent},
+	} {
+		*f.dst, err = GetInt(c.Handle, f.feature)
+		if err != nil {
+			return diag, err
+		}
+	}
+	return diag, nil
+}
+
+// GetTemperatureDiagnostics reads the sensor temperature, setpoint, cooling
+// status, and the TemperatureControl options in one call
+func (c *Camera) GetTemperatureDiagnostics() (camera.TemperatureDiagnostics, error) {
+	var diag camera.TemperatureDiagnostics
+	t, err := c.GetTemperature()
+	if err != nil {
+		return diag, err
+	}
+	setpt, err := c.GetTemperatureSetpoint()
+	if err != nil {
+		return diag, err
+	}
+	sp, err := strconv.ParseFloat(strings.TrimSpace(setpt), 64)
+	if err != nil {
+		return diag, fmt.Errorf("unable to parse temperature setpoint %q: %w", setpt, err)
+	}
+	diag.Temperature = t
+	diag.Setpoint = sp
+	diag.Deviation = t - sp
+	diag.Status, err = c.GetTemperatureStatus()
+	if err != nil {
+		return diag, err
+	}
+	diag.Options, err = c.GetTemperatureSetpoints()
+	return diag, err
+}
+
 // GetTemperatureStatus gets the current status of sensor cooling.  One of:
 // - Cooler Off
 // - Stabilised
@@ -635,8 +1135,104 @@ func (c *Camera) SetTemperatureSetpoint(s string) error {
 // - Drift
 // - Not Stabilised
 // - Fault
+//
+// Transient errors are retried according to Retry
 func (c *Camera) GetTemperatureStatus() (string, error) {
-	return GetEnumString(c.Handle, "TemperatureStatus")
+	var status string
+	err := retry(func() (err error) {
+		status, err = GetEnumString(c.Handle, "TemperatureStatus")
+		return err
+	})
+	return status, err
+}
+
+// SetRequireStabilized sets whether acquisitions require the sensor
+// temperature to be stabilised
+func (c *Camera) SetRequireStabilized(b bool) error {
+	c.Lock()
+	defer c.Unlock()
+	c.RequireStabilized = b
+	return nil
+}
+
+// GetRequireStabilized returns true if acquisitions require the sensor
+// temperature to be stabilised
+func (c *Camera) GetRequireStabilized() (bool, error) {
+	c.Lock()
+	defer c.Unlock()
+	return c.RequireStabilized, nil
+}
+
+// SetBurstRetries sets the number of frames Burst may drop to WaitBuffer
+// timeouts before it fails
+func (c *Camera) SetBurstRetries(n int) error {
+	if n < 0 {
+		return fmt.Errorf("burst retries must be non-negative, got %d", n)
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.BurstRetries = n
+	return nil
+}
+
+// GetBurstRetries returns the number of frames Burst may drop to WaitBuffer
+// timeouts before it fails
+func (c *Camera) GetBurstRetries() (int, error) {
+	c.Lock()
+	defer c.Unlock()
+	return c.BurstRetries, nil
+}
+
+// SetOrientation sets the clockwise rotation of the image from the origin,
+// in degrees, used in the FITS header
+func (c *Camera) SetOrientation(deg float64) error {
+	c.metaMu.Lock()
+	defer c.metaMu.Unlock()
+	c.Orientation = deg
+	return nil
+}
+
+// GetOrientation returns the clockwise rotation of the image from the origin,
+// in degrees
+func (c *Camera) GetOrientation() (float64, error) {
+	c.metaMu.Lock()
+	defer c.metaMu.Unlock()
+	return c.Orientation, nil
+}
+
+// SetPixelScale sets the plate scale in arcseconds per pixel used in the
+// FITS header.  Zero omits the card
+func (c *Camera) SetPixelScale(arcsecPerPx float64) error {
+	if arcsecPerPx < 0 {
+		return fmt.Errorf("pixel scale must be non-negative, got %f", arcsecPerPx)
+	}
+	c.metaMu.Lock()
+	defer c.metaMu.Unlock()
+	c.PixelScale = arcsecPerPx
+	return nil
+}
+
+// GetPixelScale returns the plate scale in arcseconds per pixel
+func (c *Camera) GetPixelScale() (float64, error) {
+	c.metaMu.Lock()
+	defer c.metaMu.Unlock()
+	return c.PixelScale, nil
+}
+
+// checkStabilized returns ErrNotStabilised if RequireStabilized is set and the
+// sensor is not stabilised.  The caller must hold the lock.
+func (c *Camera) checkStabilized() error {
+	if !c.RequireStabilized {
+		return nil
+	}
+	stat, err := c.GetTemperatureStatus()
+	if err != nil {
+		return err
+	}
+	if stat != "Stabilised" {
+		return ErrNotStabilised{Status: stat}
+	}
+	return nil
 }
 
 // GetFan gets if the fan is currently on
@@ -662,6 +1258,17 @@ func (c *Camera) SetFan(b bool) error {
 //
 // may have undefined behavior if camera is writing while you read
 func (c *Camera) Buffer() []byte {
+	c.Lock()
+	defer c.Unlock()
+	return c.buffer()
+}
+
+// buffer is Buffer without locking.  The caller must hold the lock.
+// It returns nil if no frame has been received since the buffers were allocated.
+func (c *Camera) buffer() []byte {
+	if c.recvdbuf == nil {
+		return nil
+	}
 	// this function is needed because we use a buffer of uint64 to
 	// guarantee 8-byte alignment.  We want the underlying data
 	var buf []byte
@@ -704,6 +1311,11 @@ func (c *Camera) CollectHeaderMetadata() []fitsio.Card {
 	temp, err := c.GetTemperature()
 	bin, err := c.GetBinning()
 	binS := bin.HxV()
+	orient, _ := c.GetOrientation()
+	pxscale, _ := c.GetPixelScale()
+	// not every camera implements BaselineLevel, so omit it instead of
+	// reporting a METAERR
+	baseline, blerr := c.GetBaselineLevel()
 
 	var metaerr string
 	if err != nil {
@@ -720,7 +1332,7 @@ func (c *Camera) CollectHeaderMetadata() []fitsio.Card {
 		now.Minute(),
 		now.Second())
 
-	return []fitsio.Card{
+	cards := []fitsio.Card{
 		/* andor-http header format includes:
 		- header format tag
 		- server version
@@ -753,7 +1365,7 @@ func (c *Camera) CollectHeaderMetadata() []fitsio.Card {
 		{Name: "DATE", Value: ts}, // timestamp is standard and does not require comment
 
 		// orientation
-		{Name: "ORIENT", Value: -90, Comment: "cw rotation from origin index +row +col"},
+		{Name: "ORIENT", Value: orient, Comment: "cw rotation from origin index +row +col"},
 
 		// exposure parameters
 		{Name: "EXPTIME", Value: texp.Seconds(), Comment: "exposure time, seconds"},
@@ -769,33 +1381,64 @@ func (c *Camera) CollectHeaderMetadata() []fitsio.Card {
 		{Name: "AOIW", Value: aoi.Width, Comment: "AOI width, px"},
 		{Name: "AOIH", Value: aoi.Height, Comment: "AOI height, px"},
 		{Name: "AOIB", Value: binS, Comment: "AOI Binning, HxV"}}
+	if pxscale != 0 {
+		cards = append(cards, fitsio.Card{Name: "PIXSCALE", Value: pxscale, Comment: "plate scale, arcsec/px"})
+	}
+	if blerr == nil {
+		cards = append(cards, fitsio.Card{Name: "BASELINE", Value: baseline, Comment: "baseline offset, DN"})
+	}
+	if naccum, err := c.GetAccumulations(); err == nil {
+		cards = append(cards, fitsio.Card{Name: "NACCUM", Value: naccum, Comment: "exposures summed on the camera"})
+	}
+	c.metaMu.Lock()
+	ft := c.frameTiming
+	c.metaMu.Unlock()
+	if ft != nil {
+		cards = append(cards, camera.FrameTimingCards(*ft)...)
+	}
+	return append(cards, camera.EnvironmentCards(c.Env)...)
 }
 
-// Configure takes a map of interfaces and calls Set_xxx for each, where
-// xxx is Bool, Int, etc.
+// GetFrameTiming returns when the last frame from GetFrame was taken
+func (c *Camera) GetFrameTiming() (camera.FrameTiming, error) {
+	c.metaMu.Lock()
+	defer c.metaMu.Unlock()
+	if c.frameTiming == nil {
+		return camera.FrameTiming{}, errors.New("andor/sdk3: no frame has been taken")
+	}
+	return *c.frameTiming, nil
+}
+
+// Configure takes a map of features to values and calls SetFeature for each.
+//
+// The settings are applied in no particular order.  Some features must be set
+// before others (for example, AOIBinning before AOIWidth and AOIHeight); use
+// ConfigureOrdered when the order matters.
+//
+// All settings are attempted; the errors from any which fail, including
+// unknown features and values of the wrong type, are merged and returned
+// together as a util.MultiError of util.KeyedErrors, whose Keys are the
+// features which failed.
 func (c *Camera) Configure(settings map[string]interface{}) error {
 	var errs []error
 	for k, v := range settings {
-		typs := Features[k]
-		var err error
-		err = nil
-		switch typs {
-		case "int":
-			// values will unmarshal to unsized ints, assert to int then cast
-			// to i64
-			err = SetInt(c.Handle, k, int64(v.(int)))
-		case "float":
-			err = SetFloat(c.Handle, k, v.(float64))
-		case "bool":
-			err = SetBool(c.Handle, k, v.(bool))
-		case "enum":
-			err = SetEnumString(c.Handle, k, v.(string))
-		default:
-			err = fmt.Errorf("value %v for key %s is not of type int, float64, bool, or string", v, k)
+		if err := c.SetFeature(k, v); err != nil {
+			errs = append(errs, util.KeyedError{Key: k, Err: err})
 		}
-		errs = append(errs, err)
 	}
-	return util.MergeErrors(errs)
+	return util.NewMultiError(errs)
+}
+
+// ConfigureOrdered calls SetFeature for each step in the order given,
+// stopping at the first error.  The error names the offending step.
+func (c *Camera) ConfigureOrdered(steps []camera.FeatureValue) error {
+	for i, step := range steps {
+		err := c.SetFeature(step.Feature, step.Value)
+		if err != nil {
+			return fmt.Errorf("step %d (%s=%v): %w", i, step.Feature, step.Value, err)
+		}
+	}
+	return nil
 }
 
 // GetFeature implements generichttp/camera.FeatureManipulator
@@ -812,20 +1455,29 @@ func (c *Camera) GetFeature(feature string) (interface{}, error) {
 	if !ok {
 		return nil, ErrFeatureNotFound{feature}
 	}
-	switch t {
-	case "int":
-		return GetInt(c.Handle, feature)
-	case "float":
-		return GetFloat(c.Handle, feature)
-	case "bool":
-		return GetBool(c.Handle, feature)
-	case "string":
-		return GetString(c.Handle, feature)
-	case "enum":
-		return GetEnumString(c.Handle, feature)
-	default:
-		return nil, fmt.Errorf("andor/sdk3: feature %s was recognized, but its type of %s was not", feature, t)
+	var v interface{}
+	err := retry(func() error {
+		var err error
+		switch t {
+		case "int":
+			v, err = GetInt(c.Handle, feature)
+		case "float":
+			v, err = GetFloat(c.Handle, feature)
+		case "bool":
+			v, err = GetBool(c.Handle, feature)
+		case "string":
+			v, err = GetString(c.Handle, feature)
+		case "enum":
+			v, err = GetEnumString(c.Handle, feature)
+		default:
+			err = fmt.Errorf("andor/sdk3: feature %s was recognized, but its type of %s was not", feature, t)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return v, nil
 }
 
 // GetFeatureInfo retrieves information about a feature which varies based on its type
@@ -873,7 +1525,7 @@ func (c *Camera) GetFeatureInfo(feature string) (map[string]interface{}, error)
 		ret["maxLength"] = maxlen
 	case "enum":
 		ret["type"] = "enum"
-		opts, err := GetEnumStrings(c.Handle, feature)
+		opts, err := c.enumOptions(feature)
 		if err != nil {
 			return ret, err
 		}
@@ -896,12 +1548,19 @@ func (c *Camera) GetFeatureInfo(feature string) (map[string]interface{}, error)
 //
 // This function will return an error if the feature is not known
 // or the type is mismatched, with the exception of integral float64s
-// for integer features or integers for float64s
+// for integer features or integers for float64s.  If the feature cannot be
+// written in the current state of the camera, ErrFeatureNotWritable is
+// returned without attempting to set it
 func (c *Camera) SetFeature(feature string, v interface{}) error {
 	t, ok := Features[feature]
 	if !ok {
 		return ErrFeatureNotFound{feature}
 	}
+	c.Lock()
+	defer c.Unlock()
+	if err := c.checkWritable(feature); err != nil {
+		return err
+	}
 	switch t {
 	case "string":
 		vv, ok := v.(string)
@@ -914,13 +1573,23 @@ func (c *Camera) SetFeature(feature string, v interface{}) error {
 		if !ok {
 			return fmt.Errorf("andor/sdk3: feature %s set with type %T, expected %s", feature, v, t)
 		}
-		return SetEnumString(c.Handle, feature, vv)
+		err := SetEnumString(c.Handle, feature, vv)
+		if err == nil {
+			// the options of other enums may depend on this one
+			c.RefreshEnumCache()
+			c.noteRequested(feature, vv)
+		}
+		return err
 	case "bool":
 		vv, ok := v.(bool)
 		if !ok {
 			return fmt.Errorf("andor/sdk3: feature %s set with type %T, expected %s", feature, v, t)
 		}
-		return SetBool(c.Handle, feature, vv)
+		err := SetBool(c.Handle, feature, vv)
+		if err == nil {
+			c.noteRequested(feature, vv)
+		}
+		return err
 	case "int":
 		switch vv := v.(type) {
 		case int:
@@ -984,6 +1653,176 @@ func (c *Camera) SetFeature(feature string, v interface{}) error {
 	}
 }
 
+// checkFeatureValue returns an error if v is not a value SetFeature accepts
+// for feature
+func checkFeatureValue(feature string, v interface{}) error {
+	t, ok := Features[feature]
+	if !ok {
+		return ErrFeatureNotFound{feature}
+	}
+	switch t {
+	case "string", "enum":
+		_, ok = v.(string)
+	case "bool":
+		_, ok = v.(bool)
+	case "int", "float":
+		switch v.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			ok = true
+		default:
+			ok = false
+		}
+	default:
+		return fmt.Errorf("andor/sdk3: feature %s is a %s, which cannot be set", feature, t)
+	}
+	if !ok {
+		return fmt.Errorf("andor/sdk3: feature %s set with type %T, expected %s", feature, v, t)
+	}
+	return nil
+}
+
+// ValidateConfig checks that every key of settings is a known feature and
+// that its value has a type SetFeature accepts, without communicating with the
+// camera.  Problems are returned like those from Configure, so a config can
+// be rejected before any of it is applied
+func (c *Camera) ValidateConfig(settings map[string]interface{}) error {
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var errs []error
+	for _, k := range keys {
+		if err := checkFeatureValue(k, settings[k]); err != nil {
+			errs = append(errs, util.KeyedError{Key: k, Err: err})
+		}
+	}
+	return util.NewMultiError(errs)
+}
+
+// readoutRateInfo reads the consequences of the current PixelReadoutRate.
+// The caller must hold the lock.
+func (c *Camera) readoutRateInfo() (camera.ReadoutRateInfo, error) {
+	var (
+		info camera.ReadoutRateInfo
+		err  error
+	)
+	info.PixelReadoutRate, err = GetEnumString(c.Handle, "PixelReadoutRate")
+	if err != nil {
+		return info, err
+	}
+	info.MaxFrameRate, err = GetFloatMax(c.Handle, "FrameRate")
+	if err != nil {
+		return info, err
+	}
+	info.ReadoutTime, err = GetFloat(c.Handle, "ReadoutTime")
+	return info, err
+}
+
+// PreviewPixelReadoutRate reports the maximum frame rate and readout time
+// the camera would have at the given PixelReadoutRate, without changing it.
+// The readout rate is briefly changed and restored, so this must not be called
+// during an acquisition; it is serialized with GetFrame and Burst.
+func (c *Camera) PreviewPixelReadoutRate(rate string) (camera.ReadoutRateInfo, error) {
+	c.Lock()
+	defer c.Unlock()
+	prev, err := GetEnumString(c.Handle, "PixelReadoutRate")
+	if err != nil {
+		return camera.ReadoutRateInfo{}, err
+	}
+	err = SetEnumString(c.Handle, "PixelReadoutRate", rate)
+	if err != nil {
+		return camera.ReadoutRateInfo{}, err
+	}
+	info, err := c.readoutRateInfo()
+	err2 := SetEnumString(c.Handle, "PixelReadoutRate", prev)
+	if err != nil {
+		return info, err
+	}
+	return info, err2
+}
+
+// SetPixelReadoutRate sets the PixelReadoutRate and reports the resulting
+// maximum frame rate and readout time
+func (c *Camera) SetPixelReadoutRate(rate string) (camera.ReadoutRateInfo, error) {
+	c.Lock()
+	defer c.Unlock()
+	err := SetEnumString(c.Handle, "PixelReadoutRate", rate)
+	if err != nil {
+		return camera.ReadoutRateInfo{}, err
+	}
+	c.noteRequested("PixelReadoutRate", rate)
+	return c.readoutRateInfo()
+}
+
+// ModeFeatures are the features which constrain one another's valid values.
+// On the Neo, for example, Overlap is only available with some combinations
+// of ElectronicShutteringMode and PixelReadoutRate, and the SDK may change one
+// of them without error when another is set
+var ModeFeatures = []string{"ElectronicShutteringMode", "PixelReadoutRate", "Overlap"}
+
+// ModeDiscrepancy is a mode feature whose value is not the one last set
+type ModeDiscrepancy struct {
+	Feature   string      `json:"feature"`
+	Requested interface{} `json:"requested"`
+	Actual    interface{} `json:"actual"`
+}
+
+func (m ModeDiscrepancy) String() string {
+	return fmt.Sprintf("%s was set to %v but is %v", m.Feature, m.Requested, m.Actual)
+}
+
+// noteRequested records the value a mode feature was set to.  The caller
+// must hold the lock
+func (c *Camera) noteRequested(feature string, v interface{}) {
+	for _, f := range ModeFeatures {
+		if f == feature {
+			if c.requested == nil {
+				c.requested = map[string]interface{}{}
+			}
+			c.requested[feature] = v
+			return
+		}
+	}
+}
+
+// CheckModeCompatibility reads back each of ModeFeatures that has been set
+// through SetFeature or SetPixelReadoutRate and returns those the camera no
+// longer reports the requested value for, such as Global shuttering when
+// Rolling was requested.  Call it after Configure; an empty result means
+// nothing was silently coerced
+func (c *Camera) CheckModeCompatibility() ([]ModeDiscrepancy, error) {
+	c.Lock()
+	defer c.Unlock()
+	var (
+		out  []ModeDiscrepancy
+		errs []error
+	)
+	for _, f := range ModeFeatures {
+		req, ok := c.requested[f]
+		if !ok {
+			continue
+		}
+		var (
+			actual interface{}
+			err    error
+		)
+		if Features[f] == "bool" {
+			actual, err = GetBool(c.Handle, f)
+		} else {
+			actual, err = GetEnumString(c.Handle, f)
+		}
+		if err != nil {
+			errs = append(errs, util.KeyedError{Key: f, Err: err})
+			continue
+		}
+		if actual != req {
+			out = append(out, ModeDiscrepancy{Feature: f, Requested: req, Actual: actual})
+		}
+	}
+	return out, util.NewMultiError(errs)
+}
+
 // Features returns a map of feature names to their types, as strings
 // the types map as:
 //
@@ -1001,6 +1840,42 @@ func (c *Camera) Features() (map[string]string, error) {
 	return Features, nil
 }
 
+// checkWritable returns ErrFeatureNotWritable if a feature cannot be written
+// in the current state of the camera, with the reason if it can be determined
+func (c *Camera) checkWritable(feature string) error {
+	writable, err := IsWritable(c.Handle, feature)
+	if err != nil || writable {
+		return err
+	}
+	e := ErrFeatureNotWritable{Feature: feature}
+	if impl, err := IsImplemented(c.Handle, feature); err == nil && !impl {
+		e.Reason = "it is not implemented by this camera"
+	} else if ro, err := IsReadOnly(c.Handle, feature); err == nil && ro {
+		e.Reason = "it is read only"
+	} else if acq, err := GetBool(c.Handle, "CameraAcquiring"); err == nil && acq {
+		e.Reason = "the camera is acquiring"
+	}
+	return e
+}
+
+// GetFeatureAccess returns whether a feature can currently be read and
+// written.  Features the camera does not implement are neither
+func (c *Camera) GetFeatureAccess(feature string) (bool, bool, error) {
+	if _, ok := Features[feature]; !ok {
+		return false, false, ErrFeatureNotFound{feature}
+	}
+	impl, err := IsImplemented(c.Handle, feature)
+	if err != nil || !impl {
+		return false, false, err
+	}
+	readable, err := IsReadable(c.Handle, feature)
+	if err != nil {
+		return false, false, err
+	}
+	writable, err := IsWritable(c.Handle, feature)
+	return readable, writable, err
+}
+
 // UnpadBuffer strips padding bytes from a buffer
 func UnpadBuffer(buf []byte, aoistride, aoiwidth, aoiheight int) []byte {
 	// TODO: this allocates something bigger than needed
@@ -0,0 +1,64 @@
+package sdk3_test
+
+import (
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/andor/sdk3"
+	"github.com/nasa-jpl/golaborate/generichttp/camera"
+)
+
+// openSimulator opens the first SDK3 simulator camera, or skips the test if
+// the library or the simulator is not available
+func openSimulator(t *testing.T) *sdk3.Camera {
+	t.Helper()
+	if err := sdk3.InitializeLibrary(); err != nil {
+		t.Skipf("SDK3 not available: %v", err)
+	}
+	t.Cleanup(sdk3.FinalizeLibrary)
+	c, err := sdk3.Open(1)
+	if err != nil {
+		t.Skipf("SDK3 simulator not available: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	c.UseSpinner = false
+	return c
+}
+
+func TestMetadataDuringBurst(t *testing.T) {
+	c := openSimulator(t)
+	if err := c.SetExposureTime(10 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(camera.GetMetadata(c))
+	defer srv.Close()
+
+	// 20 frames at 5 fps keeps the burst, and the camera lock, busy for
+	// about four seconds
+	ch := make(chan image.Image)
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.Burst(20, 5, ch) }()
+	if _, ok := <-ch; !ok {
+		t.Fatalf("burst ended before its first frame: %v", <-errCh)
+	}
+
+	client := http.Client{Timeout: time.Second}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Errorf("GET /metadata during a burst: %v", err)
+	} else {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET /metadata during a burst: expected 200, got %d", resp.StatusCode)
+		}
+	}
+
+	for range ch {
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
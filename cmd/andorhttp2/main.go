@@ -8,9 +8,12 @@ import (
 	"strings"
 
 	"github.com/nasa-jpl/golaborate/andor/sdk2"
+	"github.com/nasa-jpl/golaborate/fluke"
 	"github.com/nasa-jpl/golaborate/generichttp"
 	"github.com/nasa-jpl/golaborate/generichttp/camera"
 	"github.com/nasa-jpl/golaborate/imgrec"
+	"github.com/nasa-jpl/golaborate/server"
+	"github.com/nasa-jpl/golaborate/server/jobs"
 
 	"github.com/go-chi/chi"
 	"github.com/knadh/koanf"
@@ -36,6 +39,9 @@ type recorder struct {
 
 	// Prefix is the filename prefix to use
 	Prefix string `yaml:"Prefix"`
+
+	// Format is the file format, fits (default) or tiff
+	Format string `yaml:"Format"`
 }
 type config struct {
 	Addr         string                 `yaml:"Addr"`
@@ -43,6 +49,10 @@ type config struct {
 	SerialNumber string                 `yaml:"SerialNumber"`
 	Recorder     recorder               `yaml:"Recorder"`
 	BootupArgs   map[string]interface{} `yaml:"BootupArgs"`
+	InitSteps    []camera.FeatureValue  `yaml:"InitSteps"`
+	Environment  string                 `yaml:"Environment"`
+	AOIPresets   string                 `yaml:"AOIPresets"`
+	Readout      string                 `yaml:"Readout"`
 }
 
 func setupconfig() {
@@ -56,7 +66,8 @@ func setupconfig() {
 			"AcquisitionMode":     "SingleScan",
 			"ReadoutMode":         "Image",
 			"TemperatureSetpoint": "-15",
-			"SensorCooling":       true}}, "koanf"), nil)
+			"SensorCooling":       true},
+		AOIPresets: "andor-aoi-presets.json"}, "koanf"), nil)
 	if err := k.Load(file.Provider(ConfigFileName), yaml.Parser()); err != nil {
 		errtxt := err.Error()
 		if !strings.Contains(errtxt, "no such") { // file missing, who cares
@@ -94,6 +105,38 @@ a config file.
 
 If for some reason there is an error during server bootup, it may be that a feature is not supported by the camera.
 Modify the BootupArgs portion of the config to remove the offending parameters.
+BootupArgs are checked before any are applied; unknown features and values of the
+wrong type are all reported at once, and the camera is left unconfigured.
+
+BootupArgs are applied in no particular order.  If some settings must be applied
+in a given order, list them under InitSteps instead, e.g.
+
+InitSteps:
+  - Feature: ADChannel
+    Value: 0
+  - Feature: HSSpeed
+    Value: 1
+
+InitSteps are run in order after BootupArgs, and the server stops at the first
+step which fails.  Like BootupArgs, they are checked before any are applied.
+
+Readout, if slowest or fastest, selects the slowest (lowest noise) or fastest
+horizontal and vertical shift speeds for the AD channel after InitSteps.  The
+same selection can be made at runtime by POST to /readout/slowest or
+/readout/fastest, which respond with the speeds chosen.
+
+Environment is the URL of a Fluke DewK served over HTTP, e.g.
+http://localhost:8000/dewk.  When given, the ambient temperature and humidity
+are written to the TAMB and RHUMID FITS cards.  If the sensor cannot be
+reached the cards are left blank.
+
+AOIPresets is the JSON file named AOIs are kept in.  POST an AOI, or nothing to
+use the current one, to /aoi-presets/{name} to save a preset, and POST to
+/aoi/apply/{name} to apply it.  The presets are listed at /aoi-presets.
+
+On SIGINT or SIGTERM the detector is warmed above -20C before the camera is shut
+down, which can take several minutes if it is cold.  Do not kill the process
+while it waits.
 
 serialNumber 'auto' causes the server to scan the available cameras and pick the first one
 which is not a software simulation camera.
@@ -152,7 +195,14 @@ func run() {
 		log.Fatal(err)
 	}
 	c := &sdk2.Camera{}
-	defer c.ShutDown()
+	err = c.ValidateConfig(cfg.BootupArgs)
+	if err != nil {
+		log.Fatalf("BootupArgs: %v", err)
+	}
+	err = c.ValidateSteps(cfg.InitSteps)
+	if err != nil {
+		log.Fatalf("init %v", err)
+	}
 
 	hwv, err := c.GetHardwareVersion()
 	swv, err := c.GetSoftwareVersion()
@@ -178,12 +228,13 @@ func run() {
 		log.Fatal(err)
 	}
 
+	// the AD channel must be known before the HS speed can be set,
+	// so these go first and in order
 	adch := 0
-	err = c.SetADChannel(adch)
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = c.SetVSAmplitude("Normal")
+	err = c.ConfigureOrdered([]camera.FeatureValue{
+		{Feature: "ADChannel", Value: float64(adch)},
+		{Feature: "VSAmplitude", Value: "Normal"},
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -192,6 +243,27 @@ func run() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	err = c.ConfigureOrdered(cfg.InitSteps)
+	if err != nil {
+		log.Fatalf("init %v", err)
+	}
+	switch strings.ToLower(cfg.Readout) {
+	case "":
+	case "slowest":
+		ss, err := c.SelectSlowestReadout()
+		if err != nil {
+			log.Fatalf("Readout: %v", err)
+		}
+		log.Printf("selected the slowest readout, %+v\n", ss)
+	case "fastest":
+		ss, err := c.SelectFastestReadout()
+		if err != nil {
+			log.Fatalf("Readout: %v", err)
+		}
+		log.Printf("selected the fastest readout, %+v\n", ss)
+	default:
+		log.Fatalf("Readout must be slowest, fastest, or empty, got %q", cfg.Readout)
+	}
 	n, err := c.GetNumberVSSpeeds()
 	if err != nil {
 		log.Fatal(err)
@@ -243,9 +315,18 @@ func run() {
 		log.Printf("index %d: %f x (%s)\n", i, f, s)
 	}
 
+	if cfg.Environment != "" {
+		c.Env = fluke.NewClient(cfg.Environment)
+	}
 	args := cfg.Recorder
-	r := &imgrec.Recorder{Root: args.Root, Prefix: args.Prefix}
+	r := &imgrec.Recorder{Root: args.Root, Prefix: args.Prefix, Format: strings.ToLower(args.Format)}
 	w := camera.NewHTTPCamera(c, r)
+	presets := &camera.AOIPresets{A: c, Path: cfg.AOIPresets}
+	if err = presets.Load(); err != nil {
+		log.Fatal(err)
+	}
+	presets.Inject(w.RouteTable)
+	generichttp.HTTPDeviceInfo(sdk2.DeviceInfo{C: c}, w.RouteTable)
 
 	// clean up the submux string
 	hndlrS := cfg.Root
@@ -254,6 +335,11 @@ func run() {
 	mux := chi.NewRouter()
 	root.Mount(hndlrS, mux)
 	w.RT().Bind(mux)
+	jobs.Default.RT().Bind(root)
+	vt := generichttp.RouteTable{}
+	generichttp.HTTPVersion("andorhttp2", Version, c, vt)
+	vt.Bind(root)
+	server.GracefulShutdown(server.CloserFunc(c.SafeShutDown))
 	addr := cfg.Addr + cfg.Root
 	log.Println("now listening for requests at ", addr)
 	log.Fatal(http.ListenAndServe(cfg.Addr, root))
@@ -6,10 +6,14 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/nasa-jpl/golaborate/fluke"
 	"github.com/nasa-jpl/golaborate/generichttp"
 	"github.com/nasa-jpl/golaborate/generichttp/camera"
 	"github.com/nasa-jpl/golaborate/imgrec"
+	"github.com/nasa-jpl/golaborate/server"
+	"github.com/nasa-jpl/golaborate/server/jobs"
 
 	"github.com/go-chi/chi"
 	"github.com/knadh/koanf"
@@ -25,6 +29,10 @@ var (
 	// Version is the version number.  Typically injected via ldflags with git build
 	Version = "12"
 
+	// InitTimeout is how long to wait for the SDK to initialize before
+	// concluding it has deadlocked
+	InitTimeout = time.Minute
+
 	// ConfigFileName is what it sounds like
 	ConfigFileName = "andor-http.yml"
 	k              = koanf.New(".")
@@ -36,6 +44,9 @@ type recorder struct {
 
 	// Prefix is the filename prefix to use
 	Prefix string `yaml:"Prefix"`
+
+	// Format is the file format, fits (default) or tiff
+	Format string `yaml:"Format"`
 }
 type config struct {
 	Addr         string                 `yaml:"Addr"`
@@ -43,6 +54,12 @@ type config struct {
 	SerialNumber string                 `yaml:"SerialNumber"`
 	Recorder     recorder               `yaml:"Recorder"`
 	BootupArgs   map[string]interface{} `yaml:"BootupArgs"`
+	InitSteps    []camera.FeatureValue  `yaml:"InitSteps"`
+	Environment  string                 `yaml:"Environment"`
+	Orientation  float64                `yaml:"Orientation"`
+	PixelScale   float64                `yaml:"PixelScale"`
+	AOIPresets   string                 `yaml:"AOIPresets"`
+	BurstRetries int                    `yaml:"BurstRetries"`
 }
 
 func setupconfig() {
@@ -61,7 +78,9 @@ func setupconfig() {
 			"MetadataEnable":           false,
 			"SensorCooling":            true,
 			"SpuriousNoiseFilter":      false,
-			"StaticBlemishCorrection":  false}}, "koanf"), nil)
+			"StaticBlemishCorrection":  false},
+		Orientation: sdk3.DefaultOrientation,
+		AOIPresets:  "andor-aoi-presets.json"}, "koanf"), nil)
 	if err := k.Load(file.Provider(ConfigFileName), yaml.Parser()); err != nil {
 		errtxt := err.Error()
 		if !strings.Contains(errtxt, "no such") { // file missing, who cares
@@ -99,10 +118,47 @@ a config file.
 
 If for some reason there is an error during server bootup, it may be that a feature is not supported by the camera.
 Modify the BootupArgs portion of the config to remove the offending parameters.
+BootupArgs are checked before any are applied; unknown features and values of the
+wrong type are all reported at once, and the camera is left unconfigured.
+BaselineLevel is not in the defaults since not every camera implements it; add it
+to BootupArgs to set the baseline offset at bootup.
+
+BootupArgs are applied in no particular order.  If some settings must be applied
+in a given order, list them under InitSteps instead, e.g.
+
+InitSteps:
+  - Feature: ADChannel
+    Value: 0
+  - Feature: HSSpeed
+    Value: 1
+
+InitSteps are run in order after BootupArgs, and the server stops at the first
+step which fails.
+
+Environment is the URL of a Fluke DewK served over HTTP, e.g.
+http://localhost:8000/dewk.  When given, the ambient temperature and humidity
+are written to the TAMB and RHUMID FITS cards.  If the sensor cannot be
+reached the cards are left blank.
+
+Orientation is the clockwise rotation of the image in degrees and PixelScale
+the plate scale in arcsec/px.  Both are written to the FITS header; PixelScale
+is omitted when zero.
+
+AOIPresets is the JSON file named AOIs are kept in.  POST an AOI, or nothing to
+use the current one, to /aoi-presets/{name} to save a preset, and POST to
+/aoi/apply/{name} to apply it.  The presets are listed at /aoi-presets.
+
+BurstRetries is the number of frames a burst may drop to WaitBuffer timeouts
+before it fails.  Each dropped frame is logged.  The default, 0, fails the
+burst at the first timeout.
 
 serialNumber 'auto' causes the server to scan the available cameras and pick the first one
 which is not a software simulation camera.
 
+The connected cameras are listed at /cameras.  POST {"index": n} to
+/select-camera to drive a different one, for example a simulator, without
+restarting; the BootupArgs and InitSteps are applied to it again.
+
 If the files and folders created do not have the permissions you want on linux,
 your umask is likely to blame  andor-http makes them with permission 666, but your
 umask is probably the default of 0022 which knocks them down to 444.  Set your
@@ -146,8 +202,9 @@ func pversion() {
 func run() {
 	cfg := config{}
 	k.Unmarshal("", &cfg)
+	log.Println("initializing SDK, andor's code can deadlock here.")
 	// load the library and see how many cameras are connected
-	err := sdk3.InitializeLibrary()
+	err := sdk3.InitializeLibraryWithTimeout(InitTimeout)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -181,7 +238,7 @@ func run() {
 			log.Fatal(err)
 		}
 		if sn == "auto" {
-			if !strings.Contains(sn, "SFT") {
+			if !sdk3.IsSimulator(snCam) {
 				break
 			} else {
 				c.Close()
@@ -202,15 +259,29 @@ func run() {
 	}
 	log.Printf("connected to %s SN %s\n", model, snCam)
 
-	err = c.Configure(cfg.BootupArgs)
+	for i, step := range cfg.InitSteps {
+		if _, ok := sdk3.Features[step.Feature]; !ok {
+			log.Fatalf("init step %d (%s=%v): %v", i, step.Feature, step.Value, sdk3.ErrFeatureNotFound{Feature: step.Feature})
+		}
+	}
+	err = configure(c, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	c.Allocate()
 	defer c.Close()
+	if cfg.Environment != "" {
+		c.Env = fluke.NewClient(cfg.Environment)
+	}
 	args := cfg.Recorder
-	r := &imgrec.Recorder{Root: args.Root, Prefix: args.Prefix}
+	r := &imgrec.Recorder{Root: args.Root, Prefix: args.Prefix, Format: strings.ToLower(args.Format)}
 	w := camera.NewHTTPCamera(c, r)
+	sel := selector{c: c, cfg: cfg}
+	sel.Inject(w.RouteTable)
+	presets := &camera.AOIPresets{A: c, Path: cfg.AOIPresets}
+	if err = presets.Load(); err != nil {
+		log.Fatal(err)
+	}
+	presets.Inject(w.RouteTable)
 
 	// clean up the submux string
 	hndlrS := cfg.Root
@@ -219,11 +290,53 @@ func run() {
 	mux := chi.NewRouter()
 	root.Mount(hndlrS, mux)
 	w.RT().Bind(mux)
+	jobs.Default.RT().Bind(root)
+	vt := generichttp.RouteTable{}
+	generichttp.HTTPVersion("andorhttp3", Version, c, vt)
+	vt.Bind(root)
+	server.GracefulShutdown(
+		server.CloserFunc(func() error { return c.SetCooling(false) }),
+		c,
+		server.CloserFunc(func() error { sdk3.FinalizeLibrary(); return nil }))
 	addr := cfg.Addr + cfg.Root
 	log.Println("now listening for requests at ", addr)
 	log.Fatal(http.ListenAndServe(cfg.Addr, root))
 }
 
+// configure applies the BootupArgs, InitSteps, orientation, and pixel scale
+// of the config to the camera
+func configure(c *sdk3.Camera, cfg config) error {
+	err := c.ValidateConfig(cfg.BootupArgs)
+	if err != nil {
+		return fmt.Errorf("BootupArgs: %w", err)
+	}
+	err = c.Configure(cfg.BootupArgs)
+	if err != nil {
+		return err
+	}
+	err = c.ConfigureOrdered(cfg.InitSteps)
+	if err != nil {
+		return fmt.Errorf("init %w", err)
+	}
+	modes, err := c.CheckModeCompatibility()
+	if err != nil {
+		return err
+	}
+	for _, m := range modes {
+		log.Printf("warning: %s, the combination of modes requested is not supported by the camera\n", m)
+	}
+	c.SetOrientation(cfg.Orientation)
+	err = c.SetBurstRetries(cfg.BurstRetries)
+	if err != nil {
+		return err
+	}
+	err = c.SetPixelScale(cfg.PixelScale)
+	if err != nil {
+		return err
+	}
+	return c.Allocate()
+}
+
 func main() {
 	var cmd string
 	args := os.Args
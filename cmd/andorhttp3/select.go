@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/nasa-jpl/golaborate/andor/sdk3"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// selector lists the cameras known to the SDK and switches which one the
+// server drives
+type selector struct {
+	c   *sdk3.Camera
+	cfg config
+}
+
+// ListCameras responds with the cameras known to the SDK as JSON
+func (s selector) ListCameras(w http.ResponseWriter, r *http.Request) {
+	cams, err := s.c.ListCameras()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(cams)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// SelectCamera closes the camera in use and opens the one at the index in
+// the body, {"index": n}, then configures it as at bootup
+func (s selector) SelectCamera(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		Index int `json:"index"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	defer r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := sdk3.DeviceCount()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if req.Index < 0 || req.Index >= n {
+		http.Error(w, fmt.Sprintf("camera index %d out of range, there are %d cameras", req.Index, n), http.StatusBadRequest)
+		return
+	}
+	err = s.c.Reopen(req.Index)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = configure(s.c, s.cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Inject adds the camera selection routes to the table
+func (s selector) Inject(table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/cameras"}] = s.ListCameras
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/select-camera"}] = s.SelectCamera
+}
@@ -3,17 +3,20 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/nasa-jpl/golaborate/acromag"
 	"github.com/nasa-jpl/golaborate/generichttp/daq"
+	"github.com/nasa-jpl/golaborate/server"
+	"github.com/nasa-jpl/golaborate/server/jobs"
 	"github.com/nasa-jpl/golaborate/server/middleware/locker"
 )
 
@@ -21,6 +24,21 @@ var (
 	channels = []int{0, 1, 2, 3, 4, 5}
 )
 
+// maskChannels enables the channels in use and disables all others
+func maskChannels(dac daq.ChannelEnabler) error {
+	inUse := map[int]bool{}
+	for _, ch := range channels {
+		inUse[ch] = true
+	}
+	for ch := 0; ch < 16; ch++ {
+		err := dac.SetChannelEnabled(ch, inUse[ch])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // SetupAP235 initializes the AP235 hardware to a pre-configured and safe condition
 func SetupAP235() (*acromag.AP235, error) {
 	dac, err := acromag.NewAP235(0)
@@ -70,6 +88,7 @@ func SetupAP235() (*acromag.AP235, error) {
 		dac.SetTriggerMode(ch, "timer")
 		dac.SetClearOnUnderflow(ch, true)
 	}
+	err = maskChannels(dac)
 	return dac, err
 }
 
@@ -108,6 +127,7 @@ func SetupAP236() (*acromag.AP236, error) {
 			return dac, err
 		}
 	}
+	err = maskChannels(dac)
 	return dac, err
 }
 
@@ -121,28 +141,26 @@ func SetupHTTP(dac daq.DAC) chi.Router {
 	return r
 }
 
-// LoadWaveform loads a waveform into system memory, for on-demand copying into
-// the DAC's buffer
-func LoadWaveform(dac *acromag.AP235, name string, period time.Duration) error {
-	if period < 0 {
-		return errors.New("LoadWaveform: period must be positive")
+// LoadWaveform loads a waveform from a CSV file on this computer's disk with
+// daq.LoadCSVFloats, which tracks the load in jobs.Default.  The period is
+// checked before anything is loaded
+func LoadWaveform(dac *acromag.AP235, name string, period time.Duration) (daq.WaveformInfo, error) {
+	ns := period.Nanoseconds()
+	if ns < 0 || ns > math.MaxUint32 {
+		return daq.WaveformInfo{}, fmt.Errorf("%w: period %d ns is outside the range the timer can count", daq.ErrInvalidSetting, ns)
 	}
 	f, err := os.Open(name)
 	if err != nil {
-		return err
+		return daq.WaveformInfo{}, err
 	}
 	defer f.Close()
-	periodNano := period.Nanoseconds()
-	err = daq.LoadCSVFloats(dac, f, uint32(periodNano))
-	if err != nil {
-		return err
-	}
-	return nil
+	return daq.LoadCSVFloats(dac, f, uint32(ns))
 }
 
 func main() {
 	root := chi.NewRouter()
 	root.Use(middleware.Logger)
+	var closers []io.Closer
 	log.Println("connecting to AP235 (waveform DAC).  If the program is hanging, the driver has glitched;\n reboot the computer")
 	ap235, err := SetupAP235()
 	if err != nil {
@@ -150,6 +168,9 @@ func main() {
 	} else {
 		r235 := SetupHTTP(ap235)
 		root.Mount("/ap235/", r235)
+		closers = append(closers, ap235)
+		// load-waveform reads a file on this computer's disk; clients
+		// elsewhere can POST the CSV to /waveform/upload instead
 		r235.Post("/load-waveform", func(w http.ResponseWriter, r *http.Request) {
 			type msg struct {
 				Filename string `json:"filename"`
@@ -161,12 +182,24 @@ func main() {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
-			err = LoadWaveform(ap235, input.Filename, time.Duration(input.Periodns)*time.Nanosecond)
+			info, err := LoadWaveform(ap235, input.Filename, time.Duration(input.Periodns)*time.Nanosecond)
+			if info.JobID != "" {
+				w.Header().Set("X-Job-Id", info.JobID)
+			}
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				code := http.StatusInternalServerError
+				if errors.Is(err, daq.ErrInvalidSetting) {
+					code = http.StatusBadRequest
+				}
+				http.Error(w, err.Error(), code)
 				return
 			}
+			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusOK)
+			err = json.NewEncoder(w).Encode(info)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
 		})
 		log.Println("AP235 available via HTTP at /ap235")
 	}
@@ -177,20 +210,11 @@ func main() {
 	} else {
 		r236 := SetupHTTP(ap236)
 		root.Mount("/ap236/", r236)
+		closers = append(closers, ap236)
 		log.Println("AP236 available via HTTP at /ap236")
 	}
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGABRT, syscall.SIGTERM)
-	go func() {
-		<-ch
-		if ap235 != nil {
-			ap235.Close()
-		}
-		if ap236 != nil {
-			ap236.Close()
-		}
-		os.Exit(0)
-	}()
+	jobs.Default.RT().Bind(root)
+	server.GracefulShutdown(closers...)
 	log.Println("now listening on port 8080")
 	log.Fatal(http.ListenAndServe(":8080", root))
 }
@@ -2,16 +2,22 @@ package main
 
 import (
 	"encoding/json"
+	"io"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/nasa-jpl/golaborate/agilent"
 	"github.com/nasa-jpl/golaborate/generichttp"
 	"github.com/nasa-jpl/golaborate/keysight"
 	"github.com/nasa-jpl/golaborate/pi"
+	"github.com/nasa-jpl/golaborate/poller"
+	"github.com/nasa-jpl/golaborate/server/jobs"
 	"github.com/nasa-jpl/golaborate/server/middleware/locker"
+	"github.com/nasa-jpl/golaborate/server/middleware/timeout"
 	"github.com/nasa-jpl/golaborate/util"
 
 	"github.com/nasa-jpl/golaborate/aerotech"
@@ -70,6 +76,36 @@ type ObjSetup struct {
 	Args map[string]interface{} `yaml:"Args"`
 
 	DaisyChain []Daisy `yaml:"DaisyChain"`
+
+	// Timeout is the longest a request to this device may take, in seconds,
+	// before it is answered with 504.  Zero is no limit
+	Timeout float64 `yaml:"Timeout"`
+
+	// RouteTimeouts overrides Timeout for particular routes, keyed by
+	// "METHOD /path", e.g. "GET /pos".  Zero is no limit
+	RouteTimeouts map[string]float64 `yaml:"RouteTimeouts"`
+
+	// PollInterval, if not zero, reads the device in the background every
+	// PollInterval seconds and serves the latest reading at GET /read/cached,
+	// so that many clients do not each hit the device.  Only Cryocon and
+	// Fluke nodes may be polled
+	PollInterval float64 `yaml:"PollInterval"`
+}
+
+// timeouts converts the Timeout and RouteTimeouts of a node for use with the
+// timeout middleware
+func (o ObjSetup) timeouts() (timeout.Timeouts, error) {
+	t := timeout.Timeouts{
+		Default: util.SecsToDuration(o.Timeout),
+		Routes:  map[generichttp.MethodPath]time.Duration{}}
+	for route, secs := range o.RouteTimeouts {
+		mp, err := timeout.ParseMethodPath(route)
+		if err != nil {
+			return t, err
+		}
+		t.Routes[mp] = util.SecsToDuration(secs)
+	}
+	return t, nil
 }
 
 // Config is a struct that holds the initialization parameters for various
@@ -100,11 +136,15 @@ func LoadYaml(path string) (Config, error) {
 // and uses them to construct a goji mux with populated handlers.
 // The mux serves a special route, route-list, which returns an
 // array of strings containing all routes as JSON.
-func BuildMux(c Config) chi.Router {
+// The devices which must be closed on shutdown are also returned: the NKT,
+// whose power ramps run in the background, and any pollers.  The other
+// devices hold only connections, which are closed with the process.
+func BuildMux(c Config) (chi.Router, []io.Closer) {
 	// make the root handler
 	root := chi.NewRouter()
 	root.Use(middleware.Logger)
 	supergraph := map[string][]string{}
+	var closers []io.Closer
 
 OuterLoop:
 	// for every node specified, build a submux
@@ -112,9 +152,14 @@ OuterLoop:
 		var (
 			httper     generichttp.HTTPer
 			middleware []func(http.Handler) http.Handler
+			read       poller.ReadFunc
 		)
 		axislocker := false
 		typ := strings.ToLower(node.Type)
+		timeouts, err := node.timeouts()
+		if err != nil {
+			log.Fatal(node.Endpoint, ": ", err)
+		}
 		switch typ {
 
 		case "aerotech", "ensemble", "esp", "esp300", "esp301", "picomotor", "xps", "pi", "pi-daisy-chain":
@@ -157,6 +202,8 @@ OuterLoop:
 				ensemble := aerotech.NewEnsemble(node.Addr, node.Serial)
 				limiter := motion.LimitMiddleware{Limits: limiters, Mov: ensemble}
 				httper = motion.NewHTTPMotionController(ensemble)
+				aerotech.HTTPPrograms(ensemble, httper.RT())
+				aerotech.HTTPPSO(ensemble, httper.RT())
 				middleware = append(middleware, limiter.Check)
 				limiter.Inject(httper)
 			case "esp", "esp300", "esp301":
@@ -208,6 +255,7 @@ OuterLoop:
 					}
 					// add the lock middleware
 					locker.Inject(httper, lock)
+					timeout.Inject(httper, timeouts)
 					r := chi.NewRouter()
 					r.Use(middleware...)
 					r.Use(lock.Check)
@@ -232,6 +280,16 @@ OuterLoop:
 			}
 			cryo := cryocon.NewTemperatureMonitor(node.Addr)
 			httper = cryocon.NewHTTPWrapper(*cryo)
+			read = func() (interface{}, error) {
+				f, err := cryo.ReadAllChannels()
+				// NaN (no probe) encoded as -274, as by the /read route
+				for i := range f {
+					if math.IsNaN(f[i]) {
+						f[i] = -274
+					}
+				}
+				return f, err
+			}
 
 		case "fluke", "dewk":
 			if c.Mock {
@@ -239,6 +297,7 @@ OuterLoop:
 			}
 			dewK := fluke.NewDewK(node.Addr)
 			httper = fluke.NewHTTPWrapper(*dewK)
+			read = func() (interface{}, error) { return dewK.Read() }
 
 		case "keysight-scope":
 			if c.Mock {
@@ -270,11 +329,27 @@ OuterLoop:
 				sk = nkt.NewSuperK(node.Addr, node.Serial)
 			}
 			httper = nkt.NewHTTPWrapper(sk)
+			if cl, ok := sk.(io.Closer); ok {
+				closers = append(closers, cl)
+			}
 
 		default:
 			log.Fatal("type ", typ, " not understood")
 		}
 
+		if node.PollInterval != 0 {
+			if read == nil {
+				log.Fatal(node.Endpoint, ": type ", typ, " cannot be polled")
+			}
+			p, err := poller.New(read, util.SecsToDuration(node.PollInterval))
+			if err != nil {
+				log.Fatal(node.Endpoint, ": ", err)
+			}
+			p.Inject(httper.RT(), "/read/cached")
+			p.Start()
+			closers = append(closers, p)
+		}
+
 		// prepare the URL, "omc/nkt" => "/omc/nkt/*"
 		hndlS := generichttp.SubMuxSanitize(node.Endpoint)
 
@@ -292,6 +367,9 @@ OuterLoop:
 		// add the lock middleware
 		locker.Inject(httper, lock)
 
+		// bound the time each request may take
+		timeout.Inject(httper, timeouts)
+
 		// bind to the mux
 		r := chi.NewRouter()
 		r.Use(middleware...)
@@ -299,6 +377,10 @@ OuterLoop:
 		httper.RT().Bind(r)
 		root.Mount(hndlS, r)
 	}
+	jobs.Default.RT().Bind(root)
+	vt := generichttp.RouteTable{}
+	generichttp.HTTPVersion("multiserver", Version, nil, vt)
+	vt.Bind(root)
 	root.Get("/endpoints", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
@@ -307,5 +389,5 @@ OuterLoop:
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	})
-	return root
+	return root, closers
 }
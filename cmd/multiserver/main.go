@@ -11,6 +11,7 @@ import (
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/providers/structs"
+	"github.com/nasa-jpl/golaborate/server"
 
 	yml "gopkg.in/yaml.v2"
 )
@@ -133,7 +134,8 @@ func run() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	mux := BuildMux(c)
+	mux, closers := BuildMux(c)
+	server.GracefulShutdown(closers...)
 	log.Println("now listening for requests at ", c.Addr)
 	log.Fatal(http.ListenAndServe(c.Addr, mux))
 }
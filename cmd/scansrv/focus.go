@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"path/filepath"
+
+	"github.com/nasa-jpl/golaborate/generichttp/camera"
+	"github.com/nasa-jpl/golaborate/server/jobs"
+)
+
+// MaxFocusPositions bounds the number of positions in a focus sweep
+const MaxFocusPositions = 1000
+
+// FocusRequest is a sweep of the focus axis from Start to Stop in steps of
+// Step, taking a frame at each position
+type FocusRequest struct {
+	Start float64 `json:"start"`
+	Stop  float64 `json:"stop"`
+	Step  float64 `json:"step"`
+
+	// SettleTime is the time to wait after the axis is in position and before
+	// taking a frame, in seconds.  If not given, the configured SettleTime is
+	// used
+	SettleTime *float64 `json:"settleTime"`
+
+	// Average is the number of frames averaged at each position before the
+	// sharpness is computed.  Zero is the same as one, no averaging
+	Average int `json:"average"`
+
+	// Prefix, if not empty, writes the frame at each position to OutputDir as
+	// prefix_00001.fits, ...  Otherwise no frames are kept
+	Prefix string `json:"prefix"`
+
+	// Metric is the focus metric, one of camera.FocusMetrics.  If not given,
+	// camera.DefaultFocusMetric is used
+	Metric string `json:"metric"`
+
+	// MoveToBest moves the focus axis to the best focus position after the
+	// sweep
+	MoveToBest bool `json:"moveToBest"`
+}
+
+// positions returns the positions visited by the sweep
+func (r FocusRequest) positions() []float64 {
+	n := int(math.Floor(math.Abs(r.Stop-r.Start)/math.Abs(r.Step)+1e-9)) + 1
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = r.Start + float64(i)*r.Step
+	}
+	return out
+}
+
+// Validate checks the request and fills in defaults
+func (r *FocusRequest) Validate(cfg Config) error {
+	if cfg.FocusAxis == "" {
+		return errors.New("no FocusAxis is configured")
+	}
+	for _, v := range []float64{r.Start, r.Stop, r.Step} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errors.New("start, stop, and step must be finite")
+		}
+	}
+	if r.Step == 0 {
+		return errors.New("step must not be zero")
+	}
+	if r.Stop != r.Start && (r.Stop > r.Start) != (r.Step > 0) {
+		return fmt.Errorf("step %f moves away from stop %f", r.Step, r.Stop)
+	}
+	if n := math.Abs(r.Stop-r.Start)/math.Abs(r.Step) + 1; n > MaxFocusPositions {
+		return fmt.Errorf("sweep has %.0f positions, more than the maximum of %d", n, MaxFocusPositions)
+	}
+	if r.SettleTime == nil {
+		settle := cfg.SettleTime
+		r.SettleTime = &settle
+	}
+	if *r.SettleTime < 0 {
+		return fmt.Errorf("settleTime must be non-negative, got %f", *r.SettleTime)
+	}
+	if r.Average < 0 {
+		return fmt.Errorf("average must be non-negative, got %d", r.Average)
+	}
+	if r.Average == 0 {
+		r.Average = 1
+	}
+	if r.Metric == "" {
+		r.Metric = camera.DefaultFocusMetric
+	}
+	return camera.ValidateFocusMetric(r.Metric)
+}
+
+// FocusProgress describes the state and result of the current or last focus
+// sweep
+type FocusProgress struct {
+	Running bool `json:"running"`
+	Total   int  `json:"total"`
+	Done    int  `json:"done"`
+
+	// Metric is the focus metric used for Sharpness
+	Metric string `json:"metric"`
+
+	// Positions and Sharpness are the positions visited so far and the
+	// focus metric of the frame at each
+	Positions []float64 `json:"positions"`
+	Sharpness []float64 `json:"sharpness"`
+
+	// Best is the position of greatest sharpness, once the sweep has finished
+	Best *float64 `json:"best"`
+
+	Files []string `json:"files"`
+	Error string   `json:"error"`
+}
+
+// StartFocus validates the request and begins the focus sweep in the
+// background.  Focus sweeps and scans share the stage, so only one of either
+// may run at a time
+func (s *Scanner) StartFocus(req FocusRequest) error {
+	if err := req.Validate(s.Cfg); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.busy() {
+		return ErrScanRunning
+	}
+	pos := req.positions()
+	s.focus = FocusProgress{Running: true, Total: len(pos), Metric: req.Metric}
+	s.abort = make(chan struct{})
+	s.job = jobs.Default.Start("focus")
+	go s.runFocus(req, pos, s.abort)
+	return nil
+}
+
+// AbortFocus stops the focus sweep in progress before its next move or frame
+func (s *Scanner) AbortFocus() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.focus.Running {
+		return errors.New("no focus sweep is running")
+	}
+	select {
+	case <-s.abort:
+	default:
+		close(s.abort)
+	}
+	return nil
+}
+
+// FocusProgress returns the progress and result of the current or last
+// focus sweep
+func (s *Scanner) FocusProgress() FocusProgress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.focus
+	p.Positions = append([]float64(nil), p.Positions...)
+	p.Sharpness = append([]float64(nil), p.Sharpness...)
+	p.Files = append([]string(nil), p.Files...)
+	return p
+}
+
+func (s *Scanner) runFocus(req FocusRequest, pos []float64, abort chan struct{}) {
+	err := s.sweep(req, pos, abort)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.focus.Running = false
+	if err != nil {
+		s.focus.Error = err.Error()
+	}
+	s.job.Finish(err)
+}
+
+func (s *Scanner) sweep(req FocusRequest, pos []float64, abort chan struct{}) error {
+	axes := []string{s.Cfg.FocusAxis}
+	for i, p := range pos {
+		select {
+		case <-abort:
+			return ErrAborted
+		default:
+		}
+		err := s.goTo(axes, []float64{p}, *req.SettleTime, abort)
+		if err != nil {
+			return fmt.Errorf("position %d: %w", i, err)
+		}
+		cards := []camera.ExtraCard{
+			{Name: "FOCUS", Value: p, Comment: "position of axis " + s.Cfg.FocusAxis},
+			{Name: "SETTLE", Value: *req.SettleTime, Comment: "settle time after move, s"},
+			{Name: "NAVG", Value: req.Average, Comment: "number of frames averaged"}}
+		frames, err := s.frames(cards, req.Average)
+		if err != nil {
+			return fmt.Errorf("position %d: %w", i, err)
+		}
+		sharp, err := sharpness(frames, req.Metric)
+		if err != nil {
+			return fmt.Errorf("position %d: %w", i, err)
+		}
+		var fn string
+		if req.Prefix != "" {
+			fn = filepath.Join(s.Cfg.OutputDir, fmt.Sprintf("%s_%05d.fits", req.Prefix, i+1))
+			err = writeFrames(fn, frames)
+			if err != nil {
+				return fmt.Errorf("position %d: %w", i, err)
+			}
+		}
+		s.mu.Lock()
+		s.focus.Done++
+		s.focus.Positions = append(s.focus.Positions, p)
+		s.focus.Sharpness = append(s.focus.Sharpness, sharp)
+		if fn != "" {
+			s.focus.Files = append(s.focus.Files, fn)
+		}
+		s.job.Progress(100 * float64(s.focus.Done) / float64(s.focus.Total))
+		s.mu.Unlock()
+	}
+	s.mu.Lock()
+	ibest := 0
+	for i, v := range s.focus.Sharpness {
+		if v > s.focus.Sharpness[ibest] {
+			ibest = i
+		}
+	}
+	best := s.focus.Positions[ibest]
+	s.focus.Best = &best
+	s.mu.Unlock()
+	if req.MoveToBest {
+		err := s.goTo(axes, []float64{best}, 0, abort)
+		if err != nil {
+			return fmt.Errorf("moving to best focus: %w", err)
+		}
+	}
+	return nil
+}
+
+// sharpness returns the focus metric of the mean of FITS frames
+func sharpness(frames [][]byte, metric string) (float64, error) {
+	var (
+		width, height int
+		sum           []float64
+	)
+	for i, frame := range frames {
+		fw, fh, data, _, err := camera.ReadFitsFloat(bytes.NewReader(frame))
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 {
+			width, height, sum = fw, fh, data
+			continue
+		}
+		if fw != width || fh != height {
+			return 0, fmt.Errorf("frame %d is %dx%d, expected %dx%d", i, fw, fh, width, height)
+		}
+		for j, v := range data {
+			sum[j] += v
+		}
+	}
+	for i := range sum {
+		sum[i] /= float64(len(frames))
+	}
+	return camera.FocusMetricFloat(width, height, sum, metric), nil
+}
+
+// StartFocusSweep begins a focus sweep from the FocusRequest in the body
+func (s *Scanner) StartFocusSweep(w http.ResponseWriter, r *http.Request) {
+	var req FocusRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	defer r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = s.StartFocus(req)
+	if err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, ErrScanRunning) {
+			code = http.StatusConflict
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	s.mu.Lock()
+	w.Header().Set("X-Job-Id", s.job.ID())
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+// AbortFocusSweep aborts the focus sweep in progress
+func (s *Scanner) AbortFocusSweep(w http.ResponseWriter, r *http.Request) {
+	err := s.AbortFocus()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetFocusProgress responds with the FocusProgress as JSON
+func (s *Scanner) GetFocusProgress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(w).Encode(s.FocusProgress())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
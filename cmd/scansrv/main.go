@@ -0,0 +1,206 @@
+// Command scansrv runs raster scans which move a stage and take a frame at
+// each position, driving the stage and camera through their own HTTP servers
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/providers/structs"
+	"github.com/nasa-jpl/golaborate/server/jobs"
+
+	yml "gopkg.in/yaml.v2"
+)
+
+var (
+	// Version is the version number.  Typically injected via ldflags with git build
+	Version = "1"
+
+	// ConfigFileName is what it sounds like
+	ConfigFileName = "scansrv.yml"
+	k              = koanf.New(".")
+)
+
+// Config is the configuration of the scan server
+type Config struct {
+	// Addr is the address to listen at
+	Addr string `yaml:"Addr"`
+
+	// Camera is the URL of the camera, e.g. http://localhost:8000
+	Camera string `yaml:"Camera"`
+
+	// Motion is the URL of the motion controller, e.g. http://localhost:8001/omc/esp
+	Motion string `yaml:"Motion"`
+
+	// Axes are the axes moved at each position, in the order coordinates are given
+	Axes []string `yaml:"Axes"`
+
+	// FocusAxis is the axis moved by focus sweeps
+	FocusAxis string `yaml:"FocusAxis"`
+
+	// SettleTime is the time to wait after the axes are in position and before
+	// taking a frame, in seconds
+	SettleTime float64 `yaml:"SettleTime"`
+
+	// InPositionTimeout is the longest to wait for an axis to be in position, in seconds
+	InPositionTimeout float64 `yaml:"InPositionTimeout"`
+
+	// OutputDir is the folder frames are written to
+	OutputDir string `yaml:"OutputDir"`
+}
+
+func setupconfig() {
+	k.Load(structs.Provider(Config{
+		Addr:              ":8002",
+		Camera:            "http://localhost:8000",
+		Motion:            "http://localhost:8001",
+		Axes:              []string{"X"},
+		FocusAxis:         "Z",
+		SettleTime:        0.1,
+		InPositionTimeout: 60,
+		OutputDir:         "."}, "koanf"), nil)
+	if err := k.Load(file.Provider(ConfigFileName), yaml.Parser()); err != nil {
+		errtxt := err.Error()
+		if !strings.Contains(errtxt, "no such") { // file missing, who cares
+			log.Fatalf("error loading config: %v", err)
+		}
+	}
+}
+
+func root() {
+	str := `scansrv runs raster scans, moving a stage and taking a frame at each position,
+and focus sweeps, which find the position of best focus.
+The stage and camera are driven through their HTTP servers, e.g. multiserver and
+andorhttp3, so the client makes one request for the whole scan.
+
+Usage:
+	scansrv <command>
+
+Commands:
+	run
+	help
+	mkconf
+	conf
+	version`
+	fmt.Println(str)
+}
+
+func help() {
+	str := `scansrv is configured via its .yaml file, see mkconf.
+
+Camera and Motion are the URLs of the camera and motion controller, Axes are the
+axes moved at each position.  At each position, every axis is moved, scansrv
+waits for the axes to report being in position (if the controller supports it)
+and for SettleTime seconds, then takes a FITS frame.  The coordinates are
+written to the header as POS1, POS2, ... and the file to OutputDir.
+
+A scan may override the settle time with settleTime, and average several frames
+at each position with average.  Averaged frames are written as 32-bit floats.
+The settle time and number of frames are written to the header as SETTLE and NAVG.
+
+A focus sweep moves FocusAxis from start to stop in steps of step, taking a
+frame at each position and computing its sharpness with metric, one of brenner,
+gradient-energy, laplacian, or tenengrad (the default).  GET /focus returns the
+positions, the sharpness at each, and the best focus position once the sweep is
+done.  With moveToBest the axis is left at the best focus.  Frames are only
+written to OutputDir if a prefix is given, with the position as FOCUS.  Scans
+and focus sweeps share the stage, so only one runs at a time.
+
+Routes:
+	POST /scan        {"positions": [[x1, y1], [x2, y2], ...], "prefix": "scan",
+	                   "settleTime": 0.5, "average": 4}
+	GET  /scan        progress of the current or last scan
+	POST /scan/abort  stop the scan before its next move or frame
+	POST /focus       {"start": 0, "stop": 1, "step": 0.05, "settleTime": 0.5,
+	                   "average": 1, "prefix": "", "metric": "tenengrad",
+	                   "moveToBest": true}
+	GET  /focus       progress and result of the current or last focus sweep
+	POST /focus/abort stop the focus sweep before its next move or frame
+	GET  /version     the build version of scansrv`
+	fmt.Println(str)
+}
+
+func mkconf() {
+	c := Config{}
+	err := k.Unmarshal("", &c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := os.Create(ConfigFileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	err = yml.NewEncoder(f).Encode(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printconf() {
+	c := Config{}
+	k.Unmarshal("", &c)
+	err := yml.NewEncoder(os.Stdout).Encode(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func pversion() {
+	fmt.Printf("scansrv version %v\n", Version)
+}
+
+func run() {
+	c := Config{}
+	err := k.Unmarshal("", &c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := &Scanner{Cfg: c, Client: &http.Client{Timeout: 10 * time.Minute}}
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	s.RT().Bind(r)
+	jobs.Default.RT().Bind(r)
+	log.Println("now listening for requests at ", c.Addr)
+	log.Fatal(http.ListenAndServe(c.Addr, r))
+}
+
+func main() {
+	var cmd string
+	args := os.Args
+	if len(args) == 1 {
+		root()
+		return
+	}
+	setupconfig()
+	cmd = args[1]
+	cmd = strings.ToLower(cmd)
+	switch cmd {
+	case "help":
+		help()
+		return
+	case "mkconf":
+		mkconf()
+		return
+	case "conf":
+		printconf()
+		return
+	case "run":
+		run()
+		return
+	case "version":
+		pversion()
+		return
+	default:
+		log.Fatal("unknown command")
+	}
+}
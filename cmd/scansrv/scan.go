@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/astrogo/fitsio"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/generichttp/camera"
+	"github.com/nasa-jpl/golaborate/server/jobs"
+	"github.com/nasa-jpl/golaborate/util"
+)
+
+var (
+	// ErrAborted is generated when a scan is aborted
+	ErrAborted = errors.New("scan aborted")
+
+	// ErrScanRunning is generated when a scan or focus sweep is started while
+	// either is running
+	ErrScanRunning = errors.New("a scan or focus sweep is already running")
+)
+
+// ScanRequest is a list of positions to visit.  Each position has one
+// coordinate per configured axis, in order
+type ScanRequest struct {
+	Positions [][]float64 `json:"positions"`
+
+	// Prefix is prepended to the filename of each frame, e.g. prefix_00001.fits
+	Prefix string `json:"prefix"`
+
+	// SettleTime is the time to wait after the axes are in position and
+	// before taking a frame, in seconds.  If not given, the configured
+	// SettleTime is used
+	SettleTime *float64 `json:"settleTime"`
+
+	// Average is the number of frames averaged at each position.  Zero is
+	// the same as one, no averaging
+	Average int `json:"average"`
+}
+
+// Validate checks the request against the axes and fills in defaults
+func (r *ScanRequest) Validate(cfg Config) error {
+	if len(r.Positions) == 0 {
+		return errors.New("scan has no positions")
+	}
+	for i, pos := range r.Positions {
+		if len(pos) != len(cfg.Axes) {
+			return fmt.Errorf("position %d has %d coordinates, expected %d for axes %v", i, len(pos), len(cfg.Axes), cfg.Axes)
+		}
+	}
+	if r.SettleTime == nil {
+		settle := cfg.SettleTime
+		r.SettleTime = &settle
+	}
+	if *r.SettleTime < 0 {
+		return fmt.Errorf("settleTime must be non-negative, got %f", *r.SettleTime)
+	}
+	if r.Average < 0 {
+		return fmt.Errorf("average must be non-negative, got %d", r.Average)
+	}
+	if r.Average == 0 {
+		r.Average = 1
+	}
+	return nil
+}
+
+// Progress describes the state of the current or last scan
+type Progress struct {
+	Running bool     `json:"running"`
+	Total   int      `json:"total"`
+	Done    int      `json:"done"`
+	Files   []string `json:"files"`
+	Error   string   `json:"error"`
+}
+
+// Scanner runs scans which move a stage and take a frame at each position.
+// The stage and camera are driven through their HTTP servers, e.g. multiserver
+// and andorhttp3
+type Scanner struct {
+	// Cfg is the configuration of the scanner
+	Cfg Config
+
+	// Client is used for all requests to the stage and camera
+	Client *http.Client
+
+	mu       sync.Mutex
+	progress Progress
+	focus    FocusProgress
+	abort    chan struct{}
+	job      *jobs.Handle
+}
+
+// busy returns true if a scan or focus sweep is running.  s.mu must be held
+func (s *Scanner) busy() bool {
+	return s.progress.Running || s.focus.Running
+}
+
+// Start validates the request and begins the scan in the background
+func (s *Scanner) Start(req ScanRequest) error {
+	if err := req.Validate(s.Cfg); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.busy() {
+		return ErrScanRunning
+	}
+	s.progress = Progress{Running: true, Total: len(req.Positions)}
+	s.abort = make(chan struct{})
+	s.job = jobs.Default.Start("scan")
+	go s.run(req, s.abort)
+	return nil
+}
+
+// Abort stops the scan in progress before its next move or frame
+func (s *Scanner) Abort() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.progress.Running {
+		return errors.New("no scan is running")
+	}
+	select {
+	case <-s.abort:
+	default:
+		close(s.abort)
+	}
+	return nil
+}
+
+// Progress returns the progress of the current or last scan
+func (s *Scanner) Progress() Progress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.progress
+	p.Files = append([]string(nil), p.Files...)
+	return p
+}
+
+func (s *Scanner) run(req ScanRequest, abort chan struct{}) {
+	err := s.scan(req, abort)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress.Running = false
+	if err != nil {
+		s.progress.Error = err.Error()
+	}
+	s.job.Finish(err)
+}
+
+func (s *Scanner) scan(req ScanRequest, abort chan struct{}) error {
+	aborted := func() bool {
+		select {
+		case <-abort:
+			return true
+		default:
+			return false
+		}
+	}
+	for i, pos := range req.Positions {
+		if aborted() {
+			return ErrAborted
+		}
+		err := s.goTo(s.Cfg.Axes, pos, *req.SettleTime, abort)
+		if err != nil {
+			return fmt.Errorf("position %d: %w", i, err)
+		}
+		fn := filepath.Join(s.Cfg.OutputDir, fmt.Sprintf("%s_%05d.fits", req.Prefix, i+1))
+		err = s.capture(fn, pos, req)
+		if err != nil {
+			return fmt.Errorf("position %d: %w", i, err)
+		}
+		s.mu.Lock()
+		s.progress.Done++
+		s.progress.Files = append(s.progress.Files, fn)
+		s.job.Progress(100 * float64(s.progress.Done) / float64(s.progress.Total))
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// goTo moves each axis to its coordinate in pos, waits for them all to be in
+// position, then waits for the settle time, in seconds
+func (s *Scanner) goTo(axes []string, pos []float64, settle float64, abort chan struct{}) error {
+	for j, axis := range axes {
+		err := s.move(axis, pos[j])
+		if err != nil {
+			return err
+		}
+	}
+	for _, axis := range axes {
+		err := s.waitInPosition(axis, abort)
+		if err != nil {
+			return err
+		}
+	}
+	select {
+	case <-abort:
+		return ErrAborted
+	case <-time.After(util.SecsToDuration(settle)):
+	}
+	return nil
+}
+
+// move commands an absolute move of one axis
+func (s *Scanner) move(axis string, pos float64) error {
+	body, err := json.Marshal(generichttp.FloatT{F64: pos})
+	if err != nil {
+		return err
+	}
+	u := s.Cfg.Motion + "/axis/" + url.PathEscape(axis) + "/pos"
+	resp, err := s.Client.Post(u, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// waitInPosition polls an axis until it is in position.  Controllers which
+// do not report being in position are only waited on by the settle time
+func (s *Scanner) waitInPosition(axis string, abort chan struct{}) error {
+	u := s.Cfg.Motion + "/axis/" + url.PathEscape(axis) + "/inposition"
+	deadline := time.Now().Add(util.SecsToDuration(s.Cfg.InPositionTimeout))
+	for {
+		resp, err := s.Client.Get(u)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+			return nil
+		}
+		var b generichttp.BoolT
+		err = checkResponse(resp)
+		if err == nil {
+			err = json.NewDecoder(resp.Body).Decode(&b)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if b.Bool {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("axis %s not in position after %f s", axis, s.Cfg.InPositionTimeout)
+		}
+		select {
+		case <-abort:
+			return ErrAborted
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// capture takes req.Average FITS frames with the position, settle time, and
+// number of frames in the header and writes them, or their mean, to fn
+func (s *Scanner) capture(fn string, pos []float64, req ScanRequest) error {
+	cards := make([]camera.ExtraCard, len(pos), len(pos)+2)
+	for i, p := range pos {
+		cards[i] = camera.ExtraCard{
+			Name:    fmt.Sprintf("POS%d", i+1),
+			Value:   p,
+			Comment: "position of axis " + s.Cfg.Axes[i]}
+	}
+	cards = append(cards,
+		camera.ExtraCard{Name: "SETTLE", Value: *req.SettleTime, Comment: "settle time after move, s"},
+		camera.ExtraCard{Name: "NAVG", Value: req.Average, Comment: "number of frames averaged"})
+	frames, err := s.frames(cards, req.Average)
+	if err != nil {
+		return err
+	}
+	return writeFrames(fn, frames)
+}
+
+// frames takes n FITS frames with cards added to their headers
+func (s *Scanner) frames(cards []camera.ExtraCard, n int) ([][]byte, error) {
+	js, err := json.Marshal(cards)
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{}
+	q.Set("fmt", "fits")
+	q.Set("cards", string(js))
+	u := s.Cfg.Camera + "/image?" + q.Encode()
+	frames := make([][]byte, n)
+	for i := range frames {
+		frames[i], err = s.get(u)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return frames, nil
+}
+
+// writeFrames writes one FITS frame, or the mean of several, to fn
+func writeFrames(fn string, frames [][]byte) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if len(frames) == 1 {
+		_, err = f.Write(frames[0])
+		return err
+	}
+	return averageFits(f, frames)
+}
+
+// get returns the body of a GET request
+func (s *Scanner) get(u string) ([]byte, error) {
+	resp, err := s.Client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err = checkResponse(resp); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// averageFits writes the mean of FITS frames to w as 32-bit floats, with the
+// header of the first frame
+func averageFits(w io.Writer, frames [][]byte) error {
+	var (
+		width, height int
+		sum           []float64
+		cards         []fitsio.Card
+	)
+	for i, frame := range frames {
+		fw, fh, data, hdr, err := camera.ReadFitsFloat(bytes.NewReader(frame))
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			width, height, sum, cards = fw, fh, data, hdr
+			continue
+		}
+		if fw != width || fh != height {
+			return fmt.Errorf("frame %d is %dx%d, expected %dx%d", i, fw, fh, width, height)
+		}
+		for j, v := range data {
+			sum[j] += v
+		}
+	}
+	mean := make([]float32, len(sum))
+	for i, v := range sum {
+		mean[i] = float32(v / float64(len(frames)))
+	}
+	return camera.WriteFitsFloat(w, cards, width, height, mean)
+}
+
+// checkResponse returns an error containing the body if the status is not 200
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s: %s %s", resp.Request.URL.Path, resp.Status, bytes.TrimSpace(msg))
+}
+
+// StartScan begins a scan from the ScanRequest in the body
+func (s *Scanner) StartScan(w http.ResponseWriter, r *http.Request) {
+	var req ScanRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	defer r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = s.Start(req)
+	if err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, ErrScanRunning) {
+			code = http.StatusConflict
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	s.mu.Lock()
+	w.Header().Set("X-Job-Id", s.job.ID())
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+// AbortScan aborts the scan in progress
+func (s *Scanner) AbortScan(w http.ResponseWriter, r *http.Request) {
+	err := s.Abort()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetProgress responds with the Progress as JSON
+func (s *Scanner) GetProgress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(w).Encode(s.Progress())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// RT returns the route table of the scanner
+func (s *Scanner) RT() generichttp.RouteTable {
+	return generichttp.RouteTable{
+		generichttp.MethodPath{Method: http.MethodPost, Path: "/scan"}:       s.StartScan,
+		generichttp.MethodPath{Method: http.MethodGet, Path: "/scan"}:        s.GetProgress,
+		generichttp.MethodPath{Method: http.MethodPost, Path: "/scan/abort"}: s.AbortScan,
+
+		generichttp.MethodPath{Method: http.MethodPost, Path: "/focus"}:       s.StartFocusSweep,
+		generichttp.MethodPath{Method: http.MethodGet, Path: "/focus"}:        s.GetFocusProgress,
+		generichttp.MethodPath{Method: http.MethodPost, Path: "/focus/abort"}: s.AbortFocusSweep,
+
+		generichttp.MethodPath{Method: http.MethodGet, Path: "/version"}: generichttp.GetVersion("scansrv", Version, nil),
+	}
+}
@@ -210,6 +210,69 @@ func NewTerminator(rw io.ReadWriter, Rx, Tx byte) Terminator {
 	return Terminator{w: rw, r: rw, Wterm: Tx, Rterm: Rx}
 }
 
+// Terminators describes how an ASCII device frames its messages.  A driver
+// declares its terminators once and wraps each connection with Wrap.
+type Terminators struct {
+	// Tx is appended to every message written
+	Tx []byte
+
+	// Rx ends every message read, and is stripped from it.  If empty,
+	// reads are passed through untouched
+	Rx []byte
+}
+
+var (
+	// CR terminates messages in both directions with a carriage return
+	CR = Terminators{Tx: []byte{'\r'}, Rx: []byte{'\r'}}
+
+	// LF terminates messages in both directions with a newline
+	LF = Terminators{Tx: []byte{'\n'}, Rx: []byte{'\n'}}
+
+	// CRLF terminates messages in both directions with a carriage return and newline
+	CRLF = Terminators{Tx: []byte("\r\n"), Rx: []byte("\r\n")}
+)
+
+// Wrap returns a Read/Writer around rw which appends Tx to writes and reads
+// up to and strips Rx
+func (t Terminators) Wrap(rw io.ReadWriter) io.ReadWriter {
+	return terminated{rw: rw, t: t}
+}
+
+type terminated struct {
+	rw io.ReadWriter
+	t  Terminators
+}
+
+// Write implements io.Writer.  The returned count does not include Tx
+func (t terminated) Write(b []byte) (int, error) {
+	msg := make([]byte, 0, len(b)+len(t.t.Tx))
+	msg = append(append(msg, b...), t.t.Tx...)
+	n, err := t.rw.Write(msg)
+	if n > len(b) {
+		n = len(b)
+	}
+	return n, err
+}
+
+// Read implements io.Reader.  The input is scanned up to the first encounter
+// of Rx, which is stripped from the message and the remainder returned.
+func (t terminated) Read(buf []byte) (int, error) {
+	if len(t.t.Rx) == 0 {
+		return t.rw.Read(buf)
+	}
+	br := bufio.NewReader(t.rw)
+	last := t.t.Rx[len(t.t.Rx)-1]
+	var msg []byte
+	for !bytes.HasSuffix(msg, t.t.Rx) {
+		b, err := br.ReadBytes(last)
+		msg = append(msg, b...)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return copy(buf, msg[:len(msg)-len(t.t.Rx)]), nil
+}
+
 type deadlineWriter interface {
 	io.Writer
 	SetWriteDeadline(t time.Time) error
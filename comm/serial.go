@@ -0,0 +1,88 @@
+package comm
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Leaser gives out exclusive use of a connection for one exchange with a
+// device.  Both Pool and SerialConn are Leasers.
+type Leaser interface {
+	// Get acquires the connection.  If the error is nil, the connection must
+	// be returned with ReturnWithError
+	Get() (io.ReadWriter, error)
+
+	// ReturnWithError releases the connection.  It is closed if err is not nil
+	ReturnWithError(io.ReadWriter, error)
+}
+
+// SerialConn holds a single connection to a device, typically a serial port,
+// and serializes command/response pairs on it.  Between Get and
+// ReturnWithError, no other caller may use the connection, so the bytes of
+// concurrent requests are never interleaved on the wire.
+//
+// Serial ports do not support deadlines, so a per-command timeout is enforced
+// by closing the connection if it is not returned in time, which unblocks any
+// read or write in progress.  The connection is re-opened by the next Get.
+//
+// SerialConns must be created with NewSerialConn.
+type SerialConn struct {
+	mu      sync.Mutex
+	maker   CreationFunc
+	timeout time.Duration
+	conn    io.ReadWriteCloser
+	timer   *time.Timer
+}
+
+// NewSerialConn returns a new SerialConn which opens its connection with maker
+// when first needed.  If timeout is zero, DefaultTimeout is used.
+func NewSerialConn(maker CreationFunc, timeout time.Duration) *SerialConn {
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	return &SerialConn{maker: maker, timeout: timeout}
+}
+
+// Get acquires the connection, blocking until any other caller returns it, and
+// opening it if needed.  The connection is closed if it is not returned within
+// the timeout, after which its reads and writes will fail.
+func (s *SerialConn) Get() (io.ReadWriter, error) {
+	s.mu.Lock()
+	if s.conn == nil {
+		conn, err := s.maker()
+		if err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+		s.conn = conn
+	}
+	conn := s.conn
+	s.timer = time.AfterFunc(s.timeout, func() { conn.Close() })
+	return conn, nil
+}
+
+// ReturnWithError releases the connection.  If err is not nil or the timeout
+// elapsed, the connection is closed and will be re-opened by the next Get.
+func (s *SerialConn) ReturnWithError(rw io.ReadWriter, err error) {
+	expired := !s.timer.Stop()
+	if expired {
+		s.conn = nil
+	} else if err != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	s.mu.Unlock()
+}
+
+// Close closes the connection if it is open
+func (s *SerialConn) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
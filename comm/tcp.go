@@ -0,0 +1,54 @@
+package comm
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+// DefaultKeepAlive is the TCP keepalive period used by NewTCPConn
+const DefaultKeepAlive = 15 * time.Second
+
+// KeepAliveTCPConnMaker is like BackingOffTCPConnMaker, but enables TCP
+// keepalive on the connection.  Serial-to-Ethernet bridges commonly drop idle
+// connections without a FIN; keepalive lets the operating system notice, so
+// the next command fails promptly instead of hanging on a dead socket
+func KeepAliveTCPConnMaker(address string, timeout, keepAlive time.Duration) CreationFunc {
+	return func() (io.ReadWriteCloser, error) {
+		var (
+			conn net.Conn
+			err  error
+		)
+		d := net.Dialer{Timeout: timeout, KeepAlive: keepAlive}
+		op := func() error {
+			conn, err = d.Dial("tcp", address)
+			return err
+		}
+		err = backoff.Retry(op, &backoff.ExponentialBackOff{
+			InitialInterval:     100 * time.Millisecond,
+			RandomizationFactor: 0,
+			Multiplier:          2,
+			MaxInterval:         20 * time.Second,
+			MaxElapsedTime:      30 * time.Second,
+			Clock:               backoff.SystemClock})
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	}
+}
+
+// NewTCPConn returns a SerialConn holding one TCP connection to a serial
+// device behind a serial-to-Ethernet bridge at address (host:port).
+//
+// The bridge passes a single serial line, so exchanges must not overlap; the
+// SerialConn serializes them.  The connection is dialed with keepalive and
+// backoff when first needed, and is closed and re-dialed on the next command
+// after any error, so a connection the bridge has dropped is replaced rather
+// than used forever.  dialTimeout bounds each dial attempt; timeout bounds
+// each command and is DefaultTimeout if zero.
+func NewTCPConn(address string, dialTimeout, timeout time.Duration) *SerialConn {
+	return NewSerialConn(KeepAliveTCPConnMaker(address, dialTimeout, DefaultKeepAlive), timeout)
+}
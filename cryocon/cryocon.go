@@ -49,9 +49,8 @@ type TemperatureMonitor struct {
 
 // NewTemperatureMonitor creates a new temperature monitor instance
 func NewTemperatureMonitor(addr string) *TemperatureMonitor {
-	maker := comm.BackingOffTCPConnMaker(addr, time.Second)
-	pool := comm.NewPool(1, 10*time.Second, maker)
-	return &TemperatureMonitor{scpi.SCPI{Pool: pool}}
+	conn := comm.NewTCPConn(addr, time.Second, comm.DefaultTimeout)
+	return &TemperatureMonitor{scpi.SCPI{Pool: conn}}
 }
 
 // Identification returns the identifying information from the monitor.
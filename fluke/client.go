@@ -0,0 +1,46 @@
+package fluke
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Client reads a DewK served over HTTP, e.g. by multiserver.  It satisfies
+// generichttp/camera.EnvironmentProvider
+type Client struct {
+	// Addr is the base URL of the sensor, e.g. http://localhost:8000/dewk
+	Addr string
+
+	// HTTP is used for all requests
+	HTTP *http.Client
+}
+
+// NewClient returns a new Client for the sensor at addr.  Requests time out
+// after two seconds so that a missing sensor does not hold up its callers
+func NewClient(addr string) *Client {
+	return &Client{Addr: addr, HTTP: &http.Client{Timeout: 2 * time.Second}}
+}
+
+// Read gets the temperature and humidity from the sensor
+func (c *Client) Read() (TempHumid, error) {
+	var th TempHumid
+	resp, err := c.HTTP.Get(c.Addr + "/read")
+	if err != nil {
+		return th, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return th, fmt.Errorf("%s/read: %s", c.Addr, resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&th)
+	return th, err
+}
+
+// ReadEnvironment returns the temperature in Celsius and relative humidity
+// in percent
+func (c *Client) ReadEnvironment() (float64, float64, error) {
+	th, err := c.Read()
+	return th.T, th.H, err
+}
@@ -12,6 +12,9 @@ import (
 // and serves data HTTP routes and meta HTTP routes (route list)
 type DewK struct {
 	pool *comm.Pool
+
+	// Terminators frame each message to and from the sensor
+	Terminators comm.Terminators
 }
 
 // NewDewK creates a new DewK instance
@@ -21,7 +24,7 @@ func NewDewK(addr string) *DewK {
 	}
 	maker := comm.BackingOffTCPConnMaker(addr, time.Second)
 	pool := comm.NewPool(1, time.Minute, maker)
-	return &DewK{pool: pool}
+	return &DewK{pool: pool, Terminators: comm.LF}
 }
 
 // Read polls the DewK for the current temperature and humidity, opening and closing a connection along the way
@@ -32,7 +35,7 @@ func (dk *DewK) Read() (TempHumid, error) {
 		return ret, err
 	}
 	defer func() { dk.pool.ReturnWithError(conn, err) }()
-	wrap := comm.NewTerminator(conn, '\n', '\n')
+	wrap := dk.Terminators.Wrap(conn)
 	_, err = io.WriteString(wrap, "read?")
 	if err != nil {
 		return ret, err
@@ -44,3 +47,10 @@ func (dk *DewK) Read() (TempHumid, error) {
 	}
 	return ParseTHFromBuffer(buf[:n])
 }
+
+// ReadEnvironment returns the temperature in Celsius and relative humidity
+// in percent
+func (dk *DewK) ReadEnvironment() (float64, float64, error) {
+	th, err := dk.Read()
+	return th.T, th.H, err
+}
@@ -0,0 +1,232 @@
+package camera
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// AOIPresets is a registry of named AOIs, such as a full frame or a centered
+// 512x512 window, that operators switch between without re-entering pixel
+// coordinates.  The presets are kept in a JSON file so they survive restarts
+type AOIPresets struct {
+	// A is the camera presets are read from and applied to
+	A AOIManipulator
+
+	// Path is the JSON file the presets are kept in.  If empty, they are only
+	// held in memory
+	Path string
+
+	mu      sync.Mutex
+	presets map[string]AOI
+}
+
+// Load reads the presets from Path.  A missing file is not an error; it is
+// created when the first preset is saved
+func (p *AOIPresets) Load() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.presets = map[string]AOI{}
+	if p.Path == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(p.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(b, &p.presets)
+	if err != nil {
+		return fmt.Errorf("AOI presets %s: %w", p.Path, err)
+	}
+	return nil
+}
+
+// store writes the presets to Path through a temporary file, so a crash does
+// not leave it truncated.  p.mu must be held
+func (p *AOIPresets) store() error {
+	if p.Path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(p.presets, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(p.Path), filepath.Base(p.Path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(b)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p.Path)
+}
+
+// Save stores aoi as the preset name, replacing any preset of that name.  If
+// aoi is nil, the camera's current AOI is saved
+func (p *AOIPresets) Save(name string, aoi *AOI) error {
+	if name == "" {
+		return fmt.Errorf("AOI preset name must not be empty")
+	}
+	var a AOI
+	if aoi == nil {
+		var err error
+		a, err = p.A.GetAOI()
+		if err != nil {
+			return err
+		}
+	} else {
+		a = *aoi
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.presets == nil {
+		p.presets = map[string]AOI{}
+	}
+	prev, existed := p.presets[name]
+	p.presets[name] = a
+	err := p.store()
+	if err != nil {
+		// keep memory in step with the file
+		if existed {
+			p.presets[name] = prev
+		} else {
+			delete(p.presets, name)
+		}
+	}
+	return err
+}
+
+// Delete removes a preset
+func (p *AOIPresets) Delete(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prev, ok := p.presets[name]
+	if !ok {
+		return fmt.Errorf("no AOI preset named %q", name)
+	}
+	delete(p.presets, name)
+	err := p.store()
+	if err != nil {
+		p.presets[name] = prev
+	}
+	return err
+}
+
+// Get returns a preset
+func (p *AOIPresets) Get(name string) (AOI, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a, ok := p.presets[name]
+	return a, ok
+}
+
+// Names returns the names of the presets in sorted order
+func (p *AOIPresets) Names() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, 0, len(p.presets))
+	for k := range p.presets {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply sets the camera's AOI to a preset
+func (p *AOIPresets) Apply(name string) error {
+	a, ok := p.Get(name)
+	if !ok {
+		return fmt.Errorf("no AOI preset named %q", name)
+	}
+	return p.A.SetAOI(a)
+}
+
+// ListPresets responds with the presets as a JSON object keyed by name
+func (p *AOIPresets) ListPresets(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	js, err := json.Marshal(p.presets)
+	p.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// SavePreset saves the AOI in the body, or the current AOI if the body is
+// empty, as the preset named in the URL
+func (p *AOIPresets) SavePreset(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	var aoi *AOI
+	if r.ContentLength != 0 {
+		aoi = &AOI{}
+		err := json.NewDecoder(r.Body).Decode(aoi)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	err := p.Save(name, aoi)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// DeletePreset deletes the preset named in the URL
+func (p *AOIPresets) DeletePreset(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if _, ok := p.Get(name); !ok {
+		http.Error(w, fmt.Sprintf("no AOI preset named %q", name), http.StatusNotFound)
+		return
+	}
+	err := p.Delete(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// ApplyPreset sets the camera's AOI to the preset named in the URL
+func (p *AOIPresets) ApplyPreset(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if _, ok := p.Get(name); !ok {
+		http.Error(w, fmt.Sprintf("no AOI preset named %q", name), http.StatusNotFound)
+		return
+	}
+	err := p.Apply(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Inject puts AOI preset routes on a table
+func (p *AOIPresets) Inject(table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/aoi-presets"}] = p.ListPresets
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/aoi-presets/{name}"}] = p.SavePreset
+	table[generichttp.MethodPath{Method: http.MethodDelete, Path: "/aoi-presets/{name}"}] = p.DeletePreset
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/aoi/apply/{name}"}] = p.ApplyPreset
+}
@@ -0,0 +1,211 @@
+package camera
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"net/http"
+
+	"github.com/nasa-jpl/golaborate/util"
+)
+
+// DefaultAutoExposureIterations is the number of frames taken by AutoExpose
+// if no limit is given
+const DefaultAutoExposureIterations = 10
+
+// saturationLevel is the 16-bit DN at or above which a frame is considered
+// saturated, and the exposure is halved instead of scaled
+const saturationLevel = 65000
+
+// AutoExposureRequest holds the parameters to AutoExpose.  Times are in seconds
+// and levels in DN
+type AutoExposureRequest struct {
+	// Target is the desired level of the measured pixel
+	Target float64 `json:"target"`
+
+	// Tolerance is the allowed distance from Target
+	Tolerance float64 `json:"tolerance"`
+
+	// Percentile is the percentile of pixel values which is measured, (0,100].
+	// 100, or zero, uses the peak pixel
+	Percentile float64 `json:"percentile"`
+
+	// MinExposure is the shortest exposure time which may be used
+	MinExposure float64 `json:"minExposure"`
+
+	// MaxExposure is the longest exposure time which may be used
+	MaxExposure float64 `json:"maxExposure"`
+
+	// MaxIterations is the most frames which may be taken.  Zero uses
+	// DefaultAutoExposureIterations
+	MaxIterations int `json:"maxIterations"`
+}
+
+// AutoExposureResult is the outcome of AutoExpose.  Times are in seconds
+// and levels in DN
+type AutoExposureResult struct {
+	// Exposure is the last exposure time used, which is left set on the camera
+	Exposure float64 `json:"exposure"`
+
+	// Level is the measured level of the last frame
+	Level float64 `json:"level"`
+
+	// Iterations is the number of frames taken
+	Iterations int `json:"iterations"`
+
+	// Converged is true if Level is within the tolerance of the target
+	Converged bool `json:"converged"`
+}
+
+// Validate checks the request and fills in defaults
+func (a *AutoExposureRequest) Validate() error {
+	if a.Target <= 0 || a.Target >= saturationLevel {
+		return fmt.Errorf("target must be in (0, %d), got %f", saturationLevel, a.Target)
+	}
+	if a.Tolerance <= 0 {
+		return errors.New("tolerance must be greater than zero")
+	}
+	if a.Percentile == 0 {
+		a.Percentile = 100
+	}
+	if a.Percentile < 0 || a.Percentile > 100 {
+		return fmt.Errorf("percentile must be in (0,100], got %f", a.Percentile)
+	}
+	if a.MinExposure <= 0 || a.MaxExposure < a.MinExposure {
+		return fmt.Errorf("exposure bounds must satisfy 0 < min <= max, got [%f, %f]", a.MinExposure, a.MaxExposure)
+	}
+	if a.MaxIterations == 0 {
+		a.MaxIterations = DefaultAutoExposureIterations
+	}
+	if a.MaxIterations < 0 {
+		return errors.New("maxIterations must be positive")
+	}
+	return nil
+}
+
+// PercentileLevel returns the given percentile (0,100] of the pixel values of
+// a 16-bit grayscale image
+func PercentileLevel(img image.Image, percentile float64) (float64, error) {
+	g16, ok := img.(*image.Gray16)
+	if !ok {
+		return 0, ErrNotGray16
+	}
+	uints := bytesToUint(g16.Pix)
+	if len(uints) == 0 {
+		return 0, errors.New("image is empty")
+	}
+	// a histogram is linear time, sorting megapixel frames is not
+	hist := fullHistogram(uints)
+	return float64(histPercentile(hist, len(uints), percentile)), nil
+}
+
+// fullHistogram returns the number of pixels at each 16-bit value
+func fullHistogram(uints []uint16) *[65536]int {
+	var hist [65536]int
+	for _, v := range uints {
+		hist[v]++
+	}
+	return &hist
+}
+
+// histPercentile returns the given percentile (0,100] of the n values counted
+// in hist
+func histPercentile(hist *[65536]int, n int, percentile float64) uint16 {
+	rank := int(math.Ceil(percentile / 100 * float64(n)))
+	if rank < 1 {
+		rank = 1
+	}
+	count := 0
+	for v := 0; v < len(hist); v++ {
+		count += hist[v]
+		if count >= rank {
+			return uint16(v)
+		}
+	}
+	return 65535
+}
+
+// AutoExpose iteratively takes frames and adjusts the exposure time until the
+// given percentile of the frame is within tolerance of the target, or the
+// iteration limit is reached.  The exposure is scaled assuming the signal is
+// proportional to exposure time; saturated frames halve it and empty frames
+// double it.  The error is only non-nil if the request is invalid or the
+// camera generates an error.
+func AutoExpose(p PictureTaker, req AutoExposureRequest) (AutoExposureResult, error) {
+	var res AutoExposureResult
+	if err := req.Validate(); err != nil {
+		return res, err
+	}
+	d, err := p.GetExposureTime()
+	if err != nil {
+		return res, err
+	}
+	texp := util.Clamp(d.Seconds(), req.MinExposure, req.MaxExposure)
+	for res.Iterations < req.MaxIterations {
+		err = p.SetExposureTime(util.SecsToDuration(texp))
+		if err != nil {
+			return res, err
+		}
+		img, err := p.GetFrame()
+		if err != nil {
+			return res, err
+		}
+		res.Iterations++
+		res.Exposure = texp
+		res.Level, err = PercentileLevel(img, req.Percentile)
+		if err != nil {
+			return res, err
+		}
+		if math.Abs(res.Level-req.Target) <= req.Tolerance {
+			res.Converged = true
+			return res, nil
+		}
+		var next float64
+		switch {
+		case res.Level >= saturationLevel:
+			next = texp / 2
+		case res.Level <= 0:
+			next = texp * 2
+		default:
+			next = texp * req.Target / res.Level
+		}
+		next = util.Clamp(next, req.MinExposure, req.MaxExposure)
+		if next == texp {
+			// pinned against a bound, more frames will not help
+			return res, nil
+		}
+		texp = next
+	}
+	return res, nil
+}
+
+// AutoExposure returns an HTTP handler func which runs AutoExpose with the
+// AutoExposureRequest in the body and responds with the AutoExposureResult
+func AutoExposure(p PictureTaker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req AutoExposureRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = req.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err := AutoExpose(p, req)
+		if err != nil {
+			http.Error(w, err.Error(), acquisitionErrorCode(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(res)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package camera
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math"
+
+	"github.com/astrogo/fitsio"
+)
+
+// AverageFrames returns the mean of first and n-1 more frames from p, in
+// row-major order.  The frames must be 16-bit grayscale and the same size
+func AverageFrames(p Camera, first image.Image, n int) ([]float64, error) {
+	g16, ok := first.(*image.Gray16)
+	if !ok {
+		return nil, ErrNotGray16
+	}
+	b := g16.Bounds()
+	sum := make([]float64, b.Dx()*b.Dy())
+	add := func(g *image.Gray16) {
+		if len(g.Pix) == 0 {
+			return
+		}
+		for i, v := range bytesToUint(g.Pix) {
+			sum[i] += float64(v)
+		}
+	}
+	add(g16)
+	for i := 1; i < n; i++ {
+		img, err := p.GetFrame()
+		if err != nil {
+			return nil, err
+		}
+		g, ok := img.(*image.Gray16)
+		if !ok {
+			return nil, ErrNotGray16
+		}
+		if gb := g.Bounds(); gb.Dx() != b.Dx() || gb.Dy() != b.Dy() {
+			return nil, fmt.Errorf("frame %d is %dx%d, expected %dx%d", i+1, gb.Dx(), gb.Dy(), b.Dx(), b.Dy())
+		}
+		add(g)
+	}
+	for i := range sum {
+		sum[i] /= float64(n)
+	}
+	return sum, nil
+}
+
+// meanToGray16 rounds a mean from AverageFrames to a 16-bit image
+func meanToGray16(mean []float64, b image.Rectangle) *image.Gray16 {
+	out := image.NewGray16(b)
+	if len(out.Pix) == 0 {
+		return out
+	}
+	uints := bytesToUint(out.Pix)
+	for i, v := range mean {
+		uints[i] = uint16(math.Round(math.Min(math.Max(v, 0), math.MaxUint16)))
+	}
+	return out
+}
+
+// WriteFitsFloat writes one frame of 32-bit floats, such as the mean of
+// several frames, to w as FITS, preserving fractional DN
+func WriteFitsFloat(w io.Writer, metadata []fitsio.Card, width, height int, data []float32) error {
+	fits, err := fitsio.Create(w)
+	if err != nil {
+		return err
+	}
+	defer fits.Close()
+	im := fitsio.NewImage(-32, []int{width, height})
+	defer im.Close()
+	err = im.Header().Append(metadata...)
+	if err != nil {
+		return err
+	}
+	err = im.Write(data)
+	if err != nil {
+		return err
+	}
+	return fits.Write(im)
+}
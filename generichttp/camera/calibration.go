@@ -0,0 +1,372 @@
+package camera
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/astrogo/fitsio"
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/imgrec"
+)
+
+// ErrNotGray16 is generated when calibration is applied to an image which is
+// not 16-bit grayscale
+var ErrNotGray16 = errors.New("calibration requires a 16-bit grayscale image")
+
+// flatFloor is the smallest normalized flat value which is divided by.
+// Pixels of the flat below this are treated as dead and not flat-corrected
+const flatFloor = 1e-6
+
+// calFrame is a calibration frame held in floating point
+type calFrame struct {
+	w, h int
+	data []float64
+}
+
+// Calibrator holds optional bias, dark, and flat frames and applies them to
+// images.  The calibrated image is
+//
+//	(frame - bias - dark) / flat
+//
+// where flat is normalized to unit mean when it is loaded.  If a bias is
+// used, the dark and flat should already have the bias subtracted.  Any frame
+// which has not been loaded is skipped.  The zero value is ready to use.
+type Calibrator struct {
+	mu   sync.Mutex
+	bias *calFrame
+	dark *calFrame
+	flat *calFrame
+}
+
+// SetFrame loads a calibration frame of the given kind, one of bias, dark, or flat.
+// w and h are the width and height of the frame, and data is row-major.
+func (c *Calibrator) SetFrame(kind string, w, h int, data []float64) error {
+	if len(data) != w*h {
+		return fmt.Errorf("calibration %s has %d pixels, expected %dx%d", kind, len(data), w, h)
+	}
+	f := &calFrame{w: w, h: h, data: data}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch kind {
+	case "bias":
+		c.bias = f
+	case "dark":
+		c.dark = f
+	case "flat":
+		var sum float64
+		for _, v := range data {
+			sum += v
+		}
+		mean := sum / float64(len(data))
+		if mean == 0 {
+			return errors.New("calibration flat has zero mean")
+		}
+		norm := make([]float64, len(data))
+		for i, v := range data {
+			norm[i] = v / mean
+		}
+		f.data = norm
+		c.flat = f
+	default:
+		return fmt.Errorf("unknown calibration frame %s, must be bias, dark, or flat", kind)
+	}
+	return nil
+}
+
+// ClearFrame unloads a calibration frame of the given kind
+func (c *Calibrator) ClearFrame(kind string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch kind {
+	case "bias":
+		c.bias = nil
+	case "dark":
+		c.dark = nil
+	case "flat":
+		c.flat = nil
+	default:
+		return fmt.Errorf("unknown calibration frame %s, must be bias, dark, or flat", kind)
+	}
+	return nil
+}
+
+// Loaded returns which calibration frames are loaded
+func (c *Calibrator) Loaded() map[string]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return map[string]bool{
+		"bias": c.bias != nil,
+		"dark": c.dark != nil,
+		"flat": c.flat != nil,
+	}
+}
+
+// Apply calibrates img, which must be an *image.Gray16.  The math is done in
+// floating point and the result is rounded and clipped to [0, 65535].
+// img is not modified.
+func (c *Calibrator) Apply(img image.Image) (image.Image, error) {
+	g16, ok := img.(*image.Gray16)
+	if !ok {
+		return nil, ErrNotGray16
+	}
+	b := g16.Bounds()
+	w, h := b.Dx(), b.Dy()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for kind, f := range map[string]*calFrame{"bias": c.bias, "dark": c.dark, "flat": c.flat} {
+		if f != nil && (f.w != w || f.h != h) {
+			return nil, fmt.Errorf("calibration %s is %dx%d, image is %dx%d", kind, f.w, f.h, w, h)
+		}
+	}
+	in := bytesToUint(g16.Pix)
+	pix := make([]byte, len(g16.Pix))
+	out := bytesToUint(pix)
+	for i := 0; i < len(in); i++ {
+		v := float64(in[i])
+		if c.bias != nil {
+			v -= c.bias.data[i]
+		}
+		if c.dark != nil {
+			v -= c.dark.data[i]
+		}
+		if c.flat != nil && c.flat.data[i] > flatFloor {
+			v /= c.flat.data[i]
+		}
+		v = math.Round(v)
+		if v < 0 {
+			v = 0
+		} else if v > math.MaxUint16 {
+			v = math.MaxUint16
+		}
+		out[i] = uint16(v)
+	}
+	return &image.Gray16{Pix: pix, Stride: g16.Stride, Rect: b}, nil
+}
+
+// ReadFitsFloat reads the first image HDU of a FITS file as floats, returning
+// the width, height, pixels, and the header cards other than those which
+// describe the data layout.
+// 16-bit integer (with BZERO/BSCALE) and 32 or 64-bit float images are supported.
+func ReadFitsFloat(r io.Reader) (int, int, []float64, []fitsio.Card, error) {
+	f, err := fitsio.Open(r)
+	if err != nil {
+		return 0, 0, nil, nil, err
+	}
+	defer f.Close()
+	hdu, ok := f.HDU(0).(fitsio.Image)
+	if !ok {
+		return 0, 0, nil, nil, errors.New("primary HDU is not an image")
+	}
+	hdr := hdu.Header()
+	axes := hdr.Axes()
+	if len(axes) != 2 {
+		return 0, 0, nil, nil, fmt.Errorf("frame must be 2D, got %d axes", len(axes))
+	}
+	w, h := axes[0], axes[1]
+	n := w * h
+	out := make([]float64, n)
+	switch hdr.Bitpix() {
+	case 16:
+		buf := make([]int16, n)
+		if err = hdu.Read(&buf); err != nil {
+			return 0, 0, nil, nil, err
+		}
+		zero, scale := cardFloat(hdr.Get("BZERO"), 0), cardFloat(hdr.Get("BSCALE"), 1)
+		for i, v := range buf {
+			out[i] = float64(v)*scale + zero
+		}
+	case -32:
+		buf := make([]float32, n)
+		if err = hdu.Read(&buf); err != nil {
+			return 0, 0, nil, nil, err
+		}
+		for i, v := range buf {
+			out[i] = float64(v)
+		}
+	case -64:
+		if err = hdu.Read(&out); err != nil {
+			return 0, 0, nil, nil, err
+		}
+	default:
+		return 0, 0, nil, nil, fmt.Errorf("unsupported BITPIX %d, must be 16, -32, or -64", hdr.Bitpix())
+	}
+	var cards []fitsio.Card
+	for _, key := range hdr.Keys() {
+		if _, reserved := reservedCards[key]; reserved {
+			continue
+		}
+		if c := hdr.Get(key); c != nil {
+			cards = append(cards, *c)
+		}
+	}
+	return w, h, out, cards, nil
+}
+
+// cardFloat returns the value of a numeric card, or def if it is missing
+func cardFloat(c *fitsio.Card, def float64) float64 {
+	if c == nil {
+		return def
+	}
+	switch v := c.Value.(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return def
+}
+
+// CalibratedCamera wraps a PictureTaker, calibrating each frame it returns
+type CalibratedCamera struct {
+	PictureTaker
+
+	Cal *Calibrator
+}
+
+// GetFrame takes a frame from the underlying camera and calibrates it
+func (c CalibratedCamera) GetFrame() (image.Image, error) {
+	img, err := c.PictureTaker.GetFrame()
+	if err != nil {
+		return img, err
+	}
+	return c.Cal.Apply(img)
+}
+
+// CollectHeaderMetadata forwards to the underlying camera, if it makes
+// metadata, and records which calibration frames were applied
+func (c CalibratedCamera) CollectHeaderMetadata() []fitsio.Card {
+	var cards []fitsio.Card
+	if mm, ok := c.PictureTaker.(MetadataMaker); ok {
+		cards = mm.CollectHeaderMetadata()
+	}
+	loaded := c.Cal.Loaded()
+	return append(cards,
+		fitsio.Card{Name: "CALBIAS", Value: loaded["bias"], Comment: "bias subtracted"},
+		fitsio.Card{Name: "CALDARK", Value: loaded["dark"], Comment: "dark subtracted"},
+		fitsio.Card{Name: "CALFLAT", Value: loaded["flat"], Comment: "flat field corrected"})
+}
+
+// calibratedShutter is a CalibratedCamera of a FrameShutterController
+type calibratedShutter struct {
+	CalibratedCamera
+	FrameShutterController
+}
+
+// calibratedTimer is a CalibratedCamera of a FrameTimer
+type calibratedTimer struct {
+	CalibratedCamera
+	FrameTimer
+}
+
+// calibratedShutterTimer is a CalibratedCamera of a camera which is both a
+// FrameShutterController and a FrameTimer
+type calibratedShutterTimer struct {
+	CalibratedCamera
+	FrameShutterController
+	FrameTimer
+}
+
+// calibrate wraps p in a CalibratedCamera which keeps the optional interfaces
+// of p that GetFrame uses, FrameShutterController and FrameTimer
+func calibrate(p PictureTaker, cal *Calibrator) PictureTaker {
+	c := CalibratedCamera{PictureTaker: p, Cal: cal}
+	fs, isFS := p.(FrameShutterController)
+	ft, isFT := p.(FrameTimer)
+	switch {
+	case isFS && isFT:
+		return calibratedShutterTimer{c, fs, ft}
+	case isFS:
+		return calibratedShutter{c, fs}
+	case isFT:
+		return calibratedTimer{c, ft}
+	}
+	return c
+}
+
+// CalibrationWrapper exposes a Calibrator over HTTP
+type CalibrationWrapper struct {
+	// P is the camera which takes the pictures
+	P PictureTaker
+
+	// Cal holds the calibration frames
+	Cal *Calibrator
+
+	// Rec is the recorder used by /image, which may be nil
+	Rec *imgrec.Recorder
+}
+
+// UploadFrame loads a calibration frame from a FITS file in the request body.
+// The kind of frame is given by the kind URL parameter.
+func (c *CalibrationWrapper) UploadFrame(w http.ResponseWriter, r *http.Request) {
+	kind := chi.URLParam(r, "kind")
+	defer r.Body.Close()
+	fw, fh, data, _, err := ReadFitsFloat(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = c.Cal.SetFrame(kind, fw, fh, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// ClearFrame unloads a calibration frame.
+// The kind of frame is given by the kind URL parameter.
+func (c *CalibrationWrapper) ClearFrame(w http.ResponseWriter, r *http.Request) {
+	kind := chi.URLParam(r, "kind")
+	err := c.Cal.ClearFrame(kind)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Loaded returns a JSON object of which calibration frames are loaded
+func (c *CalibrationWrapper) Loaded(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(w).Encode(c.Cal.Loaded())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GetFrame serves /image, calibrating the frame if the calibrate query
+// parameter is true
+func (c *CalibrationWrapper) GetFrame(w http.ResponseWriter, r *http.Request) {
+	var p PictureTaker = c.P
+	if s := r.URL.Query().Get("calibrate"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if b {
+			p = calibrate(c.P, c.Cal)
+		}
+	}
+	GetFrame(p, c.Rec)(w, r)
+}
+
+// Inject puts calibration routes on a table, replacing the /image route with
+// one which calibrates on request
+func (c *CalibrationWrapper) Inject(table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/image"}] = c.GetFrame
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/calibration"}] = c.Loaded
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/calibration/{kind}"}] = c.UploadFrame
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/calibration/{kind}/clear"}] = c.ClearFrame
+}
@@ -3,21 +3,25 @@ package camera
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go/types"
 	"image"
 	"image/jpeg"
 	"image/png"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/astrogo/fitsio"
 	"github.com/go-chi/chi"
 	"github.com/nasa-jpl/golaborate/generichttp"
 	"github.com/nasa-jpl/golaborate/imgrec"
+	"github.com/nasa-jpl/golaborate/server/jobs"
 	"github.com/nasa-jpl/golaborate/util"
 )
 
@@ -191,6 +195,80 @@ type Burster interface {
 	Burst(int, float64, chan<- image.Image) error
 }
 
+// ErrAcquisitionTimeout is generated by cameras when no frame arrives before
+// the timeout, which usually means the camera is slow or was not triggered
+// rather than broken.  Camera packages wrap it in their own errors, so test
+// for it with errors.Is.  HTTP handlers respond to it with 504 Gateway
+// Timeout, so clients can tell it apart from an SDK error and retry
+var ErrAcquisitionTimeout = errors.New("timed out waiting for the camera to acquire a frame")
+
+// acquisitionErrorCode returns the HTTP status code for an error taking a
+// frame
+func acquisitionErrorCode(err error) int {
+	if errors.Is(err, ErrAcquisitionTimeout) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrAcquisitionBusy is generated when an acquisition is requested while
+// another is in progress
+type ErrAcquisitionBusy struct {
+	// InProgress is the kind of acquisition in progress, e.g. "burst"
+	InProgress string
+}
+
+func (e ErrAcquisitionBusy) Error() string {
+	return e.InProgress + " in progress"
+}
+
+// AcquisitionGuard tracks whether the camera is busy acquiring, so that
+// single frames and bursts are not interleaved.  The zero value is ready to
+// use, and a nil guard never reports busy.
+type AcquisitionGuard struct {
+	mu   sync.Mutex
+	busy string
+}
+
+// Acquire marks the camera busy with an acquisition of the given kind, or
+// returns ErrAcquisitionBusy if it already is
+func (g *AcquisitionGuard) Acquire(kind string) error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.busy != "" {
+		return ErrAcquisitionBusy{InProgress: g.busy}
+	}
+	g.busy = kind
+	return nil
+}
+
+// Release marks the camera idle
+func (g *AcquisitionGuard) Release() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.busy = ""
+}
+
+// Wrap returns a handler which holds the guard for the duration of h, or
+// responds 409 Conflict if the camera is busy
+func (g *AcquisitionGuard) Wrap(kind string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := g.Acquire(kind)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		defer g.Release()
+		h(w, r)
+	}
+}
+
 // BurstWrapper is a type that holds the internal buffer for a burst of camera
 // frames
 type BurstWrapper struct {
@@ -205,6 +283,10 @@ type BurstWrapper struct {
 
 	// frames is the number of frames in the burst
 	frames int
+
+	// Busy is shared with the single frame routes and held for the duration
+	// of the burst.  It may be nil
+	Busy *AcquisitionGuard
 }
 
 // SetupBurst returns a function which triggers the burst on the camera
@@ -223,10 +305,35 @@ func (b *BurstWrapper) SetupBurst(w http.ResponseWriter, r *http.Request) {
 	if t.Spool == 0 {
 		t.Spool = int(float64(t.Frames) * t.FPS)
 	}
+	err = b.Busy.Acquire("burst")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	b.ch = make(chan image.Image, t.Spool)
+	job := jobs.Default.Start("burst")
 	go func() {
-		b.err = b.B.Burst(t.Frames, t.FPS, b.ch)
+		defer b.Busy.Release()
+		// frames pass through src so they can be counted for the job, and so
+		// that b.err is set before b.ch is closed
+		src := make(chan image.Image)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- b.B.Burst(t.Frames, t.FPS, src)
+		}()
+		n := 0
+		for img := range src {
+			b.ch <- img
+			n++
+			if t.Frames > 0 {
+				job.Progress(100 * float64(n) / float64(t.Frames))
+			}
+		}
+		b.err = <-errCh
+		job.Finish(b.err)
+		close(b.ch)
 	}()
+	w.Header().Set("X-Job-Id", job.ID())
 	w.WriteHeader(http.StatusOK)
 	return
 }
@@ -241,7 +348,7 @@ func (b *BurstWrapper) ReadFrame(w http.ResponseWriter, r *http.Request) {
 		// if ch closed, err
 		if img == nil {
 			if b.err != nil {
-				http.Error(w, b.err.Error(), http.StatusInternalServerError)
+				http.Error(w, b.err.Error(), acquisitionErrorCode(b.err))
 				return
 			}
 			panic("generichttp/camera:burster nil img and nil err, unintelligible state")
@@ -302,11 +409,103 @@ type MetadataMaker interface {
 	CollectHeaderMetadata() []fitsio.Card
 }
 
+// HTTPMetadataMaker adds the metadata route to the table
+func HTTPMetadataMaker(m MetadataMaker, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/metadata"}] = GetMetadata(m)
+}
+
+// GetMetadata returns an HTTP handler func that responds with the cards
+// CollectHeaderMetadata would write to a FITS header, as a JSON array of
+// {"name", "value", "comment"} objects.  No frame is taken, so it is cheap
+// enough to log the camera's state every few seconds during an acquisition.
+// Values which are not finite numbers are given as strings, since JSON cannot
+// hold them
+func GetMetadata(m MetadataMaker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cards := m.CollectHeaderMetadata()
+		out := make([]ExtraCard, len(cards))
+		for i, c := range cards {
+			v := c.Value
+			if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+				v = strconv.FormatFloat(f, 'g', -1, 64)
+			} else if f, ok := v.(float32); ok && (math.IsNaN(float64(f)) || math.IsInf(float64(f), 0)) {
+				v = strconv.FormatFloat(float64(f), 'g', -1, 32)
+			}
+			out[i] = ExtraCard{Name: c.Name, Value: v, Comment: c.Comment}
+		}
+		js, err := json.Marshal(out)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(js)
+	}
+}
+
+// EnvironmentProvider reports the ambient conditions around a camera, for
+// example from a Fluke DewK
+type EnvironmentProvider interface {
+	// ReadEnvironment returns the ambient temperature in Celsius and the
+	// relative humidity in percent
+	ReadEnvironment() (float64, float64, error)
+}
+
+// EnvironmentCards reads env and returns the TAMB and RHUMID cards for a FITS
+// header.  If env cannot be read the cards are left blank with the error as
+// their comment, so an unreachable sensor never stops a frame from being
+// written.  If env is nil there are no cards
+func EnvironmentCards(env EnvironmentProvider) []fitsio.Card {
+	if env == nil {
+		return nil
+	}
+	t, rh, err := env.ReadEnvironment()
+	if err != nil {
+		msg := "unavailable: " + err.Error()
+		return []fitsio.Card{
+			{Name: "TAMB", Comment: msg},
+			{Name: "RHUMID", Comment: msg}}
+	}
+	return []fitsio.Card{
+		{Name: "TAMB", Value: t, Comment: "ambient temperature (Celsius)"},
+		{Name: "RHUMID", Value: rh, Comment: "ambient relative humidity, %"}}
+}
+
+// FrameTiming is when a frame was taken, by the host clock
+type FrameTiming struct {
+	// Start is the time the acquisition was started
+	Start time.Time
+
+	// Duration is the time from Start until the frame was ready, measured
+	// with the monotonic clock.  It includes the exposure and readout
+	Duration time.Duration
+}
+
+// FrameTimer is a camera which records when its last frame was taken
+type FrameTimer interface {
+	// GetFrameTiming returns the timing of the last frame from GetFrame
+	GetFrameTiming() (FrameTiming, error)
+}
+
+// FrameTimingCards returns the FRAMETS and FRAMEDUR cards for a FITS header.
+// FRAMETS is Start as RFC3339 in UTC with nanoseconds, since DATE only has
+// whole seconds
+func FrameTimingCards(t FrameTiming) []fitsio.Card {
+	return []fitsio.Card{
+		{Name: "FRAMETS", Value: t.Start.UTC().Format(time.RFC3339Nano), Comment: "acquisition start, host clock"},
+		{Name: "FRAMEDUR", Value: t.Duration.Seconds(), Comment: "acquisition start to frame ready, s"}}
+}
+
 // HTTPPicture injects HTTP methods into a route table for a picture taker
 func HTTPPicture(p PictureTaker, table generichttp.RouteTable, rec *imgrec.Recorder) {
 	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/exposure-time"}] = GetExposureTime(p)
 	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/exposure-time"}] = SetExposureTime(p)
 	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/image"}] = GetFrame(p, rec)
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/image/histogram"}] = GetHistogram(p)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/image/region-stats"}] = GetRegionStats(p)
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/image/focus-metric"}] = GetFocusMetric(p)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/auto-exposure"}] = AutoExposure(p)
 
 	if rec != nil {
 		rW := imgrec.NewHTTPWrapper(rec)
@@ -314,11 +513,21 @@ func HTTPPicture(p PictureTaker, table generichttp.RouteTable, rec *imgrec.Recor
 	}
 }
 
+// ExposureLimiter is a camera which knows the limits of its exposure time
+type ExposureLimiter interface {
+	// GetExposureTimeLimits returns the shortest and longest exposure times
+	GetExposureTimeLimits() (time.Duration, time.Duration, error)
+}
+
 // SetExposureTime sets the exposure time on a POST request.
 // it can be provided either as a query parameter exposureTime, formatted in a
 // way that is parsable by golang/time.ParseDuration, or a json payload with
 // key f64, holding the exposure time in seconds.
+//
+// if p is an ExposureLimiter, exposure times outside its limits are rejected
+// with a 400 before they are sent to the camera.
 func SetExposureTime(p PictureTaker) http.HandlerFunc {
+	limiter, hasLimits := p.(ExposureLimiter)
 	return func(w http.ResponseWriter, r *http.Request) {
 		q := r.URL.Query()
 		texp := q.Get("exposureTime")
@@ -335,6 +544,16 @@ func SetExposureTime(p PictureTaker) http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if hasLimits {
+			min, max, err := limiter.GetExposureTimeLimits()
+			if err == nil {
+				err = generichttp.FloatRange(min.Seconds(), max.Seconds())(d.Seconds())
+			}
+			if err != nil {
+				http.Error(w, "exposure time: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 		err = p.SetExposureTime(d)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -370,9 +589,79 @@ func GetExposureTime(p PictureTaker) http.HandlerFunc {
 // if no unit is appended, an s (seconds) is added.
 //
 // if no exposure time is provided, it is not updated and the existing value is used.
+//
+// jpg and png are downconverted to 8 bits with ScaleGray16.  The lo and hi
+// query parameters give the window in DN, by default the full 16-bit range,
+// and the stretch query parameter is linear, sqrt, or log.  The
+// X-Saturated-Pixels header holds the number of pixels at full scale in the
+// 16-bit data.  For png, if the markSaturated query parameter is true those
+// pixels are drawn in red.
+//
+// for fits, extra header cards may be given as a JSON array of
+// {"name", "value", "comment"} objects, either URL encoded in the cards query
+// parameter or as the request body.  They are merged with the camera's own
+// metadata, replacing any cards of the same name.
+//
+// the navg query parameter averages that many frames with AverageFrames.  For
+// fits-float, the mean is written as 32-bit floats, keeping the fractional
+// DN; every other format rounds it back to 16 bits.  FITS headers of averaged
+// frames hold the number of frames as NAVG.
+//
+// if p is a FrameShutterController, the shutter query parameter sets its
+// frame shutter mode, e.g. Light or Dark, for this request only.
+//
+// if p is a FrameTimer, the X-Frame-Start header holds the time the
+// acquisition started as RFC3339 with nanoseconds, and X-Frame-Duration the
+// seconds until the frame was ready.  When frames are averaged these are for
+// the last frame.
 func GetFrame(p Camera, rec *imgrec.Recorder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		q := r.URL.Query()
+		var extraCards []fitsio.Card
+		lo, hi, stretch, err := parseScale(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		format := q.Get("fmt")
+		if format == "" {
+			format = "jpg"
+		}
+		navg := 1
+		if s := q.Get("navg"); s != "" {
+			navg, err = strconv.Atoi(s)
+			if err != nil || navg < 1 {
+				http.Error(w, fmt.Sprintf("navg must be a positive integer, got %q", s), http.StatusBadRequest)
+				return
+			}
+		}
+		if format == "fits" || format == "fits-float" {
+			if cards := q.Get("cards"); cards != "" {
+				extraCards, err = ParseExtraCards(strings.NewReader(cards))
+			} else if r.ContentLength > 0 {
+				extraCards, err = ParseExtraCards(r.Body)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if mode := q.Get("shutter"); mode != "" {
+			fs, ok := interface{}(p).(FrameShutterController)
+			if !ok {
+				http.Error(w, "the camera cannot coordinate its shutter with frames", http.StatusBadRequest)
+				return
+			}
+			prev, err := fs.GetFrameShutter()
+			if err == nil {
+				err = fs.SetFrameShutter(mode)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer fs.SetFrameShutter(prev)
+		}
 		if pictureTaker, ok := interface{}(p).(PictureTaker); ok {
 			texp := q.Get("exposureTime")
 			if texp != "" {
@@ -393,47 +682,60 @@ func GetFrame(p Camera, rec *imgrec.Recorder) http.HandlerFunc {
 		}
 		img, err := p.GetFrame()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), acquisitionErrorCode(err))
 			return
 		}
+		var mean []float64
+		if navg > 1 || format == "fits-float" {
+			mean, err = AverageFrames(p, img, navg)
+			if err != nil {
+				http.Error(w, err.Error(), acquisitionErrorCode(err))
+				return
+			}
+			if navg > 1 {
+				img = meanToGray16(mean, img.Bounds())
+			}
+		}
 
-		format := q.Get("fmt")
-		if format == "" {
-			format = "jpg"
+		if ft, ok := interface{}(p).(FrameTimer); ok {
+			if t, err := ft.GetFrameTiming(); err == nil {
+				w.Header().Set("X-Frame-Start", t.Start.UTC().Format(time.RFC3339Nano))
+				w.Header().Set("X-Frame-Duration", strconv.FormatFloat(t.Duration.Seconds(), 'f', -1, 64))
+			}
+		}
+
+		recording := rec != nil && rec.Enabled && rec.Root != ""
+		if recording && rec.IsTIFF() {
+			// TIFFs are recorded for every frame, FITS only for fits requests
+			err = rec.WriteImage(img)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			rec.Incr()
 		}
 
 		switch format {
 		case "jpg":
 			w.Header().Set("Content-Type", "image/jpeg")
-			w.WriteHeader(http.StatusOK)
 			if g16, ok := (img).(*image.Gray16); ok {
-				uints := bytesToUint(g16.Pix)
-				b := make([]byte, len(uints))
-				l := len(uints)
-				for i := 0; i < l; i++ {
-					b[i] = byte(uints[i] / 255)
-				}
-				bound := g16.Bounds()
-				out := &image.Gray{Pix: b, Stride: bound.Dx(), Rect: bound}
-				img = out
+				var nsat int
+				img, nsat = downconvert(g16, lo, hi, stretch, false)
+				w.Header().Set("X-Saturated-Pixels", strconv.Itoa(nsat))
 			}
+			w.WriteHeader(http.StatusOK)
 			jpeg.Encode(w, img, nil)
 		case "png":
 			w.Header().Set("Content-Type", "image/png")
-			w.WriteHeader(http.StatusOK)
 			if g16, ok := (img).(*image.Gray16); ok {
-				uints := bytesToUint(g16.Pix)
-				b := make([]byte, len(uints))
-				l := len(uints)
-				for i := 0; i < l; i++ {
-					b[i] = byte(uints[i] / 255)
-				}
-				bound := g16.Bounds()
-				out := &image.Gray{Pix: b, Stride: bound.Dx(), Rect: bound}
-				img = out
+				var nsat int
+				mark, _ := strconv.ParseBool(q.Get("markSaturated"))
+				img, nsat = downconvert(g16, lo, hi, stretch, mark)
+				w.Header().Set("X-Saturated-Pixels", strconv.Itoa(nsat))
 			}
+			w.WriteHeader(http.StatusOK)
 			png.Encode(w, img)
-		case "fits":
+		case "fits", "fits-float":
 			// ^\- for picture taker::
 			// there is some cross logic, where picturetaker introspects whether the type
 			// exposes a recorder, to add the recorder logic into the fits write
@@ -447,8 +749,8 @@ func GetFrame(p Camera, rec *imgrec.Recorder) http.HandlerFunc {
 			// that is also introspected through a metadata interface
 			// declare a writer to use to stream the file to
 			var w2 io.Writer
-			if rec != nil && rec.Enabled && rec.Root != "" {
-				// if it is "", the recorder is not to be used
+			if recording && !rec.IsTIFF() {
+				// if Root is "", the recorder is not to be used
 				w2 = io.MultiWriter(w, rec)
 				defer rec.Incr()
 			} else {
@@ -458,12 +760,25 @@ func GetFrame(p Camera, rec *imgrec.Recorder) http.HandlerFunc {
 			if carder, ok := interface{}(p).(MetadataMaker); ok {
 				cards = carder.CollectHeaderMetadata()
 			}
+			if navg > 1 {
+				cards = MergeCards(cards, []fitsio.Card{{Name: "NAVG", Value: navg, Comment: "number of frames averaged"}})
+			}
+			cards = MergeCards(cards, extraCards)
 
 			hdr := w.Header()
 			hdr.Set("Content-Type", "image/fits")
 			hdr.Set("Content-Disposition", "attachment; filename=image.fits")
 			w.WriteHeader(http.StatusOK)
-			err = WriteFits(w2, cards, []image.Image{img})
+			if format == "fits-float" {
+				b := img.Bounds()
+				f32 := make([]float32, len(mean))
+				for i, v := range mean {
+					f32[i] = float32(v)
+				}
+				err = WriteFitsFloat(w2, cards, b.Dx(), b.Dy(), f32)
+			} else {
+				err = WriteFits(w2, cards, []image.Image{img})
+			}
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
@@ -473,6 +788,39 @@ func GetFrame(p Camera, rec *imgrec.Recorder) http.HandlerFunc {
 	}
 }
 
+// downconvert reduces a 16-bit image to 8 bits for previews with
+// ScaleGray16, returning the number of pixels at full scale.  If mark is true,
+// the output is RGBA with the full scale pixels drawn in red
+func downconvert(g16 *image.Gray16, lo, hi uint16, stretch Stretch, mark bool) (image.Image, int) {
+	gray := ScaleGray16(g16, lo, hi, stretch)
+	bound := g16.Bounds()
+	nsat := 0
+	if len(g16.Pix) == 0 {
+		return gray, nsat
+	}
+	uints := bytesToUint(g16.Pix)
+	if mark {
+		out := image.NewRGBA(bound)
+		for i, u := range uints {
+			v := gray.Pix[i]
+			px := out.Pix[4*i : 4*i+4]
+			if u == math.MaxUint16 {
+				nsat++
+				px[0], px[1], px[2], px[3] = 255, 0, 0, 255
+			} else {
+				px[0], px[1], px[2], px[3] = v, v, v, 255
+			}
+		}
+		return out, nsat
+	}
+	for _, u := range uints {
+		if u == math.MaxUint16 {
+			nsat++
+		}
+	}
+	return gray, nsat
+}
+
 // AOIManipulator is an interface to a camera's AOI manipulating functions
 type AOIManipulator interface {
 	// SetAOI allows the AOI to be set
@@ -572,6 +920,121 @@ func GetBinning(a AOIManipulator) http.HandlerFunc {
 	}
 }
 
+// FrameInfo describes the layout of a frame in the camera's raw buffer
+type FrameInfo struct {
+	// Width and Height are the dimensions of the frame in pixels
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// Stride is the length of one row in the buffer in bytes, including any
+	// padding at its end
+	Stride int `json:"stride"`
+
+	// BytesPerPixel may be fractional for packed encodings such as Mono12Packed
+	BytesPerPixel float64 `json:"bytesPerPixel"`
+
+	PixelEncoding string `json:"pixelEncoding"`
+
+	// ImageSizeBytes is the size of the whole buffer, which may include
+	// metadata after the last row
+	ImageSizeBytes int `json:"imageSizeBytes"`
+
+	// Padded is true if Stride is longer than a row of pixels, or
+	// ImageSizeBytes is longer than Height rows
+	Padded bool `json:"padded"`
+}
+
+// FrameInfoer describes a camera that can report the layout of its frames
+type FrameInfoer interface {
+	// GetFrameInfo returns the layout of a frame with the current settings
+	GetFrameInfo() (FrameInfo, error)
+}
+
+// HTTPFrameInfoer adds the frame info route to the table
+func HTTPFrameInfoer(f FrameInfoer, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/frame-info"}] = GetFrameInfo(f)
+}
+
+// GetFrameInfo returns an HTTP handler func that responds with the FrameInfo
+// as JSON
+func GetFrameInfo(f FrameInfoer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := f.GetFrameInfo()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(info)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// ConfigValidator describes a camera that can check a configuration without
+// applying it
+type ConfigValidator interface {
+	// ValidateConfig returns an error describing every setting which is not
+	// understood or has the wrong type
+	ValidateConfig(map[string]interface{}) error
+}
+
+// HTTPConfigValidator adds the config validation route to the table
+func HTTPConfigValidator(c ConfigValidator, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/config/validate"}] = ValidateConfig(c)
+}
+
+// configValidation is the response of ValidateConfig
+type configValidation struct {
+	Valid bool `json:"valid"`
+
+	// Errors is keyed by setting.  Problems not tied to one setting are
+	// keyed by "config"
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// ValidateConfig returns an HTTP handler func that checks the configuration
+// in the body, a JSON object as used for BootupArgs, without applying it.  It
+// responds with {"valid": true}, or with 400 and the problems found
+func ValidateConfig(c ConfigValidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		settings := map[string]interface{}{}
+		err := json.NewDecoder(r.Body).Decode(&settings)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := configValidation{Valid: true}
+		code := http.StatusOK
+		if err = c.ValidateConfig(settings); err != nil {
+			resp.Valid = false
+			resp.Errors = map[string]string{}
+			code = http.StatusBadRequest
+			errs := []error{err}
+			if me, ok := err.(util.MultiError); ok {
+				errs = me.Errors
+			}
+			for _, err := range errs {
+				key := "config"
+				if ke, ok := err.(util.KeyedError); ok {
+					key = ke.Key
+					err = ke.Err
+				}
+				resp.Errors[key] = err.Error()
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		err = json.NewEncoder(w).Encode(resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
 // EMGainManager describes an interface that can manage its electron multiplying gain
 type EMGainManager interface {
 	// GetEMGainMode returns how the EM gain is applied in the camera
@@ -686,6 +1149,23 @@ func HTTPShutterController(s ShutterController, table generichttp.RouteTable) {
 	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/shutter-auto"}] = SetShutterAuto(s)
 }
 
+// FrameShutterController is a camera which can coordinate its shutter with
+// each frame, for example opening it for lights and closing it for darks
+type FrameShutterController interface {
+	// SetFrameShutter sets the mode of coordination, e.g. Manual, Light, or Dark
+	SetFrameShutter(string) error
+
+	// GetFrameShutter returns the mode of coordination
+	GetFrameShutter() (string, error)
+}
+
+// HTTPFrameShutterController binds routes to control the frame shutter mode
+// to a route table
+func HTTPFrameShutterController(f FrameShutterController, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/shutter/frame-mode"}] = generichttp.GetString(f.GetFrameShutter)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/shutter/frame-mode"}] = generichttp.SetString(f.SetFrameShutter)
+}
+
 // ExtendedShutterController is a device which can manipulate its shutter speed
 type ExtendedShutterController interface {
 	ShutterController
@@ -743,6 +1223,297 @@ func GetShutterSpeed(e ExtendedShutterController) http.HandlerFunc {
 	}
 }
 
+// StabilityGate is a camera which can refuse to acquire until its sensor
+// temperature is stable
+type StabilityGate interface {
+	// SetRequireStabilized sets whether acquisition requires a stable temperature
+	SetRequireStabilized(bool) error
+
+	// GetRequireStabilized returns whether acquisition requires a stable temperature
+	GetRequireStabilized() (bool, error)
+}
+
+// HTTPStabilityGate binds routes to control the stability gate to a route table
+func HTTPStabilityGate(s StabilityGate, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/require-stabilized"}] = generichttp.GetBool(s.GetRequireStabilized)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/require-stabilized"}] = generichttp.SetBool(s.SetRequireStabilized)
+}
+
+// Orienter is a camera which carries orientation and plate scale metadata
+// for its FITS header
+type Orienter interface {
+	// SetOrientation sets the clockwise rotation of the image, in degrees
+	SetOrientation(float64) error
+
+	// GetOrientation returns the clockwise rotation of the image, in degrees
+	GetOrientation() (float64, error)
+
+	// SetPixelScale sets the plate scale in arcseconds per pixel
+	SetPixelScale(float64) error
+
+	// GetPixelScale returns the plate scale in arcseconds per pixel
+	GetPixelScale() (float64, error)
+}
+
+// HTTPOrienter binds routes to control orientation metadata to a route table
+func HTTPOrienter(o Orienter, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/orientation"}] = generichttp.GetFloat(o.GetOrientation)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/orientation"}] = generichttp.SetFloat(o.SetOrientation)
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/pixel-scale"}] = generichttp.GetFloat(o.GetPixelScale)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/pixel-scale"}] = generichttp.SetFloat(o.SetPixelScale)
+}
+
+// ReadoutRateInfo describes the consequences of a pixel readout rate
+type ReadoutRateInfo struct {
+	// PixelReadoutRate is the readout rate, e.g. "280 MHz"
+	PixelReadoutRate string `json:"pixelReadoutRate"`
+
+	// MaxFrameRate is the fastest achievable frame rate, Hz
+	MaxFrameRate float64 `json:"maxFrameRate"`
+
+	// ReadoutTime is the time to read out the sensor, seconds
+	ReadoutTime float64 `json:"readoutTime"`
+}
+
+// ReadoutRateExplorer is a camera which can report the frame rate and readout
+// time of a pixel readout rate before committing to it
+type ReadoutRateExplorer interface {
+	// PreviewPixelReadoutRate reports on a readout rate without changing it
+	PreviewPixelReadoutRate(string) (ReadoutRateInfo, error)
+
+	// SetPixelReadoutRate changes the readout rate and reports on it
+	SetPixelReadoutRate(string) (ReadoutRateInfo, error)
+}
+
+// HTTPReadoutRateExplorer binds routes to preview and set the readout rate to a route table
+func HTTPReadoutRateExplorer(e ReadoutRateExplorer, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/readout-rate/preview"}] = readoutRate(e.PreviewPixelReadoutRate)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/readout-rate"}] = readoutRate(e.SetPixelReadoutRate)
+}
+
+// readoutRate returns an HTTP handler func which calls fcn with the readout
+// rate in the body as {"str": rate} and responds with the ReadoutRateInfo
+func readoutRate(fcn func(string) (ReadoutRateInfo, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		str := generichttp.StrT{}
+		err := json.NewDecoder(r.Body).Decode(&str)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		info, err := fcn(str.Str)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(info)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// ShiftSpeeds are the horizontal and vertical shift speeds of a CCD
+type ShiftSpeeds struct {
+	// ADChannel is the A/D channel the horizontal shift speed is for
+	ADChannel int `json:"adChannel"`
+
+	// HSSpeedIndex and HSSpeed are the index and speed, MHz, of the
+	// horizontal shift register
+	HSSpeedIndex int     `json:"hsSpeedIndex"`
+	HSSpeed      float64 `json:"hsSpeed"`
+
+	// VSSpeedIndex and VSSpeed are the index and time to shift one row, us,
+	// of the vertical shift register
+	VSSpeedIndex int     `json:"vsSpeedIndex"`
+	VSSpeed      float64 `json:"vsSpeed"`
+}
+
+// ShiftSpeedSelector is a CCD which can pick the slowest or fastest shift
+// speeds it has for its present A/D channel
+type ShiftSpeedSelector interface {
+	// SelectSlowestReadout sets the slowest, lowest noise, shift speeds
+	SelectSlowestReadout() (ShiftSpeeds, error)
+
+	// SelectFastestReadout sets the fastest shift speeds
+	SelectFastestReadout() (ShiftSpeeds, error)
+}
+
+// HTTPShiftSpeedSelector binds routes to select the shift speeds to a route table
+func HTTPShiftSpeedSelector(s ShiftSpeedSelector, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/readout/slowest"}] = selectShiftSpeeds(s.SelectSlowestReadout)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/readout/fastest"}] = selectShiftSpeeds(s.SelectFastestReadout)
+}
+
+// selectShiftSpeeds returns an HTTP handler func which calls fcn and responds
+// with the ShiftSpeeds chosen
+func selectShiftSpeeds(fcn func() (ShiftSpeeds, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, err := fcn()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// BaselineManager is a camera with an adjustable baseline (bias) offset
+type BaselineManager interface {
+	// GetBaselineLevel returns the baseline offset in DN
+	GetBaselineLevel() (int, error)
+
+	// SetBaselineLevel sets the baseline offset in DN
+	SetBaselineLevel(int) error
+}
+
+// HTTPBaselineManager binds routes to control the baseline offset to a route table
+func HTTPBaselineManager(b BaselineManager, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/baseline-level"}] = generichttp.GetInt(b.GetBaselineLevel)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/baseline-level"}] = generichttp.SetInt(b.SetBaselineLevel)
+}
+
+// Accumulator is a camera which can sum several exposures into each frame
+// before readout
+type Accumulator interface {
+	// GetAccumulations returns the number of exposures summed into each frame
+	GetAccumulations() (int, error)
+
+	// SetAccumulations sets the number of exposures summed into each frame
+	SetAccumulations(int) error
+}
+
+// HTTPAccumulator binds routes to control accumulation to a route table
+func HTTPAccumulator(a Accumulator, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/accumulations"}] = generichttp.GetInt(a.GetAccumulations)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/accumulations"}] = generichttp.SetInt(a.SetAccumulations)
+}
+
+// AcquisitionDiagnostics holds counters which show if frames are being dropped
+type AcquisitionDiagnostics struct {
+	// AccumulatedCount is the number of images summed into each frame
+	AccumulatedCount int `json:"accumulatedCount"`
+
+	// FrameCount is the number of frames in the current acquisition
+	FrameCount int `json:"frameCount"`
+
+	// BufferOverflowEvent counts the times the camera's buffer overflowed
+	BufferOverflowEvent int `json:"bufferOverflowEvent"`
+
+	// EventsMissedEvent counts the events which were missed
+	EventsMissedEvent int `json:"eventsMissedEvent"`
+}
+
+// Diagnoser is a camera which can report its acquisition counters
+type Diagnoser interface {
+	// GetDiagnostics returns the current values of the counters
+	GetDiagnostics() (AcquisitionDiagnostics, error)
+}
+
+// HTTPDiagnoser binds the diagnostics route to a route table
+func HTTPDiagnoser(d Diagnoser, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/diagnostics"}] = GetDiagnostics(d)
+}
+
+// GetDiagnostics returns an HTTP handler func which responds with the
+// AcquisitionDiagnostics as JSON
+func GetDiagnostics(d Diagnoser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		diag, err := d.GetDiagnostics()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(diag)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// TemperatureDiagnostics is a snapshot of the state of sensor cooling
+type TemperatureDiagnostics struct {
+	// Temperature is the current sensor temperature, in C
+	Temperature float64 `json:"temperature"`
+
+	// Setpoint is the target sensor temperature, in C
+	Setpoint float64 `json:"setpoint"`
+
+	// Deviation is Temperature - Setpoint, in C
+	Deviation float64 `json:"deviation"`
+
+	// Status is the cooling status, if the camera reports one
+	Status string `json:"status,omitempty"`
+
+	// Options are the allowed setpoints, if the camera has a discrete set
+	Options []string `json:"options,omitempty"`
+}
+
+// TemperatureDiagnoser is a camera which can report on its sensor cooling
+type TemperatureDiagnoser interface {
+	// GetTemperatureDiagnostics returns the current state of cooling
+	GetTemperatureDiagnostics() (TemperatureDiagnostics, error)
+}
+
+// HTTPTemperatureDiagnoser binds the temperature diagnostics route to a route table
+func HTTPTemperatureDiagnoser(d TemperatureDiagnoser, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/temperature-diagnostics"}] = GetTemperatureDiagnostics(d)
+}
+
+// GetTemperatureDiagnostics returns an HTTP handler func which responds with
+// the TemperatureDiagnostics as JSON
+func GetTemperatureDiagnostics(d TemperatureDiagnoser) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		diag, err := d.GetTemperatureDiagnostics()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(diag)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// SoftResetter is a camera which can recover from a bad state without
+// restarting the process
+type SoftResetter interface {
+	// SoftReset stops acquisition and resets buffers, returning the image size in bytes
+	SoftReset() (int, error)
+}
+
+// HTTPSoftResetter binds the soft reset route to a route table
+func HTTPSoftResetter(s SoftResetter, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/admin/reset"}] = SoftReset(s)
+}
+
+// SoftReset returns an HTTP handler func which soft resets the camera and
+// responds with the new image size in bytes
+func SoftReset(s SoftResetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n, err := s.SoftReset()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		hp := generichttp.HumanPayload{T: types.Int, Int: n}
+		hp.EncodeAndRespond(w, r)
+	}
+}
+
 // FeatureManager is a type that can manage many features in a generic capacity
 type FeatureManager interface {
 	// Features returns a mapping of feature names to types, as strings
@@ -825,20 +1596,20 @@ func GetFeatureInfo(f FeatureManager) http.HandlerFunc {
 	}
 }
 
-// featureValue packages a feature and a value together
-type featureValue struct {
+// FeatureValue packages a feature and a value together
+type FeatureValue struct {
 	// Feature is the feature being set
-	Feature string `json:"feature"`
+	Feature string `json:"feature" yaml:"Feature"`
 
 	// Value is the value being applied
-	Value interface{} `json:"value"`
+	Value interface{} `json:"value" yaml:"Value"`
 }
 
 // SetFeature sets a particular feature on f
 func SetFeature(f FeatureManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		feature := chi.URLParam(r, "feature")
-		var fv featureValue
+		var fv FeatureValue
 		err := json.NewDecoder(r.Body).Decode(&fv)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -853,9 +1624,90 @@ func SetFeature(f FeatureManager) http.HandlerFunc {
 	}
 }
 
+// FeatureAccessor is a FeatureManager which can report whether features can
+// currently be read or written, which may depend on the state of the camera
+type FeatureAccessor interface {
+	// GetFeatureAccess returns whether a feature is readable and writable
+	GetFeatureAccess(string) (bool, bool, error)
+}
+
+// FeatureDescription describes one feature fully.  Readable and Writable are
+// omitted if the camera is not a FeatureAccessor
+type FeatureDescription struct {
+	Type string `json:"type"`
+
+	Value interface{} `json:"value,omitempty"`
+
+	Readable *bool `json:"readable,omitempty"`
+
+	Writable *bool `json:"writable,omitempty"`
+
+	// Info is the result of GetFeatureInfo, e.g. min and max or options
+	Info map[string]interface{} `json:"info,omitempty"`
+
+	// Error is the first error encountered describing the feature, if any
+	Error string `json:"error,omitempty"`
+}
+
+// DescribeFeatures returns a FeatureDescription of every feature of f, keyed
+// by name.  Errors describing one feature are recorded in its description and
+// do not stop the others from being described.  The values of features which
+// are not readable are not read
+func DescribeFeatures(f FeatureManager) (map[string]FeatureDescription, error) {
+	features, err := f.Features()
+	if err != nil {
+		return nil, err
+	}
+	accessor, hasAccess := f.(FeatureAccessor)
+	out := make(map[string]FeatureDescription, len(features))
+	for name, typ := range features {
+		d := FeatureDescription{Type: typ}
+		readable := true
+		if hasAccess {
+			var writable bool
+			readable, writable, err = accessor.GetFeatureAccess(name)
+			if err != nil {
+				d.Error = err.Error()
+				out[name] = d
+				continue
+			}
+			d.Readable, d.Writable = &readable, &writable
+		}
+		if readable && typ != "command" {
+			d.Value, err = f.GetFeature(name)
+			if err == nil {
+				d.Info, err = f.GetFeatureInfo(name)
+			}
+			if err != nil {
+				d.Error = err.Error()
+			}
+		}
+		out[name] = d
+	}
+	return out, nil
+}
+
+// GetFeatureDescriptions responds with the DescribeFeatures of f as JSON
+func GetFeatureDescriptions(f FeatureManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		desc, err := DescribeFeatures(f)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(desc)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
 // HTTPFeatureManager adds routes to rt for feature management
 func HTTPFeatureManager(f FeatureManager, rt generichttp.RouteTable) {
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/feature"}] = Features(f)
+	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/features/full"}] = GetFeatureDescriptions(f)
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/feature/{feature}"}] = GetFeature(f)
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/feature/{feature}/options"}] = GetFeatureInfo(f)
 	rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/feature/{feature}"}] = SetFeature(f)
@@ -879,12 +1731,40 @@ func NewHTTPCamera(p PictureTaker, rec *imgrec.Recorder) HTTPCamera {
 	w := HTTPCamera{PictureTaker: p}
 	rt := generichttp.RouteTable{}
 	HTTPPicture(p, rt, rec)
+	cal := CalibrationWrapper{P: p, Cal: &Calibrator{}, Rec: rec}
+	cal.Inject(rt)
+	// single frames and bursts share a busy flag so they are not interleaved
+	busy := &AcquisitionGuard{}
+	for _, mp := range []generichttp.MethodPath{
+		{Method: http.MethodGet, Path: "/image"},
+		{Method: http.MethodGet, Path: "/image/histogram"},
+		{Method: http.MethodGet, Path: "/image/focus-metric"},
+		{Method: http.MethodPost, Path: "/image/region-stats"},
+		{Method: http.MethodPost, Path: "/auto-exposure"},
+	} {
+		rt[mp] = busy.Wrap("frame", rt[mp])
+	}
+	mon := &RegionMonitor{P: p, Busy: busy}
+	mon.Inject(rt)
+	if rec != nil {
+		recipe := &RecipeRunner{P: p, Rec: rec, Busy: busy}
+		recipe.Inject(rt)
+	}
 	if thermal, ok := p.(ThermalManager); ok {
 		HTTPThermalManager(thermal, rt)
 	}
 	if aoi, ok := p.(AOIManipulator); ok {
 		HTTPAOIManipulator(aoi, rt)
 	}
+	if fi, ok := p.(FrameInfoer); ok {
+		HTTPFrameInfoer(fi, rt)
+	}
+	if mm, ok := p.(MetadataMaker); ok {
+		HTTPMetadataMaker(mm, rt)
+	}
+	if cv, ok := p.(ConfigValidator); ok {
+		HTTPConfigValidator(cv, rt)
+	}
 	if em, ok := p.(EMGainManager); ok {
 		HTTPEMGainManager(em, rt)
 	}
@@ -894,14 +1774,47 @@ func NewHTTPCamera(p PictureTaker, rec *imgrec.Recorder) HTTPCamera {
 	if sh, ok := p.(ExtendedShutterController); ok {
 		HTTPExtendedShutterController(sh, rt)
 	}
+	if fs, ok := p.(FrameShutterController); ok {
+		HTTPFrameShutterController(fs, rt)
+	}
 	if b, ok := p.(Burster); ok {
-		wrap := BurstWrapper{B: b}
+		wrap := BurstWrapper{B: b, Busy: busy}
 		wrap.Inject(rt)
 
 	}
 	if fm, ok := p.(FeatureManager); ok {
 		HTTPFeatureManager(fm, rt)
 	}
+	if sg, ok := p.(StabilityGate); ok {
+		HTTPStabilityGate(sg, rt)
+	}
+	if o, ok := p.(Orienter); ok {
+		HTTPOrienter(o, rt)
+	}
+	if re, ok := p.(ReadoutRateExplorer); ok {
+		HTTPReadoutRateExplorer(re, rt)
+	}
+	if ss, ok := p.(ShiftSpeedSelector); ok {
+		HTTPShiftSpeedSelector(ss, rt)
+	}
+	if sr, ok := p.(SoftResetter); ok {
+		HTTPSoftResetter(sr, rt)
+	}
+	if bm, ok := p.(BaselineManager); ok {
+		HTTPBaselineManager(bm, rt)
+	}
+	if a, ok := p.(Accumulator); ok {
+		HTTPAccumulator(a, rt)
+	}
+	if d, ok := p.(Diagnoser); ok {
+		HTTPDiagnoser(d, rt)
+	}
+	if td, ok := p.(TemperatureDiagnoser); ok {
+		HTTPTemperatureDiagnoser(td, rt)
+	}
+	if di, ok := p.(generichttp.DeviceInfo); ok {
+		generichttp.HTTPDeviceInfo(di, rt)
+	}
 
 	w.RouteTable = rt
 	return w
@@ -0,0 +1,542 @@
+package camera_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/astrogo/fitsio"
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/generichttp/camera"
+	"github.com/nasa-jpl/golaborate/imgrec"
+	"github.com/nasa-jpl/golaborate/util"
+)
+
+// fakeCamera is the least a camera must implement to be served
+type fakeCamera struct {
+	exposure time.Duration
+	frames   int
+}
+
+func (f *fakeCamera) GetFrame() (image.Image, error) {
+	f.frames++
+	img := image.NewGray16(image.Rect(0, 0, 4, 3))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i)
+	}
+	return img, nil
+}
+
+func (f *fakeCamera) SetExposureTime(d time.Duration) error {
+	f.exposure = d
+	return nil
+}
+
+func (f *fakeCamera) GetExposureTime() (time.Duration, error) {
+	return f.exposure, nil
+}
+
+// limitedCamera adds exposure limits to fakeCamera
+type limitedCamera struct {
+	fakeCamera
+}
+
+func (l *limitedCamera) GetExposureTimeLimits() (time.Duration, time.Duration, error) {
+	return time.Millisecond, time.Second, nil
+}
+
+// aoiCamera adds AOI control and config validation to fakeCamera
+type aoiCamera struct {
+	fakeCamera
+	aoi camera.AOI
+	bin camera.Binning
+}
+
+func (a *aoiCamera) SetAOI(aoi camera.AOI) error {
+	if aoi.Width < 1 || aoi.Height < 1 {
+		return errors.New("AOI must be at least one pixel")
+	}
+	a.aoi = aoi
+	return nil
+}
+
+func (a *aoiCamera) GetAOI() (camera.AOI, error) { return a.aoi, nil }
+
+func (a *aoiCamera) SetBinning(b camera.Binning) error {
+	a.bin = b
+	return nil
+}
+
+func (a *aoiCamera) GetBinning() (camera.Binning, error) { return a.bin, nil }
+
+func (a *aoiCamera) ValidateConfig(settings map[string]interface{}) error {
+	var errs []error
+	for k := range settings {
+		if k != "ExposureTime" {
+			errs = append(errs, util.KeyedError{Key: k, Err: errors.New("unknown feature")})
+		}
+	}
+	return util.NewMultiError(errs)
+}
+
+func serve(t *testing.T, p camera.PictureTaker) *httptest.Server {
+	t.Helper()
+	r := chi.NewRouter()
+	camera.NewHTTPCamera(p, nil).RT().Bind(r)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestExposureTimeRoundTrip(t *testing.T) {
+	cam := &fakeCamera{}
+	srv := serve(t, cam)
+	resp := do(t, srv, http.MethodPost, "/exposure-time", `{"f64": 0.25}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /exposure-time: expected 200, got %d", resp.StatusCode)
+	}
+	if cam.exposure != 250*time.Millisecond {
+		t.Errorf("expected SetExposureTime(250ms), got %v", cam.exposure)
+	}
+	resp = do(t, srv, http.MethodGet, "/exposure-time", "")
+	hp, err := generichttp.DecodeHumanPayload(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := hp.AsFloat()
+	if err != nil || f != 0.25 {
+		t.Errorf("GET /exposure-time: expected 0.25, got %v, %v", f, err)
+	}
+}
+
+func TestExposureTimeQueryParameter(t *testing.T) {
+	cam := &fakeCamera{}
+	srv := serve(t, cam)
+	resp := do(t, srv, http.MethodPost, "/exposure-time?exposureTime=10ms", "")
+	if resp.StatusCode != http.StatusOK || cam.exposure != 10*time.Millisecond {
+		t.Errorf("expected 200 and 10ms, got %d and %v", resp.StatusCode, cam.exposure)
+	}
+}
+
+func TestExposureTimeBadJSON(t *testing.T) {
+	cam := &fakeCamera{exposure: time.Second}
+	srv := serve(t, cam)
+	resp := do(t, srv, http.MethodPost, "/exposure-time", `{"f64":`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", resp.StatusCode)
+	}
+	if cam.exposure != time.Second {
+		t.Errorf("exposure time changed to %v by a bad request", cam.exposure)
+	}
+}
+
+func TestExposureTimeOutOfRange(t *testing.T) {
+	cam := &limitedCamera{fakeCamera{exposure: time.Second}}
+	srv := serve(t, cam)
+	resp := do(t, srv, http.MethodPost, "/exposure-time", `{"f64": 5}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", resp.StatusCode)
+	}
+	if cam.exposure != time.Second {
+		t.Errorf("exposure time changed to %v by an out of range request", cam.exposure)
+	}
+}
+
+func TestImageRoute(t *testing.T) {
+	cam := &fakeCamera{}
+	srv := serve(t, cam)
+	resp := do(t, srv, http.MethodGet, "/image?fmt=png", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 4 || b.Dy() != 3 {
+		t.Errorf("expected a 4x3 image, got %v", b)
+	}
+	if cam.frames != 1 {
+		t.Errorf("expected 1 frame taken, got %d", cam.frames)
+	}
+}
+
+func TestOptionalInterfacesInjected(t *testing.T) {
+	plain := camera.NewHTTPCamera(&fakeCamera{}, nil).RT()
+	withAOI := camera.NewHTTPCamera(&aoiCamera{}, nil).RT()
+	for _, mp := range []generichttp.MethodPath{
+		{Method: http.MethodGet, Path: "/exposure-time"},
+		{Method: http.MethodPost, Path: "/exposure-time"},
+		{Method: http.MethodGet, Path: "/image"},
+	} {
+		if plain[mp] == nil || withAOI[mp] == nil {
+			t.Errorf("%s %s missing from the route table", mp.Method, mp.Path)
+		}
+	}
+	for _, mp := range []generichttp.MethodPath{
+		{Method: http.MethodGet, Path: "/aoi"},
+		{Method: http.MethodPost, Path: "/aoi"},
+		{Method: http.MethodGet, Path: "/binning"},
+		{Method: http.MethodPost, Path: "/binning"},
+		{Method: http.MethodPost, Path: "/config/validate"},
+	} {
+		if plain[mp] != nil {
+			t.Errorf("%s %s injected for a camera without the interface", mp.Method, mp.Path)
+		}
+		if withAOI[mp] == nil {
+			t.Errorf("%s %s not injected for a camera with the interface", mp.Method, mp.Path)
+		}
+	}
+	for _, mp := range []generichttp.MethodPath{
+		{Method: http.MethodGet, Path: "/frame-info"},
+		{Method: http.MethodGet, Path: "/temperature"},
+		{Method: http.MethodPost, Path: "/burst/setup"},
+	} {
+		if withAOI[mp] != nil {
+			t.Errorf("%s %s injected for a camera without the interface", mp.Method, mp.Path)
+		}
+	}
+}
+
+func TestAOIRoundTrip(t *testing.T) {
+	cam := &aoiCamera{}
+	srv := serve(t, cam)
+	want := camera.AOI{Left: 1, Top: 2, Width: 3, Height: 4}
+	b, _ := json.Marshal(want)
+	resp := do(t, srv, http.MethodPost, "/aoi", string(b))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /aoi: expected 200, got %d", resp.StatusCode)
+	}
+	resp = do(t, srv, http.MethodGet, "/aoi", "")
+	var got camera.AOI
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestAOIErrors(t *testing.T) {
+	srv := serve(t, &aoiCamera{})
+	for _, body := range []string{`{"width":`, `{"width": 0, "height": 0}`} {
+		resp := do(t, srv, http.MethodPost, "/aoi", body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST /aoi %s: expected 400, got %d", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestConfigValidateRoute(t *testing.T) {
+	srv := serve(t, &aoiCamera{})
+	resp := do(t, srv, http.MethodPost, "/config/validate", `{"ExposureTime": 1}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("valid config: expected 200, got %d", resp.StatusCode)
+	}
+	resp = do(t, srv, http.MethodPost, "/config/validate", `{"ExposureTime": 1, "Bogus": true}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid config: expected 400, got %d", resp.StatusCode)
+	}
+	var out struct {
+		Valid  bool              `json:"valid"`
+		Errors map[string]string `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Valid || out.Errors["Bogus"] == "" || len(out.Errors) != 1 {
+		t.Errorf("expected only Bogus to be reported, got %+v", out)
+	}
+	resp = do(t, srv, http.MethodPost, "/config/validate", `[`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad JSON: expected 400, got %d", resp.StatusCode)
+	}
+}
+
+// metaCamera adds FITS metadata to fakeCamera
+type metaCamera struct {
+	fakeCamera
+}
+
+func (m *metaCamera) CollectHeaderMetadata() []fitsio.Card {
+	return []fitsio.Card{
+		{Name: "EXPTIME", Value: m.exposure.Seconds(), Comment: "exposure time, s"},
+		{Name: "TEMP", Value: math.NaN(), Comment: "sensor temperature"}}
+}
+
+func TestMetadataRoute(t *testing.T) {
+	cam := &metaCamera{fakeCamera{exposure: 2 * time.Second}}
+	srv := serve(t, cam)
+	resp := do(t, srv, http.MethodGet, "/metadata", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var cards []camera.ExtraCard
+	if err := json.NewDecoder(resp.Body).Decode(&cards); err != nil {
+		t.Fatal(err)
+	}
+	if len(cards) != 2 || cards[0].Name != "EXPTIME" || cards[0].Value != 2. || cards[1].Value != "NaN" {
+		t.Errorf("unexpected cards %+v", cards)
+	}
+	if cam.frames != 0 {
+		t.Errorf("expected no frames taken, got %d", cam.frames)
+	}
+}
+
+func TestRecipeSpoolsEveryFrame(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recipe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cam := &fakeCamera{exposure: time.Second}
+	rr := &camera.RecipeRunner{P: cam, Rec: &imgrec.Recorder{Root: dir, Prefix: "r"}}
+	err = rr.Start(camera.Recipe{Steps: []camera.RecipeStep{
+		{Exposure: 0.5, Count: 2, Label: "short"},
+		{Exposure: 2, Count: 1, Label: "long"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; rr.Progress().Running; i++ {
+		if i > 200 {
+			t.Fatal("recipe did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	p := rr.Progress()
+	if p.Error != "" || p.Done != 3 || cam.frames != 3 {
+		t.Errorf("expected 3 frames without error, got %+v and %d frames", p, cam.frames)
+	}
+	if cam.exposure != time.Second {
+		t.Errorf("exposure time not restored, got %v", cam.exposure)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*", "r*.fits"))
+	if len(files) != 3 {
+		t.Errorf("expected 3 files spooled, got %v", files)
+	}
+	err = rr.Start(camera.Recipe{Steps: []camera.RecipeStep{{Exposure: 1, Count: 1, Shutter: new(bool)}}})
+	if err == nil {
+		t.Error("expected an error controlling the shutter of a camera without one")
+	}
+}
+
+// slowCamera never delivers a frame
+type slowCamera struct {
+	fakeCamera
+}
+
+func (s *slowCamera) GetFrame() (image.Image, error) {
+	return nil, fmt.Errorf("fake: %w", camera.ErrAcquisitionTimeout)
+}
+
+func TestAcquisitionTimeoutIsGatewayTimeout(t *testing.T) {
+	srv := serve(t, &slowCamera{})
+	for _, path := range []string{"/image", "/image/histogram"} {
+		resp := do(t, srv, http.MethodGet, path, "")
+		if resp.StatusCode != http.StatusGatewayTimeout {
+			t.Errorf("GET %s: expected 504, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+// checkerboard returns a 16x16 checkerboard blurred by a box of the given
+// half-width.  Every value is a multiple of 257, whose two bytes are the same,
+// so the image reads the same in the cameras' native byte order as in the big
+// endian order of image.Gray16
+func checkerboard(blur int) *image.Gray16 {
+	const n = 16
+	var sharp [n][n]float64
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if (x/4+y/4)%2 == 0 {
+				sharp[y][x] = 255
+			}
+		}
+	}
+	img := image.NewGray16(image.Rect(0, 0, n, n))
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			var sum, cnt float64
+			for dy := -blur; dy <= blur; dy++ {
+				for dx := -blur; dx <= blur; dx++ {
+					xx, yy := x+dx, y+dy
+					if xx >= 0 && xx < n && yy >= 0 && yy < n {
+						sum += sharp[yy][xx]
+						cnt++
+					}
+				}
+			}
+			img.SetGray16(x, y, color.Gray16{Y: 257 * uint16(math.Round(sum/cnt))})
+		}
+	}
+	return img
+}
+
+func TestFocusMetricsPreferSharpImage(t *testing.T) {
+	sharp, blurred, flat := checkerboard(0), checkerboard(2), image.NewGray16(image.Rect(0, 0, 16, 16))
+	for _, m := range camera.FocusMetrics {
+		s, b := camera.FocusMetric(sharp, m), camera.FocusMetric(blurred, m)
+		if !(s > b) {
+			t.Errorf("%s: sharp image scored %v, not more than blurred %v", m, s, b)
+		}
+		if f := camera.FocusMetric(flat, m); f != 0 {
+			t.Errorf("%s: flat image scored %v, expected 0", m, f)
+		}
+	}
+	if v := camera.FocusMetric(sharp, "bogus"); !math.IsNaN(v) {
+		t.Errorf("unknown method scored %v, expected NaN", v)
+	}
+}
+
+func TestFocusMetricRoute(t *testing.T) {
+	srv := serve(t, &fakeCamera{})
+	resp := do(t, srv, http.MethodGet, "/image/focus-metric?method=bogus", "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown method: expected 400, got %d", resp.StatusCode)
+	}
+	resp = do(t, srv, http.MethodGet, "/image/focus-metric", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var out struct {
+		Method string  `json:"method"`
+		Value  float64 `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Method != camera.DefaultFocusMetric || !(out.Value > 0) {
+		t.Errorf("expected a positive %s, got %+v", camera.DefaultFocusMetric, out)
+	}
+}
+
+// blockingCamera holds its first frame until release is closed
+type blockingCamera struct {
+	fakeCamera
+	calls   int32
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingCamera) GetFrame() (image.Image, error) {
+	if atomic.AddInt32(&b.calls, 1) == 1 {
+		close(b.entered)
+		<-b.release
+	}
+	img := image.NewGray16(image.Rect(0, 0, 4, 3))
+	return img, nil
+}
+
+func TestFrameRoutesConflictDuringAcquisition(t *testing.T) {
+	cam := &blockingCamera{entered: make(chan struct{}), release: make(chan struct{})}
+	srv := serve(t, cam)
+	done := make(chan int)
+	go func() {
+		resp, err := http.Get(srv.URL + "/image")
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	<-cam.entered
+	for _, path := range []string{"/image", "/image/histogram", "/image/focus-metric"} {
+		resp := do(t, srv, http.MethodGet, path, "")
+		if resp.StatusCode != http.StatusConflict {
+			t.Errorf("GET %s during acquisition: expected 409, got %d", path, resp.StatusCode)
+		}
+	}
+	close(cam.release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("GET /image: expected 200, got %d", code)
+	}
+}
+
+// timedCamera adds frame timing to fakeCamera
+type timedCamera struct {
+	fakeCamera
+}
+
+func (tc *timedCamera) GetFrameTiming() (camera.FrameTiming, error) {
+	return camera.FrameTiming{
+		Start:    time.Date(2020, 1, 2, 3, 4, 5, 678900000, time.UTC),
+		Duration: 1500 * time.Millisecond}, nil
+}
+
+func TestFrameTimingHeaders(t *testing.T) {
+	srv := serve(t, &timedCamera{})
+	resp := do(t, srv, http.MethodGet, "/image?fmt=png", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-Frame-Start"); got != "2020-01-02T03:04:05.6789Z" {
+		t.Errorf("X-Frame-Start: got %q", got)
+	}
+	if got := resp.Header.Get("X-Frame-Duration"); got != "1.5" {
+		t.Errorf("X-Frame-Duration: got %q", got)
+	}
+}
+
+// shutterCamera adds frame shutter control to timedCamera, and records the
+// shutter mode of each frame
+type shutterCamera struct {
+	timedCamera
+	shutter string
+	seen    []string
+}
+
+func (s *shutterCamera) GetFrame() (image.Image, error) {
+	s.seen = append(s.seen, s.shutter)
+	return s.timedCamera.GetFrame()
+}
+
+func (s *shutterCamera) SetFrameShutter(mode string) error {
+	s.shutter = mode
+	return nil
+}
+
+func (s *shutterCamera) GetFrameShutter() (string, error) { return s.shutter, nil }
+
+func TestCalibratedFrameKeepsShutterAndTiming(t *testing.T) {
+	cam := &shutterCamera{shutter: "Light"}
+	srv := serve(t, cam)
+	resp := do(t, srv, http.MethodGet, "/image?fmt=png&calibrate=true&shutter=Dark", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if len(cam.seen) != 1 || cam.seen[0] != "Dark" || cam.shutter != "Light" {
+		t.Errorf("expected one Dark frame and the shutter restored to Light, got %q and %q", cam.seen, cam.shutter)
+	}
+	if resp.Header.Get("X-Frame-Start") == "" || resp.Header.Get("X-Frame-Duration") == "" {
+		t.Errorf("expected frame timing headers, got %v", resp.Header)
+	}
+}
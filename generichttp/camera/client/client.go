@@ -0,0 +1,305 @@
+// Package client provides a Go client for cameras served over HTTP by
+// generichttp/camera, e.g. by andorhttp2 or andorhttp3
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register the decoder for fmt=jpg
+	_ "image/png"  // register the decoder for fmt=png
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+	"unsafe"
+
+	"github.com/astrogo/fitsio"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/generichttp/camera"
+)
+
+// Client drives a remote camera.  It satisfies camera.PictureTaker,
+// camera.AOIManipulator, and camera.Burster, so it may be used in place of a
+// local camera.
+//
+// Frames are returned as *image.Gray16 with the pixels in native byte order,
+// the same as the camera drivers, so they may be given to camera.WriteFits.
+type Client struct {
+	// Addr is the base URL of the camera, e.g. http://localhost:8000/camera
+	Addr string
+
+	// HTTP is used for all requests.  If nil, http.DefaultClient is used
+	HTTP *http.Client
+}
+
+// New returns a new Client for the camera at addr
+func New(addr string) *Client {
+	return &Client{Addr: addr, HTTP: &http.Client{}}
+}
+
+func (c *Client) client() *http.Client {
+	if c.HTTP == nil {
+		return http.DefaultClient
+	}
+	return c.HTTP
+}
+
+// get performs a GET request and returns the body, which must be closed
+func (c *Client) get(path string) (io.ReadCloser, error) {
+	resp, err := c.client().Get(c.Addr + path)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// getJSON performs a GET request and decodes the JSON response into v
+func (c *Client) getJSON(path string, v interface{}) error {
+	body, err := c.get(path)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return json.NewDecoder(body).Decode(v)
+}
+
+// postJSON performs a POST request with v encoded as JSON in the body
+func (c *Client) postJSON(path string, v interface{}) error {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client().Post(c.Addr+path, "application/json", bytes.NewReader(js))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// checkResponse returns an error containing the body if the status is not 200
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s: %s %s", resp.Request.URL.Path, resp.Status, bytes.TrimSpace(msg))
+}
+
+// GetFrame takes a frame at full bit depth.  It is transferred as FITS
+func (c *Client) GetFrame() (image.Image, error) {
+	img, _, err := c.GetFrameWithCards(nil)
+	return img, err
+}
+
+// GetFrameWithCards takes a frame at full bit depth with the given extra FITS
+// cards, returning the frame and the header the server wrote
+func (c *Client) GetFrameWithCards(cards []camera.ExtraCard) (*image.Gray16, []fitsio.Card, error) {
+	b, err := c.GetFITS(cards)
+	if err != nil {
+		return nil, nil, err
+	}
+	frames, hdr, err := DecodeFits(bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, err
+	}
+	return frames[0], hdr, nil
+}
+
+// GetFITS takes a frame and returns the FITS file the server wrote, with the
+// given extra cards in the header.  cards may be nil
+func (c *Client) GetFITS(cards []camera.ExtraCard) ([]byte, error) {
+	q := url.Values{}
+	q.Set("fmt", "fits")
+	if len(cards) > 0 {
+		js, err := json.Marshal(cards)
+		if err != nil {
+			return nil, err
+		}
+		q.Set("cards", string(js))
+	}
+	body, err := c.get("/image?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// GetImage takes a frame in a display format, "jpg" or "png".  The server
+// scales 16-bit frames to 8 bits for these formats
+func (c *Client) GetImage(format string) (image.Image, error) {
+	if format != "jpg" && format != "png" {
+		return nil, fmt.Errorf("format must be jpg or png, got %q, use GetFrame for FITS", format)
+	}
+	body, err := c.get("/image?fmt=" + format)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	img, _, err := image.Decode(body)
+	return img, err
+}
+
+// SetExposureTime sets the exposure time
+func (c *Client) SetExposureTime(t time.Duration) error {
+	return c.postJSON("/exposure-time", generichttp.FloatT{F64: t.Seconds()})
+}
+
+// GetExposureTime gets the exposure time
+func (c *Client) GetExposureTime() (time.Duration, error) {
+	var f generichttp.FloatT
+	err := c.getJSON("/exposure-time", &f)
+	return time.Duration(f.F64 * 1e9), err
+}
+
+// SetAOI sets the AOI
+func (c *Client) SetAOI(aoi camera.AOI) error {
+	return c.postJSON("/aoi", aoi)
+}
+
+// GetAOI gets the AOI
+func (c *Client) GetAOI() (camera.AOI, error) {
+	var aoi camera.AOI
+	err := c.getJSON("/aoi", &aoi)
+	return aoi, err
+}
+
+// SetBinning sets the binning
+func (c *Client) SetBinning(b camera.Binning) error {
+	return c.postJSON("/binning", b)
+}
+
+// GetBinning gets the binning
+func (c *Client) GetBinning() (camera.Binning, error) {
+	var b camera.Binning
+	err := c.getJSON("/binning", &b)
+	return b, err
+}
+
+// Configure sets each feature in the order given, stopping at the first error
+func (c *Client) Configure(steps []camera.FeatureValue) error {
+	for _, step := range steps {
+		err := c.postJSON("/feature/"+url.PathEscape(step.Feature), step)
+		if err != nil {
+			return fmt.Errorf("%s: %w", step.Feature, err)
+		}
+	}
+	return nil
+}
+
+// Burst takes frames at fps and writes them to ch, which is closed when the
+// burst is done.  The frames are transferred together after the burst ends
+func (c *Client) Burst(frames int, fps float64, ch chan<- image.Image) error {
+	defer close(ch)
+	setup := struct {
+		FPS    float64 `json:"fps"`
+		Frames int     `json:"frames"`
+	}{fps, frames}
+	err := c.postJSON("/burst/setup", setup)
+	if err != nil {
+		return err
+	}
+	body, err := c.get("/burst/all-frames")
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	imgs, hdr, err := DecodeFits(body)
+	if err != nil {
+		return err
+	}
+	for _, img := range imgs {
+		ch <- img
+	}
+	for _, card := range hdr {
+		if s, ok := card.Value.(string); ok && card.Name == "ERR" && s != "" {
+			return errors.New(s)
+		}
+	}
+	return nil
+}
+
+// DecodeFits reads a 16-bit FITS file written by camera.WriteFits, returning
+// one frame per plane of the image and the header cards
+func DecodeFits(r io.Reader) ([]*image.Gray16, []fitsio.Card, error) {
+	f, err := fitsio.Open(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	hdu, ok := f.HDU(0).(fitsio.Image)
+	if !ok {
+		return nil, nil, errors.New("primary HDU is not an image")
+	}
+	hdr := hdu.Header()
+	if hdr.Bitpix() != 16 {
+		return nil, nil, fmt.Errorf("unsupported BITPIX %d, must be 16", hdr.Bitpix())
+	}
+	axes := hdr.Axes()
+	nframes := 1
+	switch len(axes) {
+	case 2:
+	case 3:
+		nframes = axes[2]
+	default:
+		return nil, nil, fmt.Errorf("image must be 2D or 3D, got %d axes", len(axes))
+	}
+	w, h := axes[0], axes[1]
+	buf := make([]int16, w*h*nframes)
+	if err = hdu.Read(&buf); err != nil {
+		return nil, nil, err
+	}
+	// WriteFits stores unsigned pixels as signed with BZERO = 32768, which
+	// is the same as flipping the sign bit
+	var flip uint16
+	if c := hdr.Get("BZERO"); c != nil && cardFloat(c.Value) == 32768 {
+		flip = 0x8000
+	}
+	out := make([]*image.Gray16, nframes)
+	for i := range out {
+		plane := buf[i*w*h : (i+1)*w*h]
+		u := make([]uint16, len(plane))
+		for j, v := range plane {
+			u[j] = uint16(v) ^ flip
+		}
+		out[i] = &image.Gray16{Pix: uintToBytes(u), Stride: w * 2, Rect: image.Rect(0, 0, w, h)}
+	}
+	var cards []fitsio.Card
+	for _, key := range hdr.Keys() {
+		if c := hdr.Get(key); c != nil {
+			cards = append(cards, *c)
+		}
+	}
+	return out, cards, nil
+}
+
+// cardFloat returns the value of a numeric card as a float, or NaN
+func cardFloat(v interface{}) float64 {
+	switch v := v.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return math.NaN()
+}
+
+// uintToBytes views u as bytes in native byte order
+func uintToBytes(u []uint16) []byte {
+	if len(u) == 0 {
+		return nil
+	}
+	return (*[1 << 30]byte)(unsafe.Pointer(&u[0]))[: 2*len(u) : 2*len(u)]
+}
@@ -1,9 +1,12 @@
 package camera
 
 import (
+	"encoding/json"
+	"fmt"
 	"image"
 	"io"
 	"reflect"
+	"strings"
 	"unsafe"
 
 	"github.com/astrogo/fitsio"
@@ -53,6 +56,117 @@ func WriteFits(w io.Writer, metadata []fitsio.Card, imgs []image.Image) error {
 	return fits.Write(im)
 }
 
+// maxCardValueLen is the longest string value which fits in an 80 character
+// FITS card alongside its name and quotes
+const maxCardValueLen = 68
+
+// reservedCards are the keywords which are written by WriteFits or fitsio
+// itself and may not be overridden by a user
+var reservedCards = map[string]struct{}{
+	"SIMPLE": {}, "BITPIX": {}, "NAXIS": {}, "NAXIS1": {}, "NAXIS2": {}, "NAXIS3": {},
+	"EXTEND": {}, "BZERO": {}, "BSCALE": {}, "END": {},
+}
+
+// ExtraCard is a user supplied FITS card, e.g. the target name or filter
+type ExtraCard struct {
+	// Name is the keyword, at most 8 characters of A-Z, 0-9, - and _
+	Name string `json:"name"`
+
+	// Value is the value; strings, numbers, and bools are allowed
+	Value interface{} `json:"value"`
+
+	// Comment is an optional comment
+	Comment string `json:"comment"`
+}
+
+// Card validates and sanitizes the card, returning it in the form used by fitsio.
+// Names are upper-cased and must be at most 8 characters.  Non-printable
+// characters are stripped from string values and comments, and string values
+// are truncated to fit on one card.
+func (e ExtraCard) Card() (fitsio.Card, error) {
+	name := strings.ToUpper(strings.TrimSpace(e.Name))
+	if name == "" || len(name) > 8 {
+		return fitsio.Card{}, fmt.Errorf("FITS card name %q must be 1 to 8 characters", e.Name)
+	}
+	for _, r := range name {
+		if !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fitsio.Card{}, fmt.Errorf("FITS card name %q may only contain A-Z, 0-9, - and _", e.Name)
+		}
+	}
+	if _, ok := reservedCards[name]; ok {
+		return fitsio.Card{}, fmt.Errorf("FITS card name %s is reserved", name)
+	}
+	var value interface{}
+	switch v := e.Value.(type) {
+	case string:
+		v = sanitizeCardString(v)
+		if len(v) > maxCardValueLen {
+			v = v[:maxCardValueLen]
+		}
+		value = v
+	case float64:
+		// JSON numbers decode as float64; keep integers as integers so they
+		// are not written with a decimal point
+		if v == float64(int64(v)) {
+			value = int64(v)
+		} else {
+			value = v
+		}
+	case bool, int, int64:
+		value = v
+	default:
+		return fitsio.Card{}, fmt.Errorf("FITS card %s has value of unsupported type %T", name, e.Value)
+	}
+	return fitsio.Card{Name: name, Value: value, Comment: sanitizeCardString(e.Comment)}, nil
+}
+
+// sanitizeCardString removes characters which are not printable ASCII
+func sanitizeCardString(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// ParseExtraCards decodes a JSON array of ExtraCards and validates each
+func ParseExtraCards(r io.Reader) ([]fitsio.Card, error) {
+	var extras []ExtraCard
+	err := json.NewDecoder(r).Decode(&extras)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]fitsio.Card, 0, len(extras))
+	for _, e := range extras {
+		c, err := e.Card()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// MergeCards appends extra to base, replacing any cards in base with the
+// same name as one in extra
+func MergeCards(base, extra []fitsio.Card) []fitsio.Card {
+	if len(extra) == 0 {
+		return base
+	}
+	override := make(map[string]struct{}, len(extra))
+	for _, c := range extra {
+		override[c.Name] = struct{}{}
+	}
+	out := make([]fitsio.Card, 0, len(base)+len(extra))
+	for _, c := range base {
+		if _, ok := override[c.Name]; !ok {
+			out = append(out, c)
+		}
+	}
+	return append(out, extra...)
+}
+
 func bytesToUint(b []byte) []uint16 {
 	var ary []uint16
 	hdr := (*reflect.SliceHeader)(unsafe.Pointer(&ary))
@@ -0,0 +1,205 @@
+package camera
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// DefaultFocusMetric is the focus metric used when none is given
+const DefaultFocusMetric = "tenengrad"
+
+// FocusMetrics are the methods understood by FocusMetric.  Each is larger
+// for a sharper image and is normalized by the number of terms, so that
+// images of different sizes are comparable:
+//
+//	brenner          the mean squared difference between pixels two columns
+//	                 apart, (I(x+2,y) - I(x,y))^2
+//	gradient-energy  the mean squared difference between horizontally and
+//	                 vertically adjacent pixels
+//	laplacian        the variance of the 4-neighbor Laplacian,
+//	                 I(x-1,y) + I(x+1,y) + I(x,y-1) + I(x,y+1) - 4I(x,y)
+//	tenengrad        the mean squared magnitude of the 3x3 Sobel gradient,
+//	                 Gx^2 + Gy^2
+//
+// Brenner is the cheapest and is sensitive to vertical edges only.  The
+// Laplacian variance responds most strongly to fine detail and so to noise.
+// Tenengrad is the most robust to noise and is the default
+var FocusMetrics = []string{"brenner", "gradient-energy", "laplacian", "tenengrad"}
+
+// FocusMetric returns the sharpness of an image by one of FocusMetrics.  NaN
+// is returned if the method is not known.  Images too small for the method's
+// kernel have a sharpness of zero
+func FocusMetric(img image.Image, method string) float64 {
+	w, h, data := imageToFloat(img)
+	return FocusMetricFloat(w, h, data, method)
+}
+
+// FocusMetricFloat is FocusMetric for an image given as row-major floats,
+// such as one read by ReadFitsFloat
+func FocusMetricFloat(width, height int, data []float64, method string) float64 {
+	switch strings.ToLower(method) {
+	case "brenner":
+		return brenner(width, height, data)
+	case "gradient-energy":
+		return gradientEnergy(width, height, data)
+	case "laplacian":
+		return laplacianVariance(width, height, data)
+	case "tenengrad":
+		return tenengrad(width, height, data)
+	}
+	return math.NaN()
+}
+
+// ValidateFocusMetric returns an error if method is not one of FocusMetrics
+func ValidateFocusMetric(method string) error {
+	for _, m := range FocusMetrics {
+		if strings.EqualFold(m, method) {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown focus metric %q, must be one of %v", method, FocusMetrics)
+}
+
+// imageToFloat returns the width, height, and pixels of an image in
+// row-major order.  16-bit grayscale images are read in the cameras' native
+// byte order, as by ComputeRegionStats, others through color.Gray16Model
+func imageToFloat(img image.Image) (int, int, []float64) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := make([]float64, w*h)
+	if g16, ok := img.(*image.Gray16); ok && len(g16.Pix) > 0 {
+		uints := bytesToUint(g16.Pix)
+		stride := g16.Stride / 2
+		for y := 0; y < h; y++ {
+			row := uints[y*stride : y*stride+w]
+			for x, v := range row {
+				out[y*w+x] = float64(v)
+			}
+		}
+		return w, h, out
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.Gray16Model.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray16)
+			out[y*w+x] = float64(c.Y)
+		}
+	}
+	return w, h, out
+}
+
+func brenner(w, h int, data []float64) float64 {
+	if w < 3 {
+		return 0
+	}
+	var sum float64
+	for y := 0; y < h; y++ {
+		row := data[y*w : (y+1)*w]
+		for x := 0; x+2 < w; x++ {
+			d := row[x+2] - row[x]
+			sum += d * d
+		}
+	}
+	return sum / float64((w-2)*h)
+}
+
+func gradientEnergy(w, h int, data []float64) float64 {
+	var (
+		sum float64
+		n   int
+	)
+	for y := 0; y < h; y++ {
+		row := data[y*w : (y+1)*w]
+		for x := 0; x < w; x++ {
+			if x+1 < w {
+				d := row[x+1] - row[x]
+				sum += d * d
+				n++
+			}
+			if y+1 < h {
+				d := data[(y+1)*w+x] - row[x]
+				sum += d * d
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+func laplacianVariance(w, h int, data []float64) float64 {
+	if w < 3 || h < 3 {
+		return 0
+	}
+	var sum, sum2 float64
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			l := data[i-1] + data[i+1] + data[i-w] + data[i+w] - 4*data[i]
+			sum += l
+			sum2 += l * l
+		}
+	}
+	n := float64((w - 2) * (h - 2))
+	mean := sum / n
+	return sum2/n - mean*mean
+}
+
+func tenengrad(w, h int, data []float64) float64 {
+	if w < 3 || h < 3 {
+		return 0
+	}
+	var sum float64
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			tl, t, tr := data[i-w-1], data[i-w], data[i-w+1]
+			l, r := data[i-1], data[i+1]
+			bl, b, br := data[i+w-1], data[i+w], data[i+w+1]
+			gx := (tr + 2*r + br) - (tl + 2*l + bl)
+			gy := (bl + 2*b + br) - (tl + 2*t + tr)
+			sum += gx*gx + gy*gy
+		}
+	}
+	return sum / float64((w-2)*(h-2))
+}
+
+// focusMetric is the response of GetFocusMetric
+type focusMetric struct {
+	Method string  `json:"method"`
+	Value  float64 `json:"value"`
+}
+
+// GetFocusMetric returns an HTTP handler func which takes a frame and responds
+// with its sharpness as {"method", "value"}.  The method is given by the
+// method query parameter, or DefaultFocusMetric
+func GetFocusMetric(p PictureTaker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		method := r.URL.Query().Get("method")
+		if method == "" {
+			method = DefaultFocusMetric
+		}
+		if err := ValidateFocusMetric(method); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		img, err := p.GetFrame()
+		if err != nil {
+			http.Error(w, err.Error(), acquisitionErrorCode(err))
+			return
+		}
+		m := focusMetric{Method: strings.ToLower(method), Value: FocusMetric(img, method)}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(m)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
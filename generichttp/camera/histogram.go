@@ -0,0 +1,122 @@
+package camera
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// DefaultHistogramBins is the number of bins in a histogram if none is given
+const DefaultHistogramBins = 256
+
+// HistogramPercentiles are the percentiles reported in a Histogram
+var HistogramPercentiles = []float64{1, 5, 25, 50, 75, 95, 99, 99.9}
+
+// Histogram describes the distribution of pixel values in a 16-bit frame.
+// The bins evenly divide [Min, Max+1), so bin i counts the values in
+// [Lo + i*BinWidth, Lo + (i+1)*BinWidth)
+type Histogram struct {
+	Counts []int `json:"counts"`
+
+	// Lo is the lower edge of the first bin, in DN
+	Lo float64 `json:"lo"`
+
+	// BinWidth is the width of each bin, in DN
+	BinWidth float64 `json:"binWidth"`
+
+	Min  uint16  `json:"min"`
+	Max  uint16  `json:"max"`
+	Mean float64 `json:"mean"`
+
+	// Percentiles maps each of HistogramPercentiles, formatted as a string,
+	// to the pixel value at that percentile
+	Percentiles map[string]uint16 `json:"percentiles"`
+
+	// Saturated is the number of pixels at full scale
+	Saturated int `json:"saturated"`
+}
+
+// ComputeHistogram returns the histogram of a 16-bit grayscale image with the
+// given number of bins, which must be in [1, 65536]
+func ComputeHistogram(img image.Image, bins int) (Histogram, error) {
+	var h Histogram
+	if bins < 1 || bins > 65536 {
+		return h, fmt.Errorf("bins must be in [1, 65536], got %d", bins)
+	}
+	g16, ok := img.(*image.Gray16)
+	if !ok {
+		return h, ErrNotGray16
+	}
+	if len(g16.Pix) == 0 {
+		return h, errors.New("image is empty")
+	}
+	uints := bytesToUint(g16.Pix)
+	hist := fullHistogram(uints)
+	h.Min, h.Max = math.MaxUint16, 0
+	var sum float64
+	for v, n := range hist {
+		if n == 0 {
+			continue
+		}
+		if uint16(v) < h.Min {
+			h.Min = uint16(v)
+		}
+		h.Max = uint16(v)
+		sum += float64(v) * float64(n)
+	}
+	h.Mean = sum / float64(len(uints))
+	h.Saturated = hist[math.MaxUint16]
+	h.Percentiles = make(map[string]uint16, len(HistogramPercentiles))
+	for _, p := range HistogramPercentiles {
+		h.Percentiles[strconv.FormatFloat(p, 'f', -1, 64)] = histPercentile(hist, len(uints), p)
+	}
+
+	h.Lo = float64(h.Min)
+	h.BinWidth = (float64(h.Max) + 1 - h.Lo) / float64(bins)
+	h.Counts = make([]int, bins)
+	for v := int(h.Min); v <= int(h.Max); v++ {
+		bin := int((float64(v) - h.Lo) / h.BinWidth)
+		if bin >= bins {
+			bin = bins - 1
+		}
+		h.Counts[bin] += hist[v]
+	}
+	return h, nil
+}
+
+// GetHistogram returns an HTTP handler func which takes a frame and responds
+// with its Histogram as JSON.  The number of bins is given by the bins query
+// parameter, or DefaultHistogramBins
+func GetHistogram(p PictureTaker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bins := DefaultHistogramBins
+		if s := r.URL.Query().Get("bins"); s != "" {
+			var err error
+			bins, err = strconv.Atoi(s)
+			if err != nil || bins < 1 || bins > 65536 {
+				http.Error(w, fmt.Sprintf("bins must be an integer in [1, 65536], got %q", s), http.StatusBadRequest)
+				return
+			}
+		}
+		img, err := p.GetFrame()
+		if err != nil {
+			http.Error(w, err.Error(), acquisitionErrorCode(err))
+			return
+		}
+		h, err := ComputeHistogram(img, bins)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(h)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package camera
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/util"
+)
+
+// RegionSample is one set of region statistics published by a RegionMonitor
+type RegionSample struct {
+	RegionStats
+
+	// Frame counts the frames since the monitor was started, from 1
+	Frame int `json:"frame"`
+
+	Time time.Time `json:"time"`
+
+	// Error is not empty if the frame could not be taken or measured
+	Error string `json:"error,omitempty"`
+}
+
+// RegionMonitorStatus describes a RegionMonitor
+type RegionMonitorStatus struct {
+	Running bool `json:"running"`
+
+	Region AOI `json:"region"`
+
+	// Last is the most recent sample, if there is one
+	Last *RegionSample `json:"last"`
+}
+
+// RegionMonitor takes frames continuously in a background goroutine and
+// publishes the RegionStats of a region of each to its subscribers.  It is a
+// diagnostics feed, for example of centroid and flux while tuning a control
+// loop, and is separate from any real-time data path
+type RegionMonitor struct {
+	// P is the camera frames are taken from
+	P Camera
+
+	// Busy is shared with the single frame routes and held while the
+	// monitor runs.  It may be nil
+	Busy *AcquisitionGuard
+
+	mu      sync.Mutex
+	running bool
+	region  AOI
+	stop    chan struct{}
+	done    chan struct{}
+	last    *RegionSample
+	subs    map[chan RegionSample]struct{}
+}
+
+// Start begins taking frames and publishing the stats of region.  If
+// interval is not zero, it is the shortest time between frames
+func (m *RegionMonitor) Start(region AOI, interval time.Duration) error {
+	if err := validateRegion(region); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return errors.New("region monitor is already running")
+	}
+	if err := m.Busy.Acquire("region monitor"); err != nil {
+		return err
+	}
+	m.running = true
+	m.region = region
+	m.last = nil
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run(region, interval, m.stop, m.done)
+	return nil
+}
+
+// Stop stops taking frames, waiting for the frame in progress to finish
+func (m *RegionMonitor) Stop() error {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return errors.New("region monitor is not running")
+	}
+	m.running = false
+	close(m.stop)
+	done := m.done
+	m.mu.Unlock()
+	<-done
+	return nil
+}
+
+// Status returns the status of the monitor and its latest sample
+func (m *RegionMonitor) Status() RegionMonitorStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := RegionMonitorStatus{Running: m.running, Region: m.region}
+	if m.last != nil {
+		last := *m.last
+		s.Last = &last
+	}
+	return s
+}
+
+// Subscribe returns a channel on which samples are published and a function
+// which unsubscribes and closes it.  Samples are dropped for subscribers that
+// are not ready to receive them, so a slow subscriber never holds up the
+// camera
+func (m *RegionMonitor) Subscribe() (<-chan RegionSample, func()) {
+	ch := make(chan RegionSample, 16)
+	m.mu.Lock()
+	if m.subs == nil {
+		m.subs = make(map[chan RegionSample]struct{})
+	}
+	m.subs[ch] = struct{}{}
+	m.mu.Unlock()
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			delete(m.subs, ch)
+			m.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (m *RegionMonitor) run(region AOI, interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	defer m.Busy.Release()
+	for frame := 1; ; frame++ {
+		start := time.Now()
+		sample := RegionSample{Frame: frame, Time: start}
+		img, err := m.P.GetFrame()
+		if err == nil {
+			sample.RegionStats, err = ComputeRegionStats(img, region)
+		}
+		if err != nil {
+			sample.Error = err.Error()
+		}
+		m.publish(sample)
+		wait := interval - time.Since(start)
+		if wait < 0 {
+			wait = 0
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (m *RegionMonitor) publish(s RegionSample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.last = &s
+	for ch := range m.subs {
+		select {
+		case ch <- s:
+		default:
+		}
+	}
+}
+
+// StartMonitor starts the monitor with the region and interval in the body,
+// {"left", "top", "width", "height", "interval"} with interval in seconds
+func (m *RegionMonitor) StartMonitor(w http.ResponseWriter, r *http.Request) {
+	req := struct {
+		AOI
+		Interval float64 `json:"interval"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	defer r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Interval < 0 {
+		http.Error(w, fmt.Sprintf("interval must be non-negative, got %f", req.Interval), http.StatusBadRequest)
+		return
+	}
+	if err = validateRegion(req.AOI); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = m.Start(req.AOI, util.SecsToDuration(req.Interval))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// StopMonitor stops the monitor
+func (m *RegionMonitor) StopMonitor(w http.ResponseWriter, r *http.Request) {
+	err := m.Stop()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetStatus responds with the RegionMonitorStatus as JSON
+func (m *RegionMonitor) GetStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(w).Encode(m.Status())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Stream publishes samples to the client as server-sent events, one JSON
+// RegionSample per event, until the client disconnects
+func (m *RegionMonitor) Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported by this connection", http.StatusInternalServerError)
+		return
+	}
+	ch, unsubscribe := m.Subscribe()
+	defer unsubscribe()
+	hdr := w.Header()
+	hdr.Set("Content-Type", "text/event-stream")
+	hdr.Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case s := <-ch:
+			js, err := json.Marshal(s)
+			if err != nil {
+				return
+			}
+			_, err = fmt.Fprintf(w, "data: %s\n\n", js)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// Inject puts region monitor routes on a table
+func (m *RegionMonitor) Inject(table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/region-monitor/start"}] = m.StartMonitor
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/region-monitor/stop"}] = m.StopMonitor
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/region-monitor"}] = m.GetStatus
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/region-monitor/stream"}] = m.Stream
+}
@@ -0,0 +1,296 @@
+package camera
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/astrogo/fitsio"
+	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/imgrec"
+	"github.com/nasa-jpl/golaborate/server/jobs"
+	"github.com/nasa-jpl/golaborate/util"
+)
+
+// ErrRecipeAborted is the error of a recipe which was aborted
+var ErrRecipeAborted = errors.New("recipe aborted")
+
+// RecipeStep is one step of a Recipe, Count frames at one exposure time
+type RecipeStep struct {
+	// Exposure is the exposure time, in seconds
+	Exposure float64 `json:"exposure"`
+
+	Count int `json:"count"`
+
+	// Shutter opens (true) or closes (false) the shutter for the step, for
+	// example to take darks.  If it is omitted the shutter is left alone
+	Shutter *bool `json:"shutter,omitempty"`
+
+	// Label is written to the header of each frame, e.g. "dark"
+	Label string `json:"label"`
+}
+
+// Recipe is a scripted sequence of acquisitions, such as 3x1s, 2x5s, and a
+// dark with the shutter closed
+type Recipe struct {
+	Steps []RecipeStep `json:"steps"`
+}
+
+// Validate returns an error if the recipe cannot be run on p
+func (r Recipe) Validate(p PictureTaker) error {
+	if len(r.Steps) == 0 {
+		return errors.New("recipe has no steps")
+	}
+	_, canShutter := p.(ShutterController)
+	for i, s := range r.Steps {
+		if !(s.Exposure > 0) || math.IsInf(s.Exposure, 0) {
+			return fmt.Errorf("step %d: exposure must be positive and finite, got %v", i, s.Exposure)
+		}
+		if s.Count < 1 {
+			return fmt.Errorf("step %d: count must be at least 1, got %d", i, s.Count)
+		}
+		if s.Shutter != nil && !canShutter {
+			return fmt.Errorf("step %d: the camera has no shutter to control", i)
+		}
+	}
+	return nil
+}
+
+// frames returns the total number of frames in the recipe
+func (r Recipe) frames() int {
+	n := 0
+	for _, s := range r.Steps {
+		n += s.Count
+	}
+	return n
+}
+
+// RecipeProgress describes the current or last recipe run by a RecipeRunner
+type RecipeProgress struct {
+	Running bool `json:"running"`
+
+	// Step is the index of the step in progress
+	Step int `json:"step"`
+
+	// Done and Total are the number of frames spooled and in the recipe
+	Done  int `json:"done"`
+	Total int `json:"total"`
+
+	// JobID is the ID of the recipe in jobs.Default
+	JobID string `json:"jobId"`
+
+	// Error is the error which ended the recipe, if any
+	Error string `json:"error,omitempty"`
+}
+
+// RecipeRunner executes Recipes in the background, spooling every frame to
+// Rec with the step's exposure time, shutter state, and label in its header.
+// Frames are spooled whether or not Rec is Enabled, but its Root must be set
+type RecipeRunner struct {
+	// P is the camera frames are taken from
+	P PictureTaker
+
+	// Rec is the recorder frames are spooled to.  TIFF recorders are
+	// supported, but only FITS files carry the per-step metadata
+	Rec *imgrec.Recorder
+
+	// Busy is shared with the single frame routes and held while a recipe
+	// runs.  It may be nil
+	Busy *AcquisitionGuard
+
+	mu       sync.Mutex
+	progress RecipeProgress
+	abort    chan struct{}
+	job      *jobs.Handle
+}
+
+// Start validates the recipe and begins running it in the background
+func (rr *RecipeRunner) Start(r Recipe) error {
+	if err := r.Validate(rr.P); err != nil {
+		return err
+	}
+	if rr.Rec == nil || rr.Rec.Root == "" {
+		return errors.New("recipes require an image recorder with a root folder")
+	}
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	if rr.progress.Running {
+		return ErrAcquisitionBusy{InProgress: "recipe"}
+	}
+	if err := rr.Busy.Acquire("recipe"); err != nil {
+		return err
+	}
+	rr.job = jobs.Default.Start("recipe")
+	rr.progress = RecipeProgress{Running: true, Total: r.frames(), JobID: rr.job.ID()}
+	rr.abort = make(chan struct{})
+	go rr.run(r, rr.abort)
+	return nil
+}
+
+// Abort stops the recipe in progress after the frame being taken
+func (rr *RecipeRunner) Abort() error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	if !rr.progress.Running {
+		return errors.New("no recipe is running")
+	}
+	select {
+	case <-rr.abort:
+	default:
+		close(rr.abort)
+	}
+	return nil
+}
+
+// Progress returns the progress of the current or last recipe
+func (rr *RecipeRunner) Progress() RecipeProgress {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	return rr.progress
+}
+
+func (rr *RecipeRunner) run(r Recipe, abort chan struct{}) {
+	defer rr.Busy.Release()
+	err := rr.execute(r, abort)
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.progress.Running = false
+	if err != nil {
+		rr.progress.Error = err.Error()
+	}
+	rr.job.Finish(err)
+}
+
+func (rr *RecipeRunner) execute(r Recipe, abort chan struct{}) error {
+	// put the exposure time and shutter back as they were found
+	if prev, err := rr.P.GetExposureTime(); err == nil {
+		defer rr.P.SetExposureTime(prev)
+	}
+	if sh, ok := rr.P.(ShutterController); ok {
+		if prev, err := sh.GetShutter(); err == nil {
+			defer sh.SetShutter(prev)
+		}
+	}
+	for i, step := range r.Steps {
+		rr.mu.Lock()
+		rr.progress.Step = i
+		rr.mu.Unlock()
+		err := rr.P.SetExposureTime(util.SecsToDuration(step.Exposure))
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+		if step.Shutter != nil {
+			err = rr.P.(ShutterController).SetShutter(*step.Shutter)
+			if err != nil {
+				return fmt.Errorf("step %d: %w", i, err)
+			}
+		}
+		for j := 0; j < step.Count; j++ {
+			select {
+			case <-abort:
+				return ErrRecipeAborted
+			default:
+			}
+			err = rr.capture(i, j, step)
+			if err != nil {
+				return fmt.Errorf("step %d frame %d: %w", i, j, err)
+			}
+			rr.mu.Lock()
+			rr.progress.Done++
+			rr.job.Progress(100 * float64(rr.progress.Done) / float64(rr.progress.Total))
+			rr.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+// capture takes one frame and spools it
+func (rr *RecipeRunner) capture(step, frame int, s RecipeStep) error {
+	start := time.Now()
+	img, err := rr.P.GetFrame()
+	if err != nil {
+		return err
+	}
+	if rr.Rec.IsTIFF() {
+		err = rr.Rec.WriteImage(img)
+	} else {
+		var cards []fitsio.Card
+		if mm, ok := rr.P.(MetadataMaker); ok {
+			cards = mm.CollectHeaderMetadata()
+		}
+		label := sanitizeCardString(s.Label)
+		if len(label) > maxCardValueLen {
+			label = label[:maxCardValueLen]
+		}
+		extra := []fitsio.Card{
+			{Name: "RCPSTEP", Value: step, Comment: "recipe step, 0-based"},
+			{Name: "RCPFRAME", Value: frame, Comment: "frame within the recipe step, 0-based"},
+			{Name: "RCPLABEL", Value: label, Comment: "recipe step label"},
+			{Name: "RCPEXP", Value: s.Exposure, Comment: "requested exposure time, s"},
+			{Name: "RCPSTART", Value: start.UTC().Format(time.RFC3339Nano), Comment: "time the frame was requested"}}
+		if s.Shutter != nil {
+			extra = append(extra, fitsio.Card{Name: "RCPSHUT", Value: *s.Shutter, Comment: "shutter open"})
+		}
+		err = WriteFits(rr.Rec, MergeCards(cards, extra), []image.Image{img})
+	}
+	if err != nil {
+		return err
+	}
+	rr.Rec.Incr()
+	return nil
+}
+
+// StartRecipe starts the Recipe in the body and responds with its job ID in
+// the X-Job-Id header
+func (rr *RecipeRunner) StartRecipe(w http.ResponseWriter, r *http.Request) {
+	recipe := Recipe{}
+	err := json.NewDecoder(r.Body).Decode(&recipe)
+	defer r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = rr.Start(recipe)
+	if err != nil {
+		code := http.StatusBadRequest
+		if errors.As(err, &ErrAcquisitionBusy{}) {
+			code = http.StatusConflict
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	w.Header().Set("X-Job-Id", rr.Progress().JobID)
+	w.WriteHeader(http.StatusOK)
+}
+
+// AbortRecipe aborts the recipe in progress
+func (rr *RecipeRunner) AbortRecipe(w http.ResponseWriter, r *http.Request) {
+	err := rr.Abort()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetRecipeProgress responds with the RecipeProgress as JSON
+func (rr *RecipeRunner) GetRecipeProgress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(w).Encode(rr.Progress())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Inject puts recipe routes on a table
+func (rr *RecipeRunner) Inject(table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/recipe"}] = rr.StartRecipe
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/recipe/abort"}] = rr.AbortRecipe
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/recipe"}] = rr.GetRecipeProgress
+}
@@ -0,0 +1,119 @@
+package camera
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+)
+
+// ErrRegionOutOfBounds is generated when a region does not lie within a frame
+var ErrRegionOutOfBounds = errors.New("region is not within the frame")
+
+// RegionStats are statistics of the pixels in a region of a frame.  The
+// centroid is in the same 1-based pixel coordinates as the region
+type RegionStats struct {
+	Pixels int     `json:"pixels"`
+	Sum    float64 `json:"sum"`
+	Mean   float64 `json:"mean"`
+	Max    uint16  `json:"max"`
+
+	// CentroidX and CentroidY are the flux-weighted center of the region.
+	// If the region sums to zero, they are its geometric center
+	CentroidX float64 `json:"centroidX"`
+	CentroidY float64 `json:"centroidY"`
+}
+
+// validateRegion checks that a region is not empty and is 1-based
+func validateRegion(region AOI) error {
+	if region.Left < 1 || region.Top < 1 {
+		return fmt.Errorf("region left and top are 1-based, got %d, %d", region.Left, region.Top)
+	}
+	if region.Width < 1 || region.Height < 1 {
+		return fmt.Errorf("region must have a positive width and height, got %dx%d", region.Width, region.Height)
+	}
+	return nil
+}
+
+// ComputeRegionStats returns the statistics of the pixels of a 16-bit
+// grayscale image within region, whose Left and Top are 1-based indices into
+// the image
+func ComputeRegionStats(img image.Image, region AOI) (RegionStats, error) {
+	var s RegionStats
+	if err := validateRegion(region); err != nil {
+		return s, err
+	}
+	g16, ok := img.(*image.Gray16)
+	if !ok {
+		return s, ErrNotGray16
+	}
+	b := g16.Bounds()
+	if region.Right()-1 > b.Dx() || region.Bottom()-1 > b.Dy() {
+		return s, fmt.Errorf("%w: region %+v, frame is %dx%d", ErrRegionOutOfBounds, region, b.Dx(), b.Dy())
+	}
+	uints := bytesToUint(g16.Pix)
+	stride := g16.Stride / 2
+	var sx, sy float64
+	for y := region.Top - 1; y < region.Bottom()-1; y++ {
+		row := uints[y*stride : y*stride+b.Dx()]
+		for x := region.Left - 1; x < region.Right()-1; x++ {
+			v := row[x]
+			if v > s.Max {
+				s.Max = v
+			}
+			f := float64(v)
+			s.Sum += f
+			sx += f * float64(x+1)
+			sy += f * float64(y+1)
+		}
+	}
+	s.Pixels = region.Width * region.Height
+	s.Mean = s.Sum / float64(s.Pixels)
+	if s.Sum == 0 {
+		s.CentroidX = float64(region.Left) + float64(region.Width-1)/2
+		s.CentroidY = float64(region.Top) + float64(region.Height-1)/2
+	} else {
+		s.CentroidX = sx / s.Sum
+		s.CentroidY = sy / s.Sum
+	}
+	return s, nil
+}
+
+// GetRegionStats returns an HTTP handler func which takes a frame and responds
+// with the RegionStats of the region given as an AOI in the request body
+func GetRegionStats(p PictureTaker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var region AOI
+		err := json.NewDecoder(r.Body).Decode(&region)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = validateRegion(region); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		img, err := p.GetFrame()
+		if err != nil {
+			http.Error(w, err.Error(), acquisitionErrorCode(err))
+			return
+		}
+		s, err := ComputeRegionStats(img, region)
+		if err != nil {
+			code := http.StatusInternalServerError
+			if errors.Is(err, ErrRegionOutOfBounds) {
+				code = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), code)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
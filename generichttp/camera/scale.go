@@ -0,0 +1,110 @@
+package camera
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"net/url"
+	"strconv"
+)
+
+// Stretch is a function applied to pixel values after they are windowed, to
+// bring out faint features when a frame is scaled to 8 bits
+type Stretch int
+
+const (
+	// StretchLinear maps the window linearly to 0..255
+	StretchLinear Stretch = iota
+
+	// StretchSqrt maps the square root of the windowed value to 0..255
+	StretchSqrt
+
+	// StretchLog maps the windowed value through the log function DS9 uses,
+	// log10(a*x+1)/log10(a) with a = 1000
+	StretchLog
+)
+
+// logExponent is a in DS9's log scale, log10(a*x+1)/log10(a)
+const logExponent = 1000
+
+// ParseStretch converts "linear", "sqrt", or "log" to a Stretch.  "" is linear
+func ParseStretch(s string) (Stretch, error) {
+	switch s {
+	case "", "linear":
+		return StretchLinear, nil
+	case "sqrt":
+		return StretchSqrt, nil
+	case "log":
+		return StretchLog, nil
+	default:
+		return StretchLinear, fmt.Errorf("stretch %q must be a member of {linear, sqrt, log}", s)
+	}
+}
+
+// apply maps x in [0,1] through the stretch
+func (s Stretch) apply(x float64) float64 {
+	switch s {
+	case StretchSqrt:
+		return math.Sqrt(x)
+	case StretchLog:
+		return math.Log10(logExponent*x+1) / math.Log10(logExponent)
+	default:
+		return x
+	}
+}
+
+// ScaleGray16 converts a 16-bit image in native byte order to 8 bits by
+// windowing to [lo,hi] and applying a stretch.  Pixels at or below lo are
+// black and those at or above hi are white.  hi must be greater than lo
+func ScaleGray16(img *image.Gray16, lo, hi uint16, stretch Stretch) *image.Gray {
+	lut := scaleLUT(lo, hi, stretch)
+	bound := img.Bounds()
+	out := image.NewGray(bound)
+	if len(img.Pix) == 0 {
+		return out
+	}
+	for i, v := range bytesToUint(img.Pix) {
+		out.Pix[i] = lut[v]
+	}
+	return out
+}
+
+// scaleLUT returns a lookup table from 16-bit to 8-bit values for ScaleGray16
+func scaleLUT(lo, hi uint16, stretch Stretch) []byte {
+	lut := make([]byte, math.MaxUint16+1)
+	span := float64(hi) - float64(lo)
+	for i := int(lo) + 1; i < len(lut); i++ {
+		if i >= int(hi) {
+			lut[i] = math.MaxUint8
+			continue
+		}
+		x := (float64(i) - float64(lo)) / span
+		lut[i] = byte(math.Round(math.MaxUint8 * stretch.apply(x)))
+	}
+	return lut
+}
+
+// parseScale reads the scale limits and stretch for ScaleGray16 from the lo,
+// hi, and stretch query parameters.  lo and hi default to the full 16-bit
+// range
+func parseScale(q url.Values) (uint16, uint16, Stretch, error) {
+	lo, hi := uint64(0), uint64(math.MaxUint16)
+	var err error
+	if s := q.Get("lo"); s != "" {
+		lo, err = strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return 0, 0, StretchLinear, fmt.Errorf("lo: %w", err)
+		}
+	}
+	if s := q.Get("hi"); s != "" {
+		hi, err = strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return 0, 0, StretchLinear, fmt.Errorf("hi: %w", err)
+		}
+	}
+	if hi <= lo {
+		return 0, 0, StretchLinear, fmt.Errorf("hi (%d) must be greater than lo (%d)", hi, lo)
+	}
+	stretch, err := ParseStretch(q.Get("stretch"))
+	return uint16(lo), uint16(hi), stretch, err
+}
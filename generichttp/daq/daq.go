@@ -7,14 +7,52 @@ package daq
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"go/types"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/server/jobs"
 )
 
+var (
+	// ErrInvalidSetting is wrapped by errors from devices when a setter is
+	// given a value the device does not accept, such as an unknown trigger
+	// mode or an out of range channel.  Handlers respond to these with 400
+	// Bad Request
+	ErrInvalidSetting = errors.New("invalid setting")
+
+	// ErrAlreadyPlaying is wrapped by errors from devices asked to start
+	// waveform playback while it is already occurring.  Handlers respond to
+	// these with 409 Conflict
+	ErrAlreadyPlaying = errors.New("already playing back a waveform")
+
+	// ErrNotPlaying is wrapped by errors from devices asked to stop waveform
+	// playback when it is not occurring.  Handlers respond to these with 409
+	// Conflict
+	ErrNotPlaying = errors.New("not playing back a waveform")
+
+	// ErrNotCommanded is wrapped by errors from devices asked for the output
+	// of a channel no voltage has been commanded on.  Handlers respond to
+	// these with 409 Conflict
+	ErrNotCommanded = errors.New("no output has been commanded")
+)
+
+// errorCode returns the HTTP status code for an error returned by a device
+func errorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidSetting):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrAlreadyPlaying), errors.Is(err, ErrNotPlaying), errors.Is(err, ErrNotCommanded):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
 // DAC is a model for simple digital to analog converter
 type DAC interface {
 	// Output sends a voltage on a given channel
@@ -42,7 +80,66 @@ type channelDN struct {
 	DN uint16 `json:"dn"`
 }
 
-// Output returns an HTTP handlerfunc that will write a voltage to a channel
+// rangeGetter is a DAC which reports its output range as "min,max"
+type rangeGetter interface {
+	GetRange(int) (string, error)
+}
+
+// overRanger is a DAC which may allow its output to exceed its range by 5%
+type overRanger interface {
+	GetOverRange(int) (bool, error)
+}
+
+// outputRange returns the output range of the channel in volts, including the
+// over range margin if it is enabled.  ok is false if the DAC does not report
+// its range or the range is not understood.  An error is returned if the
+// range can't be read, as for a channel the DAC does not have
+func outputRange(d DAC, channel int) (min, max float64, ok bool, err error) {
+	rg, ok := d.(rangeGetter)
+	if !ok {
+		return 0, 0, false, nil
+	}
+	rng, err := rg.GetRange(channel)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("channel %d: %w", channel, err)
+	}
+	parts := strings.Split(rng, ",")
+	if len(parts) != 2 {
+		return 0, 0, false, nil
+	}
+	min, err1 := strconv.ParseFloat(parts[0], 64)
+	max, err2 := strconv.ParseFloat(parts[1], 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false, nil
+	}
+	if or, ok := d.(overRanger); ok {
+		if over, err := or.GetOverRange(channel); err == nil && over {
+			margin := (max - min) * 0.05
+			min, max = min-margin, max+margin
+		}
+	}
+	return min, max, true, nil
+}
+
+// checkVoltage returns an error if the voltage is outside the output range
+// of the channel, or the range can't be read, as for a channel the DAC does
+// not have.  nil is returned if the DAC does not report its range or the range
+// is not understood, leaving the check to the DAC
+func checkVoltage(d DAC, channel int, voltage float64) error {
+	min, max, ok, err := outputRange(d, channel)
+	if err != nil || !ok {
+		return err
+	}
+	err = generichttp.FloatRange(min, max)(voltage)
+	if err != nil {
+		return fmt.Errorf("channel %d voltage: %w", channel, err)
+	}
+	return nil
+}
+
+// Output returns an HTTP handlerfunc that will write a voltage to a channel.
+// If the DAC reports its output range, voltages outside it are rejected with
+// a 400 before they are sent to the DAC
 func Output(d DAC) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var input channelVoltage
@@ -51,6 +148,11 @@ func Output(d DAC) http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		err = checkVoltage(d, input.Channel, input.Voltage)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		err = d.Output(input.Channel, input.Voltage)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -256,6 +358,394 @@ func GetOutputSimultaneous(d ExtendedDAC) http.HandlerFunc {
 	}
 }
 
+// RangePreserver is a DAC which can change range without the output jumping
+type RangePreserver interface {
+	// SetRangePreservingOutput sets the output range of a DAC channel and
+	// re-commands its last output voltage
+	SetRangePreservingOutput(int, string) error
+}
+
+// HTTPRangePreserver adds a route for glitch-free range changes to the table
+func HTTPRangePreserver(iface RangePreserver, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/range-preserving-output"}] = SetRangePreservingOutput(iface)
+}
+
+// SetRangePreservingOutput changes the output range of one channel of a DAC
+// without changing its output voltage
+func SetRangePreservingOutput(d RangePreserver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input channelRange
+		err := json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = d.SetRangePreservingOutput(input.Channel, input.Range)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// OutputReader is a DAC which remembers the voltage last commanded on each
+// channel.  This is the value the DAC was told to output, not a measurement
+// of the output
+type OutputReader interface {
+	// GetOutput returns the voltage last commanded on a channel
+	GetOutput(int) (float64, error)
+}
+
+// HTTPOutputReader adds a route for the last commanded output to the table
+func HTTPOutputReader(iface OutputReader, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/output"}] = GetOutput(iface)
+}
+
+// GetOutput returns an HTTP handlerfunc that responds with the voltage last
+// commanded on the channel in the query string, e.g. /output?channel=3, as
+// {"channel", "voltage"}.  The voltage is what was commanded, not measured.
+// If nothing has been commanded on the channel the response is 409
+func GetOutput(d OutputReader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ch, err := strconv.Atoi(r.URL.Query().Get("channel"))
+		if err != nil {
+			http.Error(w, "channel query parameter must be an integer", http.StatusBadRequest)
+			return
+		}
+		v, err := d.GetOutput(ch)
+		if err != nil {
+			http.Error(w, err.Error(), errorCode(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(channelVoltage{Channel: ch, Voltage: v})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// StagingDAC is a DAC which can accumulate output values for several channels
+// and apply them all at once, without the channels updating one by one
+type StagingDAC interface {
+	// Stage queues a voltage for a channel, to be written by Commit
+	Stage(int, float64) error
+
+	// Commit writes every staged voltage together and empties the stage
+	Commit() error
+
+	// Discard empties the stage without writing anything
+	Discard() error
+
+	// GetStaged returns the channels with a staged voltage and their voltages
+	GetStaged() ([]int, []float64, error)
+}
+
+// HTTPStaging adds routes for staged output to the table
+func HTTPStaging(iface StagingDAC, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/stage"}] = Stage(iface)
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/stage"}] = GetStaged(iface)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/stage/commit"}] = Commit(iface)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/stage/discard"}] = Discard(iface)
+}
+
+// Stage returns an HTTP handlerfunc that stages a voltage for a channel.  The
+// body is the same as for Output, {"channel", "voltage"}
+func Stage(d StagingDAC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input channelVoltage
+		err := json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = d.Stage(input.Channel, input.Voltage)
+		if err != nil {
+			http.Error(w, err.Error(), errorCode(err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// GetStaged returns an HTTP handlerfunc that responds with the staged
+// channels and voltages, in the same format as the body of OutputMulti
+func GetStaged(d StagingDAC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		chans, volts, err := d.GetStaged()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if chans == nil {
+			chans, volts = []int{}, []float64{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(channelsVoltages{Channels: chans, Voltages: volts})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// Commit returns an HTTP handlerfunc that writes the staged voltages
+func Commit(d StagingDAC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := d.Commit()
+		if err != nil {
+			http.Error(w, err.Error(), errorCode(err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Discard returns an HTTP handlerfunc that empties the stage
+func Discard(d StagingDAC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := d.Discard()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// ChannelEnabler is a DAC which can mark channels as in use or not
+type ChannelEnabler interface {
+	// SetChannelEnabled marks a channel as in use (true) or not (false)
+	SetChannelEnabled(int, bool) error
+
+	// GetChannelEnabled returns true if a channel is in use
+	GetChannelEnabled(int) (bool, error)
+}
+
+// HTTPChannelEnabler adds routes for the channel enable mask to the table
+func HTTPChannelEnabler(iface ChannelEnabler, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/channel-enabled"}] = SetChannelEnabled(iface)
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/channel-enabled"}] = GetChannelEnabled(iface)
+}
+
+type channelEnabled struct {
+	Channel int `json:"channel"`
+
+	Enabled bool `json:"enabled"`
+}
+
+// SetChannelEnabled enables or disables one channel of a DAC
+func SetChannelEnabled(d ChannelEnabler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input channelEnabled
+		err := json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = d.SetChannelEnabled(input.Channel, input.Enabled)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// GetChannelEnabled retrieves if one channel of a DAC is enabled
+func GetChannelEnabled(d ChannelEnabler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input channelEnabled
+		err := json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		boolean, err := d.GetChannelEnabled(input.Channel)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		hp := generichttp.HumanPayload{T: types.Bool, Bool: boolean}
+		hp.EncodeAndRespond(w, r)
+	}
+}
+
+// ChannelConfig is the configuration of one DAC channel.  Fields which do not
+// apply to a given DAC are left empty
+type ChannelConfig struct {
+	Range string `json:"range"`
+
+	// PowerUpScale and ClearScale are the output scale at power up and on clear,
+	// 0, 1, and 2 for zero, mid, and full scale
+	PowerUpScale int `json:"powerUpScale"`
+	ClearScale   int `json:"clearScale"`
+
+	OverTempShutdown bool `json:"overTempShutdown"`
+	OverRange        bool `json:"overRange"`
+	Simultaneous     bool `json:"simultaneous"`
+	Enabled          bool `json:"enabled"`
+
+	OperatingMode    string `json:"operatingMode,omitempty"`
+	TriggerMode      string `json:"triggerMode,omitempty"`
+	ClearOnUnderflow bool   `json:"clearOnUnderflow,omitempty"`
+}
+
+// DACConfig is a snapshot of the configuration of a DAC
+type DACConfig struct {
+	// Model is the model of DAC the configuration was taken from, and must
+	// match the DAC it is imported to
+	Model string `json:"model"`
+
+	Channels []ChannelConfig `json:"channels"`
+
+	TriggerDirection bool   `json:"triggerDirection,omitempty"`
+	TimerPeriod      uint32 `json:"timerPeriod,omitempty"`
+}
+
+// ConfigPorter is a DAC whose configuration can be saved and restored
+type ConfigPorter interface {
+	// ExportConfig returns the live configuration of the DAC
+	ExportConfig() (DACConfig, error)
+
+	// ImportConfig applies a configuration to the DAC
+	ImportConfig(DACConfig) error
+}
+
+// HTTPConfigPorter adds routes for saving and restoring the configuration to the table
+func HTTPConfigPorter(iface ConfigPorter, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/config"}] = ExportConfig(iface)
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/config"}] = ImportConfig(iface)
+}
+
+// ExportConfig responds with the configuration of the DAC as JSON
+func ExportConfig(d ConfigPorter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg, err := d.ExportConfig()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// ImportConfig applies the configuration in the request body to the DAC
+func ImportConfig(d ConfigPorter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var cfg DACConfig
+		err := json.NewDecoder(r.Body).Decode(&cfg)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = d.ImportConfig(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// SelfTester is a DAC which can check its own health
+type SelfTester interface {
+	// SelfTest returns an error describing the first problem found, if any
+	SelfTest() error
+}
+
+// HTTPSelfTester adds a route for running the self test to the table
+func HTTPSelfTester(iface SelfTester, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/selftest"}] = SelfTest(iface)
+}
+
+// SelfTest runs the self test, responding 200 if it passed and 500 with the
+// problem found if it did not
+func SelfTest(d SelfTester) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := d.SelfTest()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Calibrator is a DAC which can convert between volts and the DN it sends
+// to the hardware, using its calibration
+type Calibrator interface {
+	// VoltsToDN converts a voltage on a channel to DN
+	VoltsToDN(int, float64) (uint16, error)
+
+	// DNToVolts converts DN on a channel to a voltage
+	DNToVolts(int, uint16) (float64, error)
+}
+
+// HTTPCalibrator adds routes for converting between volts and DN to the table
+func HTTPCalibrator(iface Calibrator, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/convert/volts-to-dn"}] = VoltsToDN(iface)
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/convert/dn-to-volts"}] = DNToVolts(iface)
+}
+
+// VoltsToDN converts the voltage in a channelVoltage body to DN, responding
+// with a channelDN
+func VoltsToDN(d Calibrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input channelVoltage
+		err := json.NewDecoder(r.Body).Decode(&input)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		dn, err := d.VoltsToDN(input.Channel, input.Voltage)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(channelDN{Channel: input.Channel, DN: dn})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// DNToVolts converts the DN in a channelDN body to volts, responding with a
+// channelVoltage
+func DNToVolts(d Calibrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input channelDN
+		err := json.NewDecoder(r.Body).Decode(&input)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		v, err := d.DNToVolts(input.Channel, input.DN)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(channelVoltage{Channel: input.Channel, Voltage: v})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
 // WaveformDAC is a DAC which allows waveform playback
 type WaveformDAC interface {
 	ExtendedDAC
@@ -290,6 +780,95 @@ func HTTPWaveform(iface WaveformDAC, table generichttp.RouteTable) {
 	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/playback/stop"}] = StopWaveform(iface)
 }
 
+// ChannelProgress is the progress of waveform playback on one channel
+type ChannelProgress struct {
+	Channel int `json:"channel"`
+
+	// Samples is the length of the waveform
+	Samples int `json:"samples"`
+
+	// Transferred is the number of samples sent to the device so far
+	Transferred int `json:"transferred"`
+}
+
+// PlaybackStatus describes whether a DAC is playing back a waveform and how
+// far along each waveform channel is
+type PlaybackStatus struct {
+	PlayingBack bool `json:"playingBack"`
+
+	Channels []ChannelProgress `json:"channels"`
+}
+
+// PlaybackMonitor is a DAC which can report the status of waveform playback
+type PlaybackMonitor interface {
+	PlaybackStatus() (PlaybackStatus, error)
+}
+
+// HTTPPlaybackMonitor adds a route for the playback status to the table
+func HTTPPlaybackMonitor(iface PlaybackMonitor, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/playback/status"}] = GetPlaybackStatus(iface)
+}
+
+// GetPlaybackStatus responds with the PlaybackStatus as JSON
+func GetPlaybackStatus(d PlaybackMonitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := d.PlaybackStatus()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(status)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// WaveformPreviewer is a DAC which can return the waveform it has loaded
+type WaveformPreviewer interface {
+	// PreviewWaveform returns the waveform on a channel in volts, decimated
+	// to a number of points
+	PreviewWaveform(int, int) ([]float64, error)
+}
+
+// HTTPWaveformPreviewer adds a route for previewing loaded waveforms to the table
+func HTTPWaveformPreviewer(iface WaveformPreviewer, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/playback/preview"}] = PreviewWaveform(iface)
+}
+
+type channelPoints struct {
+	Channel int `json:"channel"`
+
+	Points int `json:"points"`
+}
+
+// PreviewWaveform responds with the waveform loaded on a channel as a JSON
+// array of volts.  Points is the number of samples to decimate to, or zero
+// for all of them
+func PreviewWaveform(d WaveformPreviewer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input channelPoints
+		err := json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		volts, err := d.PreviewWaveform(input.Channel, input.Points)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(volts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
 type channelOpMode struct {
 	Channel int `json:"channel"`
 
@@ -314,7 +893,7 @@ func SetOperatingMode(d WaveformDAC) http.HandlerFunc {
 		}
 		err = d.SetOperatingMode(input.Channel, input.OperatingMode)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorCode(err))
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -352,7 +931,7 @@ func SetTriggerMode(d WaveformDAC) http.HandlerFunc {
 		}
 		err = d.SetTriggerMode(input.Channel, input.TriggerMode)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorCode(err))
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -403,7 +982,7 @@ func StartWaveform(d WaveformDAC) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := d.StartWaveform()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorCode(err))
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -415,7 +994,7 @@ func StopWaveform(d WaveformDAC) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		err := d.StopWaveform()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorCode(err))
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -445,9 +1024,13 @@ func SetTimerPeriod(t Timer) http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if u.Uint == 0 {
+			http.Error(w, "timer period must be greater than zero", http.StatusBadRequest)
+			return
+		}
 		err = t.SetTimerPeriod(u.Uint)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorCode(err))
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -483,6 +1066,12 @@ type TriggerExport interface {
 	GetTriggerDirection() (bool, error)
 }
 
+// HTTPTriggerExport adds routes for the trigger direction to the table
+func HTTPTriggerExport(iface TriggerExport, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/trigger-direction"}] = SetTriggerDirection(iface)
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: "/trigger-direction"}] = GetTriggerDirection(iface)
+}
+
 // SetTriggerDirection causes the device to export a trigger if True, else import
 func SetTriggerDirection(t TriggerExport) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -494,7 +1083,7 @@ func SetTriggerDirection(t TriggerExport) http.HandlerFunc {
 		}
 		err = t.SetTriggerDirection(b.Bool)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorCode(err))
 			return
 		}
 		w.WriteHeader(http.StatusOK)
@@ -521,6 +1110,16 @@ type ChannelWaveformVolt struct {
 	waveform []float64
 }
 
+// Channel returns the channel index
+func (c ChannelWaveformVolt) Channel() int {
+	return c.channel
+}
+
+// Waveform returns the waveform data in volts
+func (c ChannelWaveformVolt) Waveform() []float64 {
+	return c.waveform
+}
+
 // ChannelWaveformDN is a combination of a channel index and waveform data
 type ChannelWaveformDN struct {
 	channel int
@@ -621,25 +1220,130 @@ type TimerDAC interface {
 	Timer
 }
 
+// TimerPeriodChecker is a TimerDAC which can check whether waveforms can be
+// played back at a sampling period without setting it
+type TimerPeriodChecker interface {
+	// CheckTimerPeriod returns an error wrapping ErrInvalidSetting if
+	// waveforms cannot be played back at the period in nanoseconds.  If they
+	// can, but with limitations, warning is not nil; SetTimerPeriod may
+	// return the same warning after setting the period
+	CheckTimerPeriod(uint32) (warning error, err error)
+}
+
+// WaveformInfo describes a waveform loaded by LoadCSVFloats
+type WaveformInfo struct {
+	// Samples is the length of the longest waveform
+	Samples int `json:"samples"`
+
+	// PeriodNs is the sample period in effect, which may be the requested
+	// period rounded to what the timer can count
+	PeriodNs uint32 `json:"period_ns"`
+
+	// RateHz is the sample rate
+	RateHz float64 `json:"rate_hz"`
+
+	// DurationS is the time taken to play back the longest waveform once
+	DurationS float64 `json:"duration_s"`
+
+	// Warning is not empty if the DAC accepted the period with limitations
+	Warning string `json:"warning,omitempty"`
+
+	// JobID is the ID of the load in jobs.Default, empty if the waveform was
+	// rejected before anything was sent to the DAC
+	JobID string `json:"jobId,omitempty"`
+}
+
 // LoadCSVFloats populates the waveform table of a DAC and sets the sampling
 // period in nanoseconds.  r is not closed and must be managed by the caller.
 // Playback is not started.
-func LoadCSVFloats(d TimerDAC, r io.Reader, periodNano uint32) error {
-	err := d.SetTimerPeriod(periodNano)
-	if err != nil {
-		return err
+//
+// The period is checked with CheckTimerPeriod if d is a TimerPeriodChecker,
+// and r is parsed, before anything is sent to the DAC.  The load is then
+// tracked as a "waveform" job in jobs.Default.
+func LoadCSVFloats(d TimerDAC, r io.Reader, periodNano uint32) (info WaveformInfo, err error) {
+	if periodNano == 0 {
+		return info, fmt.Errorf("%w: timer period must be greater than zero", ErrInvalidSetting)
+	}
+	var warning error
+	if c, ok := d.(TimerPeriodChecker); ok {
+		warning, err = c.CheckTimerPeriod(periodNano)
+		if err != nil {
+			return info, err
+		}
 	}
 	data, err := CSVToWaveformFloat(r)
 	if err != nil {
-		return err
+		return info, err
+	}
+	job := jobs.Default.Start("waveform")
+	info.JobID = job.ID()
+	defer func() { job.Finish(err) }()
+	err = d.SetTimerPeriod(periodNano)
+	if err != nil && !(warning != nil && errors.Is(err, warning)) {
+		return info, err
+	}
+	if warning != nil {
+		info.Warning = warning.Error()
 	}
 	for i := 0; i < len(data); i++ {
 		err = d.PopulateWaveform(data[i].channel, data[i].waveform)
 		if err != nil {
-			return err
+			return info, fmt.Errorf("channel %d: %w", data[i].channel, err)
+		}
+		if n := len(data[i].waveform); n > info.Samples {
+			info.Samples = n
+		}
+		job.Progress(100 * float64(i+1) / float64(len(data)))
+	}
+	info.PeriodNs, err = d.GetTimerPeriod()
+	if err != nil {
+		return info, err
+	}
+	info.RateHz = 1e9 / float64(info.PeriodNs)
+	info.DurationS = float64(info.Samples) * float64(info.PeriodNs) / 1e9
+	return info, nil
+}
+
+// HTTPWaveformUpload adds a route for loading a waveform from the request
+// body to the table
+func HTTPWaveformUpload(iface TimerDAC, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/waveform/upload"}] = UploadCSVFloats(iface)
+}
+
+// UploadCSVFloats loads a waveform from a CSV file in the request body, in the
+// format of CSVToWaveformFloat, with LoadCSVFloats.  The sampling period is
+// given in nanoseconds by the period_ns query parameter.  This allows a
+// client to send a waveform without first copying it onto the server.
+// Playback is not started.  The response is the WaveformInfo as JSON, and the
+// X-Job-Id header holds the ID of the load in jobs.Default.
+func UploadCSVFloats(d TimerDAC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		periodS := r.URL.Query().Get("period_ns")
+		if periodS == "" {
+			http.Error(w, "period_ns query parameter is required", http.StatusBadRequest)
+			return
+		}
+		period, err := strconv.ParseUint(periodS, 10, 32)
+		if err != nil {
+			http.Error(w, "period_ns: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		info, err := LoadCSVFloats(d, r.Body, uint32(period))
+		if info.JobID != "" {
+			w.Header().Set("X-Job-Id", info.JobID)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), errorCode(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(info)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	}
-	return nil
 }
 
 // HTTPDAC is a type that allows setting up a DAC satisfying any combination
@@ -664,9 +1368,46 @@ func NewHTTPDAC(d DAC) HTTPDAC {
 	if wd, ok := (d).(WaveformDAC); ok {
 		HTTPWaveform(wd, rt)
 	}
+	if wp, ok := (d).(WaveformPreviewer); ok {
+		HTTPWaveformPreviewer(wp, rt)
+	}
+	if pm, ok := (d).(PlaybackMonitor); ok {
+		HTTPPlaybackMonitor(pm, rt)
+	}
 	if t, ok := (d).(Timer); ok {
 		HTTPTimer(t, rt)
 	}
+	if te, ok := (d).(TriggerExport); ok {
+		HTTPTriggerExport(te, rt)
+	}
+	if td, ok := (d).(TimerDAC); ok {
+		HTTPWaveformUpload(td, rt)
+		HTTPWaveformExpression(td, rt)
+	}
+	if ce, ok := (d).(ChannelEnabler); ok {
+		HTTPChannelEnabler(ce, rt)
+	}
+	if rp, ok := (d).(RangePreserver); ok {
+		HTTPRangePreserver(rp, rt)
+	}
+	if or, ok := (d).(OutputReader); ok {
+		HTTPOutputReader(or, rt)
+	}
+	if sd, ok := (d).(StagingDAC); ok {
+		HTTPStaging(sd, rt)
+	}
+	if cp, ok := (d).(ConfigPorter); ok {
+		HTTPConfigPorter(cp, rt)
+	}
+	if st, ok := (d).(SelfTester); ok {
+		HTTPSelfTester(st, rt)
+	}
+	if c, ok := (d).(Calibrator); ok {
+		HTTPCalibrator(c, rt)
+	}
+	if di, ok := (d).(generichttp.DeviceInfo); ok {
+		generichttp.HTTPDeviceInfo(di, rt)
+	}
 	w.RouteTable = rt
 	return w
 }
@@ -0,0 +1,299 @@
+package daq
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// MaxExpressionSamples bounds the number of samples SampleExpression will
+// produce, so that a long duration at a short period cannot exhaust memory
+const MaxExpressionSamples = 1 << 20
+
+// exprFuncs are the functions an Expression may call, by number of arguments
+var (
+	exprFuncs1 = map[string]func(float64) float64{
+		"sin":   math.Sin,
+		"cos":   math.Cos,
+		"tan":   math.Tan,
+		"asin":  math.Asin,
+		"acos":  math.Acos,
+		"atan":  math.Atan,
+		"sinh":  math.Sinh,
+		"cosh":  math.Cosh,
+		"tanh":  math.Tanh,
+		"exp":   math.Exp,
+		"log":   math.Log,
+		"log10": math.Log10,
+		"sqrt":  math.Sqrt,
+		"abs":   math.Abs,
+		"floor": math.Floor,
+		"ceil":  math.Ceil,
+		"round": math.Round,
+		"sign": func(x float64) float64 {
+			switch {
+			case x > 0:
+				return 1
+			case x < 0:
+				return -1
+			default:
+				return 0
+			}
+		},
+	}
+	exprFuncs2 = map[string]func(float64, float64) float64{
+		"pow":   math.Pow,
+		"mod":   math.Mod,
+		"min":   math.Min,
+		"max":   math.Max,
+		"atan2": math.Atan2,
+	}
+	exprConsts = map[string]float64{
+		"pi": math.Pi,
+		"e":  math.E,
+	}
+)
+
+// Expression is a function of time, t, in seconds, parsed by
+// ParseExpression
+type Expression struct {
+	src  string
+	root ast.Expr
+}
+
+// ParseExpression parses a waveform given as an arithmetic expression of t,
+// such as "10*sin(2*pi*100*t)".  Expressions may contain numbers, t, pi, e,
+// the operators + - * / and parentheses, and calls to sin, cos, tan, asin,
+// acos, atan, sinh, cosh, tanh, exp, log, log10, sqrt, abs, floor, ceil, round,
+// sign, pow, mod, min, max, and atan2.  Nothing else is permitted; use pow for
+// exponentiation
+func ParseExpression(s string) (Expression, error) {
+	root, err := parser.ParseExpr(s)
+	if err != nil {
+		return Expression{}, fmt.Errorf("%w: expression %q: %v", ErrInvalidSetting, s, err)
+	}
+	if err = checkExpr(root); err != nil {
+		return Expression{}, fmt.Errorf("%w: expression %q: %v", ErrInvalidSetting, s, err)
+	}
+	return Expression{src: s, root: root}, nil
+}
+
+// checkExpr ensures an expression contains only what evalExpr can evaluate
+func checkExpr(n ast.Expr) error {
+	switch n := n.(type) {
+	case *ast.BasicLit:
+		if n.Kind != token.INT && n.Kind != token.FLOAT {
+			return fmt.Errorf("literal %s is not a number", n.Value)
+		}
+		_, err := strconv.ParseFloat(n.Value, 64)
+		return err
+	case *ast.Ident:
+		if _, ok := exprConsts[n.Name]; !ok && n.Name != "t" {
+			return fmt.Errorf("unknown name %q", n.Name)
+		}
+		return nil
+	case *ast.ParenExpr:
+		return checkExpr(n.X)
+	case *ast.UnaryExpr:
+		if n.Op != token.ADD && n.Op != token.SUB {
+			return fmt.Errorf("operator %s is not allowed", n.Op)
+		}
+		return checkExpr(n.X)
+	case *ast.BinaryExpr:
+		switch n.Op {
+		case token.ADD, token.SUB, token.MUL, token.QUO:
+		default:
+			return fmt.Errorf("operator %s is not allowed", n.Op)
+		}
+		if err := checkExpr(n.X); err != nil {
+			return err
+		}
+		return checkExpr(n.Y)
+	case *ast.CallExpr:
+		id, ok := n.Fun.(*ast.Ident)
+		if !ok || n.Ellipsis.IsValid() {
+			return fmt.Errorf("only calls to named functions are allowed")
+		}
+		want := 0
+		if _, ok := exprFuncs1[id.Name]; ok {
+			want = 1
+		} else if _, ok := exprFuncs2[id.Name]; ok {
+			want = 2
+		} else {
+			return fmt.Errorf("unknown function %q", id.Name)
+		}
+		if len(n.Args) != want {
+			return fmt.Errorf("%s takes %d arguments, got %d", id.Name, want, len(n.Args))
+		}
+		for _, arg := range n.Args {
+			if err := checkExpr(arg); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported syntax %T", n)
+	}
+}
+
+// evalExpr evaluates an expression which has passed checkExpr
+func evalExpr(n ast.Expr, t float64) float64 {
+	switch n := n.(type) {
+	case *ast.BasicLit:
+		f, _ := strconv.ParseFloat(n.Value, 64)
+		return f
+	case *ast.Ident:
+		if n.Name == "t" {
+			return t
+		}
+		return exprConsts[n.Name]
+	case *ast.ParenExpr:
+		return evalExpr(n.X, t)
+	case *ast.UnaryExpr:
+		if n.Op == token.SUB {
+			return -evalExpr(n.X, t)
+		}
+		return evalExpr(n.X, t)
+	case *ast.BinaryExpr:
+		x, y := evalExpr(n.X, t), evalExpr(n.Y, t)
+		switch n.Op {
+		case token.ADD:
+			return x + y
+		case token.SUB:
+			return x - y
+		case token.MUL:
+			return x * y
+		default:
+			return x / y
+		}
+	case *ast.CallExpr:
+		name := n.Fun.(*ast.Ident).Name
+		if f, ok := exprFuncs1[name]; ok {
+			return f(evalExpr(n.Args[0], t))
+		}
+		return exprFuncs2[name](evalExpr(n.Args[0], t), evalExpr(n.Args[1], t))
+	}
+	return math.NaN()
+}
+
+// Eval returns the value of the expression at time t, in seconds
+func (e Expression) Eval(t float64) float64 {
+	return evalExpr(e.root, t)
+}
+
+func (e Expression) String() string {
+	return e.src
+}
+
+// SampleExpression evaluates an expression at t = 0, period, 2*period, ...
+// for duration, both in seconds.  Every sample must be finite and within
+// [min, max]
+func SampleExpression(e Expression, period, duration, min, max float64) ([]float64, error) {
+	if !(period > 0) || !(duration > 0) {
+		return nil, fmt.Errorf("%w: period and duration must be positive, got %v and %v", ErrInvalidSetting, period, duration)
+	}
+	n := math.Round(duration / period)
+	if n < 1 {
+		n = 1
+	}
+	if n > MaxExpressionSamples {
+		return nil, fmt.Errorf("%w: %v s at a period of %v s is %.0f samples, more than the maximum of %d",
+			ErrInvalidSetting, duration, period, n, MaxExpressionSamples)
+	}
+	out := make([]float64, int(n))
+	for i := range out {
+		t := float64(i) * period
+		v := e.Eval(t)
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("%w: %s is not finite at t=%v", ErrInvalidSetting, e, t)
+		}
+		if v < min || v > max {
+			return nil, fmt.Errorf("%w: %s is %v at t=%v, outside [%v, %v]", ErrInvalidSetting, e, v, t, min, max)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// HTTPWaveformExpression adds a route for populating a channel's waveform
+// from an expression to the table
+func HTTPWaveformExpression(iface TimerDAC, table generichttp.RouteTable) {
+	table[generichttp.MethodPath{Method: http.MethodPost, Path: "/waveform/expression"}] = PopulateExpression(iface)
+}
+
+// waveformExpression is the body of a PopulateExpression request
+type waveformExpression struct {
+	Channel int `json:"channel"`
+
+	Expression string `json:"expression"`
+
+	// Duration is the length of the waveform, in seconds
+	Duration float64 `json:"duration"`
+
+	// Min and Max bound the waveform, in volts.  They default to the output
+	// range of the channel, and may narrow but not widen it.  If the DAC does
+	// not report its range, they default to -10 and 10
+	Min *float64 `json:"min"`
+	Max *float64 `json:"max"`
+}
+
+// PopulateExpression returns an HTTP handler func which samples an expression
+// of t with SampleExpression at the DAC's timer period and populates the
+// waveform of a channel with it.  The body is a JSON object
+// {"channel", "expression", "duration", "min", "max"}.  If the DAC reports its
+// output range, samples outside it are rejected with a 400 before anything is
+// sent to the DAC, since devices such as the AP235 clip them silently.
+// Playback is not started
+func PopulateExpression(d TimerDAC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := waveformExpression{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		min, max, ok, err := outputRange(d, req.Channel)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !ok {
+			min, max = -10, 10
+		}
+		if req.Min != nil && (!ok || *req.Min > min) {
+			min = *req.Min
+		}
+		if req.Max != nil && (!ok || *req.Max < max) {
+			max = *req.Max
+		}
+		expr, err := ParseExpression(req.Expression)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		periodNs, err := d.GetTimerPeriod()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		samples, err := SampleExpression(expr, float64(periodNs)/1e9, req.Duration, min, max)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = d.PopulateWaveform(req.Channel, samples)
+		if err != nil {
+			http.Error(w, err.Error(), errorCode(err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"go/types"
+	"io"
 	"net/http"
+	"runtime"
 	"sort"
 	"strings"
 
@@ -143,6 +145,83 @@ func (hp *HumanPayload) EncodeAndRespond(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// DecodeHumanPayload reads a payload written by EncodeAndRespond.  The type is
+// taken from the key of the JSON object; bytes and uint16s are encoded with
+// the same key as ints, so they are decoded as types.Int.
+func DecodeHumanPayload(r io.Reader) (HumanPayload, error) {
+	var (
+		hp  HumanPayload
+		obj map[string]json.RawMessage
+	)
+	err := json.NewDecoder(r).Decode(&obj)
+	if err != nil {
+		return hp, err
+	}
+	if len(obj) != 1 {
+		return hp, fmt.Errorf("payload must have exactly one field, got %d", len(obj))
+	}
+	for k, v := range obj {
+		switch k {
+		case "bool":
+			hp.T = types.Bool
+			err = json.Unmarshal(v, &hp.Bool)
+		case "int":
+			hp.T = types.Int
+			err = json.Unmarshal(v, &hp.Int)
+		case "f64":
+			hp.T = types.Float64
+			err = json.Unmarshal(v, &hp.Float)
+		case "str":
+			hp.T = types.String
+			err = json.Unmarshal(v, &hp.String)
+		default:
+			err = fmt.Errorf("unknown payload field %q", k)
+		}
+	}
+	return hp, err
+}
+
+// AsBool returns the payload as a bool, which must be its type
+func (hp HumanPayload) AsBool() (bool, error) {
+	if hp.T != types.Bool {
+		return false, fmt.Errorf("payload is %s, not bool", types.Typ[hp.T])
+	}
+	return hp.Bool, nil
+}
+
+// AsInt returns the payload as an int.  Bytes and uint16s are converted
+func (hp HumanPayload) AsInt() (int, error) {
+	switch hp.T {
+	case types.Int:
+		return hp.Int, nil
+	case types.Byte:
+		return int(hp.Byte), nil
+	case types.Uint16:
+		return int(hp.Uint16), nil
+	}
+	return 0, fmt.Errorf("payload is %s, not int", types.Typ[hp.T])
+}
+
+// AsFloat returns the payload as a float64.  Integers are converted
+func (hp HumanPayload) AsFloat() (float64, error) {
+	if hp.T == types.Float64 {
+		return hp.Float, nil
+	}
+	i, err := hp.AsInt()
+	if err != nil {
+		return 0, fmt.Errorf("payload is %s, not float64", types.Typ[hp.T])
+	}
+	return float64(i), nil
+}
+
+// AsString returns the payload as a string, which must be its type
+func (hp HumanPayload) AsString() (string, error) {
+	if hp.T != types.String {
+		return "", fmt.Errorf("payload is %s, not string", types.Typ[hp.T])
+	}
+	return hp.String, nil
+}
+
 // GetFloat calls a float-getting function and returns the response
 // as json {'f64': value}
 func GetFloat(fcn func() (float64, error)) http.HandlerFunc {
@@ -178,6 +257,43 @@ func SetFloat(fcn func(float64) error) http.HandlerFunc {
 	}
 }
 
+// FloatRange returns a validator for SetFloatValidated which accepts values
+// in [min, max]
+func FloatRange(min, max float64) func(float64) error {
+	return func(f float64) error {
+		if f < min || f > max {
+			return fmt.Errorf("value %g out of range, must be between %g and %g", f, min, max)
+		}
+		return nil
+	}
+}
+
+// SetFloatValidated is SetFloat, but the value is checked with valid before
+// fcn is called.  If valid returns an error, the response is 400 with the
+// error as the message
+func SetFloatValidated(fcn func(float64) error, valid func(float64) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := FloatT{}
+		err := json.NewDecoder(r.Body).Decode(&f)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = valid(f.F64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = fcn(f.F64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
 // GetInt calls an int-getting function and returns the response
 // as json {'int': value}
 func GetInt(fcn func() (int, error)) http.HandlerFunc {
@@ -213,6 +329,43 @@ func SetInt(fcn func(int) error) http.HandlerFunc {
 	}
 }
 
+// IntRange returns a validator for SetIntValidated which accepts values in
+// [min, max]
+func IntRange(min, max int) func(int) error {
+	return func(i int) error {
+		if i < min || i > max {
+			return fmt.Errorf("value %d out of range, must be between %d and %d", i, min, max)
+		}
+		return nil
+	}
+}
+
+// SetIntValidated is SetInt, but the value is checked with valid before fcn
+// is called.  If valid returns an error, the response is 400 with the error
+// as the message
+func SetIntValidated(fcn func(int) error, valid func(int) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f := IntT{}
+		err := json.NewDecoder(r.Body).Decode(&f)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = valid(f.Int)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = fcn(f.Int)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
 // GetString calls a string-getting function and returns the response
 // as json {'str': value}
 func GetString(fcn func() (string, error)) http.HandlerFunc {
@@ -352,3 +505,116 @@ func SubMuxSanitize(str string) string {
 	}
 	return str
 }
+
+// DeviceInfo describes a device which can identify itself.  Devices which do
+// not know one of the fields return an empty string for it
+type DeviceInfo interface {
+	// GetModel returns the model of the device
+	GetModel() (string, error)
+
+	// GetSerialNumber returns the serial number of the device
+	GetSerialNumber() (string, error)
+
+	// GetFirmwareVersion returns the firmware version of the device
+	GetFirmwareVersion() (string, error)
+}
+
+// DeviceInfoT holds the fields of a DeviceInfo
+type DeviceInfoT struct {
+	Model           string `json:"model"`
+	SerialNumber    string `json:"serialNumber"`
+	FirmwareVersion string `json:"firmwareVersion"`
+}
+
+// GetDeviceInfo returns an HTTP handler func which responds with the
+// DeviceInfoT of the device as JSON
+func GetDeviceInfo(d DeviceInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			info DeviceInfoT
+			err  error
+		)
+		info.Model, err = d.GetModel()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		info.SerialNumber, err = d.GetSerialNumber()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		info.FirmwareVersion, err = d.GetFirmwareVersion()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(info)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// HTTPDeviceInfo adds the /device-info route to a table
+func HTTPDeviceInfo(d DeviceInfo, table RouteTable) {
+	table[MethodPath{Method: http.MethodGet, Path: "/device-info"}] = GetDeviceInfo(d)
+}
+
+// ComponentVersioner describes a device which can report the versions of the
+// software and hardware beneath a server, such as its SDK, driver, and
+// firmware
+type ComponentVersioner interface {
+	// GetComponentVersions returns versions keyed by component, e.g. "sdk".
+	// If some cannot be read, those which could are returned with an error
+	GetComponentVersions() (map[string]string, error)
+}
+
+// VersionInfo is the response of the /version route
+type VersionInfo struct {
+	// Program is the name of the server program, e.g. andorhttp3
+	Program string `json:"program"`
+
+	// Version is the build version of the program, typically injected via
+	// ldflags
+	Version string `json:"version"`
+
+	// Go is the version of Go the program was built with
+	Go string `json:"go"`
+
+	// Components are the versions reported by a ComponentVersioner
+	Components map[string]string `json:"components,omitempty"`
+
+	// Error is the error encountered reading Components, if any
+	Error string `json:"error,omitempty"`
+}
+
+// GetVersion returns an HTTP handler func which responds with the VersionInfo
+// of the program as JSON.  cv may be nil.  A device which cannot report its
+// versions does not cause an error status, so that the build can always be
+// read
+func GetVersion(program, version string, cv ComponentVersioner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info := VersionInfo{Program: program, Version: version, Go: runtime.Version()}
+		if cv != nil {
+			var err error
+			info.Components, err = cv.GetComponentVersions()
+			if err != nil {
+				info.Error = err.Error()
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err := json.NewEncoder(w).Encode(info)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// HTTPVersion adds the /version route to a table
+func HTTPVersion(program, version string, cv ComponentVersioner, table RouteTable) {
+	table[MethodPath{Method: http.MethodGet, Path: "/version"}] = GetVersion(program, version, cv)
+}
@@ -0,0 +1,98 @@
+package generichttp_test
+
+import (
+	"encoding/json"
+	"errors"
+	"go/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+func roundTrip(t *testing.T, hp generichttp.HumanPayload) generichttp.HumanPayload {
+	w := httptest.NewRecorder()
+	hp.EncodeAndRespond(w, httptest.NewRequest("GET", "/", nil))
+	out, err := generichttp.DecodeHumanPayload(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestHumanPayloadRoundTripBool(t *testing.T) {
+	out := roundTrip(t, generichttp.HumanPayload{T: types.Bool, Bool: true})
+	b, err := out.AsBool()
+	if err != nil || !b {
+		t.Errorf("expected true, got %v, %v", b, err)
+	}
+}
+
+func TestHumanPayloadRoundTripInt(t *testing.T) {
+	out := roundTrip(t, generichttp.HumanPayload{T: types.Int, Int: -42})
+	i, err := out.AsInt()
+	if err != nil || i != -42 {
+		t.Errorf("expected -42, got %d, %v", i, err)
+	}
+}
+
+func TestHumanPayloadRoundTripUint16(t *testing.T) {
+	out := roundTrip(t, generichttp.HumanPayload{T: types.Uint16, Uint16: 65535})
+	i, err := out.AsInt()
+	if err != nil || i != 65535 {
+		t.Errorf("expected 65535, got %d, %v", i, err)
+	}
+}
+
+func TestHumanPayloadRoundTripFloat(t *testing.T) {
+	out := roundTrip(t, generichttp.HumanPayload{T: types.Float64, Float: 1.25})
+	f, err := out.AsFloat()
+	if err != nil || f != 1.25 {
+		t.Errorf("expected 1.25, got %f, %v", f, err)
+	}
+}
+
+func TestHumanPayloadRoundTripString(t *testing.T) {
+	out := roundTrip(t, generichttp.HumanPayload{T: types.String, String: "hello"})
+	s, err := out.AsString()
+	if err != nil || s != "hello" {
+		t.Errorf("expected hello, got %q, %v", s, err)
+	}
+}
+
+func TestHumanPayloadWrongType(t *testing.T) {
+	hp := generichttp.HumanPayload{T: types.String, String: "hello"}
+	if _, err := hp.AsFloat(); err == nil {
+		t.Error("expected error converting string payload to float")
+	}
+}
+
+func TestDecodeHumanPayloadUnknownField(t *testing.T) {
+	_, err := generichttp.DecodeHumanPayload(strings.NewReader(`{"f32": 1}`))
+	if err == nil {
+		t.Error("expected error decoding unknown field")
+	}
+}
+
+type brokenVersioner struct{}
+
+func (brokenVersioner) GetComponentVersions() (map[string]string, error) {
+	return map[string]string{"wrapper": "3"}, errors.New("firmware: no camera")
+}
+
+func TestVersionReportsPartialComponents(t *testing.T) {
+	w := httptest.NewRecorder()
+	generichttp.GetVersion("srv", "7", brokenVersioner{})(w, httptest.NewRequest("GET", "/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var info generichttp.VersionInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Program != "srv" || info.Version != "7" || info.Components["wrapper"] != "3" || info.Error == "" {
+		t.Errorf("unexpected version info %+v", info)
+	}
+}
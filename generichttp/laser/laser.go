@@ -3,10 +3,15 @@ package laser
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
 	"net/http"
+	"time"
 
 	"github.com/nasa-jpl/golaborate/generichttp"
+	"github.com/nasa-jpl/golaborate/server/jobs"
+	"github.com/nasa-jpl/golaborate/util"
 )
 
 // CenterBandwidth is a struct holding the center wavelength (nm) and full bandwidth (nm) of a VARIA
@@ -58,8 +63,25 @@ type CurrentController interface {
 	GetCurrent() (float64, error)
 }
 
-// SetCurrent configures the output current of the laser
+// CurrentLimiter is a current controller with a limit on its output current
+type CurrentLimiter interface {
+	// GetCurrentLimit retrieves the maximum output current of the controller
+	GetCurrentLimit() (float64, error)
+}
+
+// SetCurrent configures the output current of the laser.  If c is a
+// CurrentLimiter, currents outside [0, limit] are rejected with a 400
+// before they are sent to the controller
 func SetCurrent(c CurrentController) http.HandlerFunc {
+	if l, ok := c.(CurrentLimiter); ok {
+		return generichttp.SetFloatValidated(c.SetCurrent, func(f float64) error {
+			limit, err := l.GetCurrentLimit()
+			if err != nil {
+				return fmt.Errorf("unable to check current against the limit: %w", err)
+			}
+			return generichttp.FloatRange(0, limit)(f)
+		})
+	}
 	return generichttp.SetFloat(c.SetCurrent)
 }
 
@@ -68,6 +90,136 @@ func GetCurrent(c CurrentController) http.HandlerFunc {
 	return generichttp.GetFloat(c.GetCurrent)
 }
 
+// Ramp is a target value and the rate at which to approach it, in units per second
+type Ramp struct {
+	Target float64 `json:"target"`
+	Rate   float64 `json:"rate"`
+}
+
+// CurrentRamper can smoothly ramp its output current to a new value
+type CurrentRamper interface {
+	// RampCurrent begins a ramp of the output current to target at ratePerSec
+	RampCurrent(target, ratePerSec float64) error
+
+	// StopRamp aborts a ramp in progress
+	StopRamp() error
+}
+
+// RampMonitor can report the status of its most recent ramp
+type RampMonitor interface {
+	RampStatus() util.RampStatus
+}
+
+// ErrRampStopped is the error of a ramp job which was aborted
+var ErrRampStopped = errors.New("ramp stopped before reaching its target")
+
+// TrackRamp registers a job of the given kind with jobs.Default and follows
+// the ramp of m in the background until it ends.  It should be called just
+// after the ramp is started
+func TrackRamp(kind string, m RampMonitor) *jobs.Handle {
+	job := jobs.Default.Start(kind)
+	go func() {
+		for {
+			st := m.RampStatus()
+			job.Progress(100 * st.Fraction)
+			if !st.Active {
+				err := st.Err
+				if err == nil && st.Stopped {
+					err = ErrRampStopped
+				}
+				job.Finish(err)
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+	return job
+}
+
+// RampCurrent begins a current ramp, taking a Ramp as JSON.  If c is a
+// RampMonitor, the ramp is tracked as a job and its ID is returned in the
+// X-Job-Id header
+func RampCurrent(c CurrentRamper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ramp := Ramp{}
+		err := json.NewDecoder(r.Body).Decode(&ramp)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = c.RampCurrent(ramp.Target, ramp.Rate)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if m, ok := c.(RampMonitor); ok {
+			w.Header().Set("X-Job-Id", TrackRamp("ramp", m).ID())
+		}
+	}
+}
+
+// StopRamp aborts a ramp in progress
+func StopRamp(c CurrentRamper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := c.StopRamp()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// Modulation describes if a controller's output is modulated and from where
+type Modulation struct {
+	Enabled bool   `json:"enabled"`
+	Source  string `json:"source"`
+}
+
+// ModulationController can modulate its output from an internal or external source
+type ModulationController interface {
+	// SetModulation enables or disables modulation and selects its source
+	SetModulation(enabled bool, source string) error
+
+	// GetModulation retrieves the modulation configuration
+	GetModulation() (Modulation, error)
+}
+
+// SetModulation configures the modulation of the laser, taking a Modulation as JSON
+func SetModulation(c ModulationController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m := Modulation{}
+		err := json.NewDecoder(r.Body).Decode(&m)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = c.SetModulation(m.Enabled, m.Source)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetModulation retrieves the modulation of the laser as JSON
+func GetModulation(c ModulationController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m, err := c.GetModulation()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(m)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
 // PowerController can control its output power
 type PowerController interface {
 	// SetPower sets the output power level of the the device
@@ -197,6 +349,28 @@ func GetEmissionRuntime(e EmissionInfoHaver) http.HandlerFunc {
 	return generichttp.GetFloat(e.GetEmissionRuntime)
 }
 
+// TECMonitor can report the temperature of the diode's TEC
+type TECMonitor interface {
+	// GetTECTemperature retrieves the temperature of the TEC in Celsius
+	GetTECTemperature() (float64, error)
+}
+
+// GetTECTemperature returns an http.HandlerFunc for GetTECTemperature
+func GetTECTemperature(t TECMonitor) http.HandlerFunc {
+	return generichttp.GetFloat(t.GetTECTemperature)
+}
+
+// PhotodiodeMonitor can report the current of its monitor photodiode
+type PhotodiodeMonitor interface {
+	// GetPhotodiodeCurrent retrieves the monitor photodiode current
+	GetPhotodiodeCurrent() (float64, error)
+}
+
+// GetPhotodiodeCurrent returns an http.HandlerFunc for GetPhotodiodeCurrent
+func GetPhotodiodeCurrent(p PhotodiodeMonitor) http.HandlerFunc {
+	return generichttp.GetFloat(p.GetPhotodiodeCurrent)
+}
+
 // HTTPLaserController wraps a LaserController in an HTTP route table
 type HTTPLaserController struct {
 	// Ctl is the underlying laser controller
@@ -217,6 +391,14 @@ func NewHTTPLaserController(ctl Controller) HTTPLaserController {
 		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/current"}] = GetCurrent(currentctl)
 		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/current"}] = SetCurrent(currentctl)
 	}
+	if ramper, ok := ctl.(CurrentRamper); ok {
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/current/ramp"}] = RampCurrent(ramper)
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/current/ramp/stop"}] = StopRamp(ramper)
+	}
+	if modctl, ok := ctl.(ModulationController); ok {
+		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/modulation"}] = GetModulation(modctl)
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/modulation"}] = SetModulation(modctl)
+	}
 	if powerctl, ok := ctl.(PowerController); ok {
 		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/power"}] = GetPower(powerctl)
 		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/power"}] = SetPower(powerctl)
@@ -238,6 +420,15 @@ func NewHTTPLaserController(ctl Controller) HTTPLaserController {
 	if emh, ok := ctl.(EmissionInfoHaver); ok {
 		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/emission-runtime"}] = GetEmissionRuntime(emh)
 	}
+	if tec, ok := ctl.(TECMonitor); ok {
+		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/tec/temperature"}] = GetTECTemperature(tec)
+	}
+	if pd, ok := ctl.(PhotodiodeMonitor); ok {
+		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/photodiode/current"}] = GetPhotodiodeCurrent(pd)
+	}
+	if di, ok := ctl.(generichttp.DeviceInfo); ok {
+		generichttp.HTTPDeviceInfo(di, rt)
+	}
 	h.RouteTable = rt
 	return h
 }
@@ -55,6 +55,9 @@ func NewHTTPMotionController(c Controller) HTTPMotionController {
 	if stopper, ok := (c).(Stopper); ok {
 		HTTPStop(stopper, rt)
 	}
+	if di, ok := (c).(generichttp.DeviceInfo); ok {
+		generichttp.HTTPDeviceInfo(di, rt)
+	}
 	w.RouteTable = rt
 	return w
 }
@@ -8,6 +8,7 @@ import (
 	"go/types"
 	"net/http"
 	"reflect"
+	"strings"
 	"unsafe"
 
 	"github.com/nasa-jpl/golaborate/generichttp"
@@ -247,6 +248,10 @@ type Oscilloscope interface {
 
 	// AcquireWaveform triggers a measurement on the scope and returns the data
 	AcquireWaveform([]string) (oscilloscope.Waveform, error)
+
+	// AcquireWaveformDecimated is AcquireWaveform, with the data reduced by a
+	// factor of n as by oscilloscope.Waveform.Decimate
+	AcquireWaveformDecimated(chans []string, n int, envelope bool) (oscilloscope.Waveform, error)
 }
 
 // SetTimebase exposes an HTTP interface to SetTimebase
@@ -346,9 +351,29 @@ func StartAcq(o Oscilloscope) http.HandlerFunc {
 
 type channels struct {
 	Chans []string `json:"channels"`
+
+	// Decimate is the decimation factor, see oscilloscope.Waveform.Decimate.
+	// Values less than 2 return the full waveform
+	Decimate int `json:"decimate"`
+
+	// Envelope decimates to the min and max of each bin instead of every
+	// Nth sample
+	Envelope bool `json:"envelope"`
+}
+
+// wantsJSON returns true if the request asks for JSON, by the fmt query
+// parameter or the Accept header
+func wantsJSON(r *http.Request) bool {
+	if f := r.URL.Query().Get("fmt"); f != "" {
+		return f == "json"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
 }
 
-// AcquireWaveform transfers the data from the oscilloscope to the user
+// AcquireWaveform transfers the data from the oscilloscope to the user.
+// The data is CSV unless the fmt query parameter is json, or fmt is not given
+// and the Accept header includes application/json.  If decimate is
+// given in the body, the waveform is decimated before it is sent
 func AcquireWaveform(o Oscilloscope) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		chans := channels{}
@@ -358,14 +383,25 @@ func AcquireWaveform(o Oscilloscope) http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		data, err := o.AcquireWaveform(chans.Chans)
+		var data oscilloscope.Waveform
+		if chans.Decimate > 1 {
+			data, err = o.AcquireWaveformDecimated(chans.Chans, chans.Decimate, chans.Envelope)
+		} else {
+			data, err = o.AcquireWaveform(chans.Chans)
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		w.Header().Set("Content-Type", "text/csv")
-		w.WriteHeader(http.StatusOK)
-		err = data.EncodeCSV(w)
+		if wantsJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			err = data.EncodeJSON(w)
+		} else {
+			w.Header().Set("Content-Type", "text/csv")
+			w.WriteHeader(http.StatusOK)
+			err = data.EncodeCSV(w)
+		}
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"go/types"
+	"image"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -32,6 +33,41 @@ type Recorder struct {
 
 	// Enabled is a flag unused by this struct that allows consumers to disable its use in their code
 	Enabled bool
+
+	// Format is the file format, FormatFITS or FormatTIFF.  Empty is FITS
+	Format string
+}
+
+const (
+	// FormatFITS records frames as FITS files, streamed through Write
+	FormatFITS = "fits"
+
+	// FormatTIFF records frames as 16-bit TIFF files, written by WriteImage
+	FormatTIFF = "tiff"
+)
+
+// ext returns the file extension for the recorder's format, without the dot
+func (r *Recorder) ext() string {
+	if r.Format == FormatTIFF {
+		return "tiff"
+	}
+	return "fits"
+}
+
+// IsTIFF returns true if the recorder writes TIFF files
+func (r *Recorder) IsTIFF() bool {
+	return r.Format == FormatTIFF
+}
+
+// filename returns the full path of the current file, creating its folder
+func (r *Recorder) filename() (string, error) {
+	r.updateFolder()
+	fldr, err := r.mkDir()
+	if err != nil {
+		return "", err
+	}
+	fn := fmt.Sprintf("%s%06d.%s", r.Prefix, r.counter, r.ext())
+	return path.Join(fldr, fn), nil
 }
 
 // updateFolder checks the current time and updates the folder and timestamp as needed
@@ -53,15 +89,12 @@ func (r *Recorder) mkDir() (string, error) {
 // Write implements io.Writer and writes the contents of a fits file to disk
 func (r *Recorder) Write(p []byte) (n int, err error) {
 	// make sure the folder exists
-	r.updateFolder()
-	fldr, err := r.mkDir()
+	fn, err := r.filename()
 	if err != nil {
 		return 0, err
 	}
 
 	// now open the file and write to it
-	fn := fmt.Sprintf("%s%06d.fits", r.Prefix, r.counter)
-	fn = path.Join(fldr, fn)
 	var fid *os.File
 	fid, err = os.OpenFile(fn, os.O_APPEND|os.O_WRONLY, 0666)
 	if err != nil && os.IsNotExist(err) {
@@ -77,6 +110,28 @@ func (r *Recorder) Write(p []byte) (n int, err error) {
 	return fid.Write(p)
 }
 
+// WriteImage writes a 16-bit image to disk as a TIFF file.  Incr is not called.
+func (r *Recorder) WriteImage(img image.Image) error {
+	g16, ok := img.(*image.Gray16)
+	if !ok {
+		return fmt.Errorf("imgrec: TIFF recording requires *image.Gray16, got %T", img)
+	}
+	fn, err := r.filename()
+	if err != nil {
+		return err
+	}
+	fid, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	err = EncodeTIFF16(fid, g16)
+	if err != nil {
+		fid.Close()
+		return err
+	}
+	return fid.Close()
+}
+
 // Incr updates the filename counter; it scans the folder to do so.  If there is an error, the counter is not incremented
 func (r *Recorder) Incr() {
 	dn, _ := r.mkDir()
@@ -85,18 +140,19 @@ func (r *Recorder) Incr() {
 		return
 	}
 	count := 0
+	ext := "." + r.ext()
 	for _, file := range files {
-		// skip directories, non-fits, and wrong prefix
+		// skip directories, other formats, and wrong prefix
 		if file.IsDir() {
 			continue
 		}
 		fn := file.Name()
-		if !strings.HasSuffix(fn, ".fits") || !strings.HasPrefix(fn, r.Prefix) {
+		if !strings.HasSuffix(fn, ext) || !strings.HasPrefix(fn, r.Prefix) {
 			continue
 		}
 		// guaranteed match
 		bit := strings.Split(fn, r.Prefix)[1]
-		bit = bit[:len(bit)-5] // pop fits
+		bit = bit[:len(bit)-len(ext)] // pop extension
 		n, err := strconv.Atoi(bit)
 		if err != nil {
 			return
@@ -167,6 +223,31 @@ func (h HTTPWrapper) GetPrefix(w http.ResponseWriter, r *http.Request) {
 	hp.EncodeAndRespond(w, r)
 }
 
+// SetFormat sets the recorder's file format, fits or tiff
+func (h HTTPWrapper) SetFormat(w http.ResponseWriter, r *http.Request) {
+	str := generichttp.StrT{}
+	err := json.NewDecoder(r.Body).Decode(&str)
+	defer r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f := strings.ToLower(str.Str)
+	if f != FormatFITS && f != FormatTIFF {
+		http.Error(w, fmt.Sprintf("format must be fits or tiff, got %s", str.Str), http.StatusBadRequest)
+		return
+	}
+	h.Recorder.Format = f
+	h.Recorder.counter = 0
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetFormat gets the recorder's file format and sends it back as JSON
+func (h HTTPWrapper) GetFormat(w http.ResponseWriter, r *http.Request) {
+	hp := generichttp.HumanPayload{T: types.String, String: h.Recorder.ext()}
+	hp.EncodeAndRespond(w, r)
+}
+
 // GetEnabled returns the Recorder's Enabled field
 func (h HTTPWrapper) GetEnabled(w http.ResponseWriter, r *http.Request) {
 	hp := generichttp.HumanPayload{T: types.Bool, Bool: h.Recorder.Enabled}
@@ -187,7 +268,7 @@ func (h HTTPWrapper) SetEnabled(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-// Inject adds GET and POST routes for /autorwrite/root and /autowrite/prefix to the HTTPer which manipulate this wrapper's recorder
+// Inject adds GET and POST routes for /autowrite/root, prefix, enabled, and format to the HTTPer which manipulate this wrapper's recorder
 func (h HTTPWrapper) Inject(rt generichttp.RouteTable) {
 	rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/autowrite/root"}] = h.SetRoot
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/autowrite/root"}] = h.GetRoot
@@ -195,4 +276,6 @@ func (h HTTPWrapper) Inject(rt generichttp.RouteTable) {
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/autowrite/prefix"}] = h.GetPrefix
 	rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/autowrite/enabled"}] = h.SetEnabled
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/autowrite/enabled"}] = h.GetEnabled
+	rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/autowrite/format"}] = h.SetFormat
+	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/autowrite/format"}] = h.GetFormat
 }
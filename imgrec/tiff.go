@@ -0,0 +1,97 @@
+package imgrec
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+)
+
+// TIFF tags and types used by EncodeTIFF16, from the TIFF 6.0 spec
+const (
+	tagImageWidth                = 256
+	tagImageLength               = 257
+	tagBitsPerSample             = 258
+	tagCompression               = 259
+	tagPhotometricInterpretation = 262
+	tagStripOffsets              = 273
+	tagSamplesPerPixel           = 277
+	tagRowsPerStrip              = 278
+	tagStripByteCounts           = 279
+
+	tiffShort = 3
+	tiffLong  = 4
+)
+
+// EncodeTIFF16 writes img to w as an uncompressed, single strip, 16-bit
+// grayscale little-endian TIFF.  Like the rest of golab, Pix is taken to hold
+// native (little-endian) uint16s, as the cameras produce them.
+func EncodeTIFF16(w io.Writer, img *image.Gray16) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width == 0 || height == 0 {
+		return errors.New("imgrec: cannot encode an empty image")
+	}
+	rowBytes := width * 2
+	nbytes := rowBytes * height
+
+	// layout: 8 byte header, pixel data, then the IFD
+	type entry struct {
+		tag, typ uint16
+		value    uint32
+	}
+	entries := []entry{
+		{tagImageWidth, tiffLong, uint32(width)},
+		{tagImageLength, tiffLong, uint32(height)},
+		{tagBitsPerSample, tiffShort, 16},
+		{tagCompression, tiffShort, 1},               // none
+		{tagPhotometricInterpretation, tiffShort, 1}, // black is zero
+		{tagStripOffsets, tiffLong, 8},
+		{tagSamplesPerPixel, tiffShort, 1},
+		{tagRowsPerStrip, tiffLong, uint32(height)},
+		{tagStripByteCounts, tiffLong, uint32(nbytes)},
+	}
+	ifdOffset := 8 + nbytes
+	if ifdOffset%2 == 1 { // IFD must begin on a word boundary
+		ifdOffset++
+	}
+
+	le := binary.LittleEndian
+	hdr := make([]byte, 8)
+	copy(hdr, "II")
+	le.PutUint16(hdr[2:], 42)
+	le.PutUint32(hdr[4:], uint32(ifdOffset))
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+
+	// write row by row in case the stride contains padding
+	for y := 0; y < height; y++ {
+		start := y * img.Stride
+		if _, err := w.Write(img.Pix[start : start+rowBytes]); err != nil {
+			return err
+		}
+	}
+	if ifdOffset != 8+nbytes {
+		if _, err := w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+
+	ifd := make([]byte, 2+12*len(entries)+4)
+	le.PutUint16(ifd, uint16(len(entries)))
+	for i, e := range entries {
+		off := 2 + 12*i
+		le.PutUint16(ifd[off:], e.tag)
+		le.PutUint16(ifd[off+2:], e.typ)
+		le.PutUint32(ifd[off+4:], 1) // count
+		if e.typ == tiffShort {
+			le.PutUint16(ifd[off+8:], uint16(e.value))
+		} else {
+			le.PutUint32(ifd[off+8:], e.value)
+		}
+	}
+	// next IFD offset of zero terminates the file; already zeroed
+	_, err := w.Write(ifd)
+	return err
+}
@@ -154,6 +154,29 @@ func (s *Scope) XIncrement() (float64, error) {
 	return s.ReadFloat(":WAVeform:XINCrement?")
 }
 
+// XOrigin gets the time of the first sample of the scope's data record
+// relative to the trigger
+func (s *Scope) XOrigin() (float64, error) {
+	return s.ReadFloat(":WAVeform:XORigin?")
+}
+
+// GetUnits returns the units of a channel, V or A
+func (s *Scope) GetUnits(channel string) (string, error) {
+	str := fmt.Sprintf(":CHANnel%s:UNITs?", channel)
+	resp, err := s.ReadString(str)
+	if err != nil {
+		return "", err
+	}
+	switch resp {
+	case "VOLT":
+		return "V", nil
+	case "AMP":
+		return "A", nil
+	default:
+		return resp, nil
+	}
+}
+
 // getBuffer transfers the data buffer from the scope handling all internal details
 func (s *Scope) getBuffer() ([]byte, error) {
 	var ret []byte
@@ -250,6 +273,10 @@ func (s *Scope) AcquireWaveform(channels []string) (oscilloscope.Waveform, error
 	if err != nil {
 		return ret, err
 	}
+	ret.TriggerOffset, err = s.XOrigin()
+	if err != nil {
+		return ret, err
+	}
 	unsigned, err := s.ReadBool(":WAVeform:UNSigned?")
 	if err != nil {
 		return ret, err
@@ -279,11 +306,16 @@ func (s *Scope) AcquireWaveform(channels []string) (oscilloscope.Waveform, error
 			return ret, err
 		}
 
+		units, err := s.GetUnits(channels[i])
+		if err != nil {
+			return ret, err
+		}
+
 		buf, err := s.getBuffer()
 		if err != nil {
 			return ret, err
 		}
-		ch := oscilloscope.Channel{Scale: yscale, Offset: yoff, Reference: yref}
+		ch := oscilloscope.Channel{Scale: yscale, Offset: yoff, Reference: yref, Units: units}
 		if unsigned {
 			var ary []uint16
 			hdr := (*reflect.SliceHeader)(unsafe.Pointer(&ary))
@@ -303,3 +335,14 @@ func (s *Scope) AcquireWaveform(channels []string) (oscilloscope.Waveform, error
 	}
 	return ret, nil
 }
+
+// AcquireWaveformDecimated is AcquireWaveform, with the data reduced by a
+// factor of n in physical units as by oscilloscope.Waveform.Decimate.  The
+// scope transfers the full waveform; only the result is smaller
+func (s *Scope) AcquireWaveformDecimated(channels []string, n int, envelope bool) (oscilloscope.Waveform, error) {
+	wav, err := s.AcquireWaveform(channels)
+	if err != nil {
+		return wav, err
+	}
+	return wav.Decimate(n, envelope)
+}
@@ -240,19 +240,21 @@ func makeSerConf(addr string) *serial.Config {
 
 // ESP301 represents an ESP301 motion controller.
 type ESP301 struct {
-	pool *comm.Pool
+	pool comm.Leaser
+
+	// Terminators frame each message to and from the controller
+	Terminators comm.Terminators
 }
 
 // NewESP301 makes a new ESP301 motion controller instance
 func NewESP301(addr string, connectSerial bool) *ESP301 {
-	var maker comm.CreationFunc
 	if connectSerial {
-		maker = comm.SerialConnMaker(makeSerConf(addr))
-	} else {
-		maker = comm.BackingOffTCPConnMaker(addr, 1*time.Second)
+		maker := comm.SerialConnMaker(makeSerConf(addr))
+		return &ESP301{pool: comm.NewSerialConn(maker, comm.DefaultTimeout), Terminators: comm.CR}
 	}
+	maker := comm.BackingOffTCPConnMaker(addr, 1*time.Second)
 	p := comm.NewPool(1, time.Minute, maker)
-	return &ESP301{pool: p}
+	return &ESP301{pool: p, Terminators: comm.CR}
 }
 
 // RawCommand sends a command directly to the motion controller (with EOT appended) and returns the response as-is
@@ -263,7 +265,7 @@ func (esp *ESP301) RawCommand(cmd string) (string, error) {
 		return "", err
 	}
 	defer func() { esp.pool.ReturnWithError(conn, err) }()
-	wrapper := comm.NewTerminator(conn, CarriageReturn, CarriageReturn)
+	wrapper := esp.Terminators.Wrap(conn)
 
 	// acquire an almost imperceptible amount of parallel performance here
 	// the message will be in flight or processed by the ESP while we
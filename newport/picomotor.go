@@ -58,33 +58,30 @@ func intToPicomotorError(i int) error {
 
 // Picomotor represents an picomotor controller.
 type Picomotor struct {
-	pool *comm.Pool
+	pool comm.Leaser
 
 	// Handshaking controls if commands check for errors.  Higher throughput can
 	// be achieved without error checking in exchange for reduced safety
 	Handshaking bool
 
-	// serial indicates whether this driver uses a serial connection or ethernet.
-	// unlike most devices where this only matters to the connection logic,
-	// newport's love of suffering means that the terminators are different on
-	// ethernet and serial.  Particularly,
+	// Terminators frame each message to and from the controller.
+	// Unlike most devices where the connection type only matters to the
+	// connection logic, newport's love of suffering means that the terminators
+	// are different on ethernet and serial.  Particularly,
 	// serial: terminator = \r
 	// ethernet: terminator = \n
 	// bonus pain: ethernet replies are \r\n
-	serial bool
+	Terminators comm.Terminators
 }
 
 // NewESP301 makes a new ESP301 motion controller instance
 func NewPicomotor(addr string, connectSerial bool) *Picomotor {
-	p := comm.NewPool(1, time.Minute, newConnectionFactory(addr, connectSerial))
-	return &Picomotor{pool: p, Handshaking: true}
-}
-
-func (p *Picomotor) terminator() byte {
-	if p.serial {
-		return CarriageReturn
+	maker := newConnectionFactory(addr, connectSerial)
+	if connectSerial {
+		return &Picomotor{pool: comm.NewSerialConn(maker, comm.DefaultTimeout), Handshaking: true, Terminators: comm.CR}
 	}
-	return Newline
+	p := comm.NewPool(1, time.Minute, maker)
+	return &Picomotor{pool: p, Handshaking: true, Terminators: comm.LF}
 }
 
 func (p *Picomotor) writeOnlyCommand(cmd string) error {
@@ -93,8 +90,7 @@ func (p *Picomotor) writeOnlyCommand(cmd string) error {
 		return err
 	}
 	defer func() { p.pool.ReturnWithError(conn, err) }()
-	term := p.terminator()
-	wrap := comm.NewTerminator(conn, term, term)
+	wrap := p.Terminators.Wrap(conn)
 	_, err = io.WriteString(wrap, cmd)
 	if err != nil {
 		return err
@@ -137,8 +133,7 @@ func (p *Picomotor) writeReadCommand(cmd string) (string, error) {
 		return "", err
 	}
 	defer func() { p.pool.ReturnWithError(conn, err) }()
-	term := p.terminator()
-	wrap := comm.NewTerminator(conn, term, term)
+	wrap := p.Terminators.Wrap(conn)
 	if p.Handshaking {
 		cmd = cmd + ";TE?"
 	}
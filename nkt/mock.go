@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/nasa-jpl/golaborate/generichttp/laser"
+	"github.com/nasa-jpl/golaborate/util"
 )
 
 type MockSuperK struct {
@@ -16,6 +17,7 @@ type MockSuperK struct {
 	power           uint16
 	emission        bool
 	cancel          chan struct{}
+	ramp            util.Ramper
 }
 
 func NewMockSuperK(addr string, connectSerial bool) *MockSuperK {
@@ -69,6 +71,14 @@ func (m *MockSuperK) GetEmission() (bool, error) {
 	return m.emission, nil
 }
 
+func (m *MockSuperK) ResetInterlock() error {
+	return nil
+}
+
+func (m *MockSuperK) EnableEmissionSafe() error {
+	return m.SetEmission(true)
+}
+
 func (m *MockSuperK) SetPower(p float64) error {
 	m.Lock()
 	defer m.Unlock()
@@ -85,6 +95,30 @@ func (m *MockSuperK) GetPower() (float64, error) {
 	return float64(m.power / 10), nil
 }
 
+func (m *MockSuperK) RampPowerLevel(target, ratePerSec float64) error {
+	target = util.Clamp(target, 0, 100)
+	start, err := m.GetPower()
+	if err != nil {
+		return err
+	}
+	return m.ramp.Ramp(start, target, ratePerSec, RampInterval, m.SetPower)
+}
+
+func (m *MockSuperK) StopRamp() error {
+	m.ramp.Stop()
+	return nil
+}
+
+// RampStatus returns the status of the most recent ramp
+func (m *MockSuperK) RampStatus() util.RampStatus {
+	return m.ramp.Status()
+}
+
+func (m *MockSuperK) Close() error {
+	m.ramp.Stop()
+	return nil
+}
+
 func (m *MockSuperK) SetShortWave(nanometers float64) error {
 	m.Lock()
 	defer m.Unlock()
@@ -155,6 +189,17 @@ func (m *MockSuperK) StatusMain() (map[string]bool, error) {
 	}, nil
 }
 
+func (m *MockSuperK) GetStatusFlags() (SuperKStatus, error) {
+	em, err := m.GetEmission()
+	if err != nil {
+		return 0, err
+	}
+	if em {
+		return SuperKStatus(1), nil
+	}
+	return SuperKStatus(0), nil
+}
+
 func (m *MockSuperK) StatusVaria() (map[string]bool, error) {
 	return map[string]bool{
 		"Interlock off":      false,
@@ -169,3 +214,15 @@ func (m *MockSuperK) StatusVaria() (map[string]bool, error) {
 		"Error code present": false,
 	}, nil
 }
+
+func (m *MockSuperK) GetModel() (string, error) {
+	return ModuleTypeMap[0x60], nil
+}
+
+func (m *MockSuperK) GetSerialNumber() (string, error) {
+	return "MOCK0001", nil
+}
+
+func (m *MockSuperK) GetFirmwareVersion() (string, error) {
+	return "0", nil
+}
@@ -7,9 +7,13 @@ package nkt
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/nasa-jpl/golaborate/comm"
@@ -81,7 +85,11 @@ type Module struct {
 	// Info contains mapping data for a given module, see ModuleInformation for more docs.
 	Info *ModuleInformation
 
-	pool *comm.Pool // pointer so that pool is shared between modules
+	// pool is shared between the modules of a laser.  NKT modules share one
+	// bus, so it must give out a single connection, as comm.SerialConn
+	// does, for a request and its reply not to be interleaved with those of
+	// another module
+	pool comm.Leaser
 }
 
 func (m *Module) getRegister(addrName string) (byte, error) {
@@ -97,7 +105,15 @@ func (m *Module) getRegister(addrName string) (byte, error) {
 }
 
 // SendRecvMP sends a buffer after appending the Tx terminator,
-// then returns the response with the Rx terminator stripped
+// then returns the response with the Rx terminator stripped.
+//
+// The whole exchange, including retries, is made while the connection shared
+// by the modules of the laser is leased, and the reply is checked against the
+// request.
+// Failures are reported as ErrCRCMismatch, ErrRemoteCRCMismatch, ErrFraming,
+// ErrMismatchedReply, or ErrTimeout where they can be told apart.  A CRC
+// failure in either direction is retransmitted once, since the line is noisy
+// over serial bridges
 func (m *Module) SendRecvMP(mp MessagePrimitive) (MessagePrimitive, error) {
 	var ret MessagePrimitive
 	// set up the connection to the device
@@ -106,32 +122,77 @@ func (m *Module) SendRecvMP(mp MessagePrimitive) (MessagePrimitive, error) {
 		return ret, err
 	}
 	defer func() { m.pool.ReturnWithError(conn, err) }()
-
-	send, err := mp.EncodeTelegram()
-	if err != nil {
-		return ret, err
+	var rw io.ReadWriter = conn
+	if t, terr := comm.NewTimeout(conn, comm.DefaultTimeout); terr == nil {
+		rw = t
 	}
 
-	// try to send the message up to 3 times.  transient CRC errors are pretty common with the NKTs
-	for idx := 0; idx < 5; idx++ {
-		_, err = conn.Write(send)
+	for attempt := 0; attempt < 2; attempt++ {
+		var send []byte
+		send, err = mp.EncodeTelegram()
+		if err != nil {
+			return ret, err
+		}
+		_, err = rw.Write(send)
 		if err != nil {
+			err = timeoutError(err)
 			return ret, err
 		}
 		buf := make([]byte, 64) // messages are typically close to 10 bytes, 64 is plenty
-		n, err := conn.Read(buf)
+		var n int
+		n, err = rw.Read(buf)
+		if n == 0 && (err == nil || err == io.EOF) {
+			// serial ports return nothing when their read timeout elapses
+			err = ErrTimeout
+		}
 		if err != nil {
+			err = timeoutError(err)
 			return ret, err
 		}
 		ret, err = DecodeTelegram(buf[:n])
 		if err == nil {
+			if ret.Dest == mp.Src && ret.Src == mp.Dest && ret.Register == mp.Register {
+				return ret, nil
+			}
+			err = fmt.Errorf("%w: sent to module 0x%02X register 0x%02X from 0x%02X, reply was from 0x%02X register 0x%02X to 0x%02X",
+				ErrMismatchedReply, mp.Dest, mp.Register, mp.Src, ret.Src, ret.Register, ret.Dest)
+		}
+		if !errors.Is(err, ErrCRCMismatch) && !errors.Is(err, ErrRemoteCRCMismatch) {
 			break
 		}
 		mp.Src = getSourceAddr()
 	}
+	// err is not nil, so the connection is closed and re-opened by the next
+	// exchange, discarding any stale or garbled bytes still in flight
 	return ret, err
 }
 
+// timeoutError wraps err with ErrTimeout if it is a network timeout
+func timeoutError(err error) error {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	}
+	return err
+}
+
+// errorCode maps an error from the laser to an HTTP status code.  Problems on
+// the link to the laser are reported as bad gateway, or gateway timeout if it
+// did not reply, to tell them apart from errors reported by the laser itself
+func errorCode(err error) int {
+	switch {
+	case errors.Is(err, ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrCRCMismatch),
+		errors.Is(err, ErrRemoteCRCMismatch),
+		errors.Is(err, ErrFraming),
+		errors.Is(err, ErrMismatchedReply):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // GetValue reads a register
 func (m *Module) GetValue(addrName string) (MessagePrimitive, error) {
 	var (
@@ -235,6 +296,44 @@ func (m *Module) GetStatus() (map[string]bool, error) {
 	return resp, nil
 }
 
+// GetModel returns the module type as a human-readable string
+func (m *Module) GetModel() (string, error) {
+	resp, err := m.GetValue("TypeCode")
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Data) == 0 {
+		return "", errors.New("empty response from NKT to type code query")
+	}
+	if name, ok := ModuleTypeMap[resp.Data[0]]; ok {
+		return name, nil
+	}
+	return fmt.Sprintf("unknown (0x%02X)", resp.Data[0]), nil
+}
+
+// GetSerialNumber returns the serial number of the module
+func (m *Module) GetSerialNumber() (string, error) {
+	resp, err := m.GetValue("Serial")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(resp.Data), "\x00 "), nil
+}
+
+// GetFirmwareVersion returns the firmware version of the module.
+// Modules which report a 16-bit version code have it formatted as an integer,
+// others report a string
+func (m *Module) GetFirmwareVersion() (string, error) {
+	resp, err := m.GetValue("Firmware Version")
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Data) == 2 {
+		return strconv.Itoa(int(dataOrder.Uint16(resp.Data))), nil
+	}
+	return strings.TrimRight(string(resp.Data), "\x00 "), nil
+}
+
 // SuperK is a struct holding all of the usual modules
 type SuperK struct {
 	*SuperKExtreme
@@ -244,23 +343,35 @@ type SuperK struct {
 
 // NewSuperK returns a new laser with pre-configured varia and extreme modules
 func NewSuperK(addr string, connectSerial bool) *SuperK {
-	var maker comm.CreationFunc
-	// make the maker func
+	var pool comm.Leaser
 	if connectSerial {
-		maker = func() (io.ReadWriteCloser, error) {
+		maker := func() (io.ReadWriteCloser, error) {
 			conf := makeSerConf(addr)
 			return serial.OpenPort(&conf)
 		}
+		pool = comm.NewSerialConn(maker, comm.DefaultTimeout)
 	} else {
-		maker = comm.BackingOffTCPConnMaker(addr, 3*time.Second)
+		pool = comm.NewTCPConn(addr, 3*time.Second, comm.DefaultTimeout)
 	}
-	pool := comm.NewPool(1, 30*time.Second, maker)
 	extreme := NewSuperKExtreme(addr, pool)
 	varia := NewSuperKVaria(addr, pool)
 	booster := NewSuperKBooster(addr, pool)
 	return &SuperK{SuperKExtreme: extreme, SuperKVaria: varia, SuperKBooster: booster}
 }
 
+// Close stops any power level ramp in progress and closes the connection to
+// the laser.  Emission is left as it is
+func (sk *SuperK) Close() error {
+	sk.SuperKExtreme.StopRamp()
+	switch p := sk.SuperKExtreme.pool.(type) {
+	case *comm.Pool:
+		p.Close()
+	case io.Closer:
+		return p.Close()
+	}
+	return nil
+}
+
 // StatusMain retrieves the main module status
 func (sk *SuperK) StatusMain() (map[string]bool, error) {
 	return sk.SuperKExtreme.GetStatus()
@@ -271,11 +382,65 @@ func (sk *SuperK) StatusVaria() (map[string]bool, error) {
 	return sk.SuperKVaria.GetStatus()
 }
 
+// GetModel returns the model of the main module
+func (sk *SuperK) GetModel() (string, error) {
+	return sk.SuperKExtreme.GetModel()
+}
+
+// GetSerialNumber returns the serial number of the main module
+func (sk *SuperK) GetSerialNumber() (string, error) {
+	return sk.SuperKExtreme.GetSerialNumber()
+}
+
+// GetFirmwareVersion returns the firmware version of the main module
+func (sk *SuperK) GetFirmwareVersion() (string, error) {
+	return sk.SuperKExtreme.GetFirmwareVersion()
+}
+
+// EnableEmissionSafe turns emission on after verifying the interlock is OK
+// and neither the main module nor the Varia report a fault
+func (sk *SuperK) EnableEmissionSafe() error {
+	main, err := sk.StatusMain()
+	if err != nil {
+		return err
+	}
+	varia, err := sk.StatusVaria()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, k := range []string{
+		"Interlock relays off",
+		"Interlock supply voltage low (possible short circuit)",
+		"Interlock loop open",
+		"Supply voltage low",
+		"System error code present"} {
+		if main[k] {
+			errs = append(errs, fmt.Errorf("main module: %s", k))
+		}
+	}
+	for _, k := range []string{
+		"Interlock off",
+		"Supply voltage low",
+		"Filter 1 moving",
+		"Filter 2 moving",
+		"Filter 3 moving",
+		"Error code present"} {
+		if varia[k] {
+			errs = append(errs, fmt.Errorf("varia: %s", k))
+		}
+	}
+	if err = util.MergeErrors(errs); err != nil {
+		return fmt.Errorf("emission not enabled:\n%w", err)
+	}
+	return sk.SetEmission(true)
+}
+
 func encodeStatus(fcn func() (map[string]bool, error)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		status, err := fcn()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), errorCode(err))
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
@@ -289,6 +454,74 @@ func encodeStatus(fcn func() (map[string]bool, error)) http.HandlerFunc {
 	}
 }
 
+func doAction(fcn func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := fcn()
+		if err != nil {
+			http.Error(w, err.Error(), errorCode(err))
+			return
+		}
+	}
+}
+
+// InterlockResetter can reset its interlock after a trip
+type InterlockResetter interface {
+	ResetInterlock() error
+}
+
+// SafeEmitter can verify it is in a safe state before enabling emission
+type SafeEmitter interface {
+	EnableEmissionSafe() error
+}
+
+// PowerRamper can smoothly ramp its power level to a new value
+type PowerRamper interface {
+	RampPowerLevel(target, ratePerSec float64) error
+	StopRamp() error
+}
+
+func rampPower(p PowerRamper) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ramp := laser.Ramp{}
+		err := json.NewDecoder(r.Body).Decode(&ramp)
+		defer r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = p.RampPowerLevel(ramp.Target, ramp.Rate)
+		if err != nil {
+			http.Error(w, err.Error(), errorCode(err))
+			return
+		}
+		if m, ok := p.(laser.RampMonitor); ok {
+			w.Header().Set("X-Job-Id", laser.TrackRamp("ramp", m).ID())
+		}
+	}
+}
+
+// StatusFlagger can report its status register as named flags
+type StatusFlagger interface {
+	GetStatusFlags() (SuperKStatus, error)
+}
+
+func encodeStatusFlags(s StatusFlagger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flags, err := s.GetStatusFlags()
+		if err != nil {
+			http.Error(w, err.Error(), errorCode(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(flags)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// AugmentedLaserController is a laser controller with main and Varia module statuses
 type AugmentedLaserController interface {
 	laser.Controller
 	StatusMain() (map[string]bool, error)
@@ -301,5 +534,18 @@ func NewHTTPWrapper(sk AugmentedLaserController) laser.HTTPLaserController {
 	rt := w.RT()
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/main-module-status"}] = encodeStatus(sk.StatusMain)
 	rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/varia-status"}] = encodeStatus(sk.StatusVaria)
+	if ir, ok := sk.(InterlockResetter); ok {
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/interlock/reset"}] = doAction(ir.ResetInterlock)
+	}
+	if se, ok := sk.(SafeEmitter); ok {
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/emission/safe"}] = doAction(se.EnableEmissionSafe)
+	}
+	if sf, ok := sk.(StatusFlagger); ok {
+		rt[generichttp.MethodPath{Method: http.MethodGet, Path: "/status-flags"}] = encodeStatusFlags(sf)
+	}
+	if pr, ok := sk.(PowerRamper); ok {
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/power/ramp"}] = rampPower(pr)
+		rt[generichttp.MethodPath{Method: http.MethodPost, Path: "/power/ramp/stop"}] = doAction(pr.StopRamp)
+	}
 	return w
 }
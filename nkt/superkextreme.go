@@ -1,7 +1,12 @@
 package nkt
 
 import (
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/nasa-jpl/golaborate/comm"
+	"github.com/nasa-jpl/golaborate/util"
 )
 
 // this file contains values relevant to the SuperK Extreme modules
@@ -10,6 +15,9 @@ const (
 	extremeDefaultAddr        = 0x0F
 	extremeFrontDefaultAddr   = 0x01
 	extremeBoosterDefaultAddr = 0x65
+
+	// RampInterval is the time between steps of a power level ramp
+	RampInterval = 100 * time.Millisecond
 )
 
 var (
@@ -79,14 +87,85 @@ var (
 			}}}
 )
 
+// SuperKStatus is the SuperK Extreme main module status bitfield
+type SuperKStatus uint16
+
+// EmissionOn returns true if emission is on (bit 0)
+func (s SuperKStatus) EmissionOn() bool { return (s>>0)&1 == 1 }
+
+// InterlockRelaysOff returns true if the interlock relays are off (bit 1)
+func (s SuperKStatus) InterlockRelaysOff() bool { return (s>>1)&1 == 1 }
+
+// InterlockSupplyLow returns true if the interlock supply voltage is low,
+// possibly from a short circuit (bit 2)
+func (s SuperKStatus) InterlockSupplyLow() bool { return (s>>2)&1 == 1 }
+
+// InterlockLoopOpen returns true if the interlock loop is open (bit 3)
+func (s SuperKStatus) InterlockLoopOpen() bool { return (s>>3)&1 == 1 }
+
+// OutputControlLow returns true if the output control signal is low (bit 4)
+func (s SuperKStatus) OutputControlLow() bool { return (s>>4)&1 == 1 }
+
+// SupplyVoltageLow returns true if the supply voltage is low (bit 5)
+func (s SuperKStatus) SupplyVoltageLow() bool { return (s>>5)&1 == 1 }
+
+// InletTempOutOfRange returns true if the inlet temperature is out of range (bit 6)
+func (s SuperKStatus) InletTempOutOfRange() bool { return (s>>6)&1 == 1 }
+
+// ClockBatteryLow returns true if the clock battery voltage is low (bit 7)
+func (s SuperKStatus) ClockBatteryLow() bool { return (s>>7)&1 == 1 }
+
+// CRCErrorOnStartup returns true if there was a CRC error on startup,
+// possibly from a module address conflict (bit 13)
+func (s SuperKStatus) CRCErrorOnStartup() bool { return (s>>13)&1 == 1 }
+
+// LogErrorPresent returns true if a log error code is present (bit 14)
+func (s SuperKStatus) LogErrorPresent() bool { return (s>>14)&1 == 1 }
+
+// SystemErrorPresent returns true if a system error code is present (bit 15)
+func (s SuperKStatus) SystemErrorPresent() bool { return (s>>15)&1 == 1 }
+
+// InterlockTripped returns true if any of the interlock bits are set
+func (s SuperKStatus) InterlockTripped() bool {
+	return s.InterlockRelaysOff() || s.InterlockSupplyLow() || s.InterlockLoopOpen()
+}
+
+// Overheat returns true if the inlet temperature is out of range
+func (s SuperKStatus) Overheat() bool {
+	return s.InletTempOutOfRange()
+}
+
+// MarshalJSON encodes the status as an object of named flags
+func (s SuperKStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Raw         uint16 `json:"raw"`
+		Emission    bool   `json:"emission"`
+		Interlock   bool   `json:"interlock"`
+		Overheat    bool   `json:"overheat"`
+		SystemError bool   `json:"systemError"`
+		SupplyLow   bool   `json:"supplyLow"`
+		LogError    bool   `json:"logError"`
+	}{
+		Raw:         uint16(s),
+		Emission:    s.EmissionOn(),
+		Interlock:   s.InterlockTripped(),
+		Overheat:    s.Overheat(),
+		SystemError: s.SystemErrorPresent(),
+		SupplyLow:   s.SupplyVoltageLow(),
+		LogError:    s.LogErrorPresent(),
+	})
+}
+
 // SuperKExtreme embeds Module and has some quick usage methods
 type SuperKExtreme struct {
 	Module
+
+	ramp util.Ramper
 }
 
 // NewSuperKExtreme create a new Module representing a SuperKExtreme's main module
-func NewSuperKExtreme(addr string, pool *comm.Pool) *SuperKExtreme {
-	return &SuperKExtreme{Module{
+func NewSuperKExtreme(addr string, pool comm.Leaser) *SuperKExtreme {
+	return &SuperKExtreme{Module: Module{
 		pool:    pool,
 		AddrDev: extremeDefaultAddr,
 		Info:    SuperKExtremeMainInfo}}
@@ -111,6 +190,27 @@ func (sk *SuperKExtreme) GetEmission() (bool, error) {
 	return resp.Data[0] > 0, nil
 }
 
+// ResetInterlock resets the interlock after it has been tripped.  The
+// interlock loop must be closed for the reset to take effect.
+func (sk *SuperKExtreme) ResetInterlock() error {
+	buf := make([]byte, 2)
+	dataOrder.PutUint16(buf, 1)
+	_, err := sk.SetValue("Interlock", buf)
+	return err
+}
+
+// GetStatusFlags retrieves the status register of the main module
+func (sk *SuperKExtreme) GetStatusFlags() (SuperKStatus, error) {
+	resp, err := sk.GetValue("Status")
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Data) < 2 {
+		return 0, fmt.Errorf("status response from NKT was %d bytes, expected 2", len(resp.Data))
+	}
+	return SuperKStatus(dataOrder.Uint16(resp.Data)), nil
+}
+
 // SetPower sets the output power level (0-100) of the laser
 func (sk *SuperKExtreme) SetPower(level float64) error {
 	return sk.SetFloat("Power Level", level)
@@ -121,13 +221,36 @@ func (sk *SuperKExtreme) GetPower() (float64, error) {
 	return sk.GetFloat("Power Level")
 }
 
+// RampPowerLevel ramps the power level from its present value to target (0-100)
+// at ratePerSec (percent per second).  The target is clamped to [0,100].
+// The ramp runs in the background; a new ramp or StopRamp aborts it.
+func (sk *SuperKExtreme) RampPowerLevel(target, ratePerSec float64) error {
+	target = util.Clamp(target, 0, 100)
+	start, err := sk.GetPower()
+	if err != nil {
+		return err
+	}
+	return sk.ramp.Ramp(start, target, ratePerSec, RampInterval, sk.SetPower)
+}
+
+// StopRamp aborts the power level ramp in progress, if any
+func (sk *SuperKExtreme) StopRamp() error {
+	sk.ramp.Stop()
+	return nil
+}
+
+// RampStatus returns the status of the most recent ramp
+func (sk *SuperKExtreme) RampStatus() util.RampStatus {
+	return sk.ramp.Status()
+}
+
 // SuperKBooster embeds Module and has an EmissionRuntime method
 type SuperKBooster struct {
 	Module
 }
 
 // NewSuperKBooster creates a new Module representing a SuperK booster (where the laser gain medium lives)
-func NewSuperKBooster(addr string, pool *comm.Pool) *SuperKBooster {
+func NewSuperKBooster(addr string, pool comm.Leaser) *SuperKBooster {
 	return &SuperKBooster{Module{
 		pool:    pool,
 		AddrDev: extremeDefaultAddr,
@@ -70,7 +70,7 @@ type SuperKVaria struct {
 }
 
 // NewSuperKVaria create a new Module representing a SuperKVaria module
-func NewSuperKVaria(addr string, pool *comm.Pool) *SuperKVaria {
+func NewSuperKVaria(addr string, pool comm.Leaser) *SuperKVaria {
 	return &SuperKVaria{Module{
 		pool:    pool,
 		AddrDev: variaDefaultAddr,
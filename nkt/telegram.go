@@ -34,6 +34,21 @@ var (
 	// ErrRemoteCRCMismatch is generated when the NKT responds with the CRC mismatch telegram type code
 	ErrRemoteCRCMismatch = errors.New("CRC mismatch at NKT")
 
+	// ErrCRCMismatch is generated when a reply from the NKT fails its CRC,
+	// usually because of noise on the line
+	ErrCRCMismatch = errors.New("CRC mismatch in reply from NKT, data was corrupted in transmission")
+
+	// ErrFraming is generated when a reply is not a complete telegram
+	ErrFraming = errors.New("reply from NKT is not a complete telegram")
+
+	// ErrMismatchedReply is generated when the reply to a telegram is not from
+	// the module and register it was sent to, or is addressed to a different
+	// request
+	ErrMismatchedReply = errors.New("reply from NKT came from an unexpected address")
+
+	// ErrTimeout is generated when the NKT does not reply in time
+	ErrTimeout = errors.New("NKT did not reply before the timeout")
+
 	// dataOrder is the byte order
 	dataOrder = binary.LittleEndian
 
@@ -175,26 +190,28 @@ func (mp MessagePrimitive) EncodeTelegram() ([]byte, error) {
 // DecodeTelegram renders a raw byte stream into a MessagePrimitive
 func DecodeTelegram(tele []byte) (MessagePrimitive, error) {
 	// first make sure that we have a start and an end
-	if !bytes.Contains(tele, []byte{telStart}) {
-		fstr := fmt.Sprintf("telegram start byte %X not found", telStart)
-		return MessagePrimitive{}, errors.New(fstr)
-		// } else if !bytes.Contains(tele, []byte{telEnd}) {
-		// fstr := fmt.Sprintf("telegram end byte %X not found", telEnd)
-		// return MessagePrimitive{}, errors.New(fstr)
+	start := bytes.IndexByte(tele, telStart)
+	if start == -1 {
+		return MessagePrimitive{}, fmt.Errorf("%w: start byte %X not found", ErrFraming, telStart)
 	}
-	if !bytes.Contains(tele, []byte{telEnd}) {
-		fstr := fmt.Sprintf("telegram end byte %X not found", telEnd)
-		return MessagePrimitive{}, errors.New(fstr)
+	end := bytes.IndexByte(tele, telEnd)
+	if end == -1 {
+		return MessagePrimitive{}, fmt.Errorf("%w: end byte %X not found", ErrFraming, telEnd)
+	}
+	if end < start {
+		return MessagePrimitive{}, fmt.Errorf("%w: end byte %X precedes start byte %X", ErrFraming, telEnd, telStart)
 	}
 	// remove SOT/EOT
-	start := bytes.IndexByte(tele, telStart)
-	end := bytes.IndexByte(tele, telEnd)
-	// iEnd := bytes.IndexByte(tele, telEnd)
 	tele = tele[start+1 : end]
 
 	// now desanitize the message
 	tele = reverseSanitize(tele)
 
+	// [DEST] [SOURCE] [TYPE] [REGISTER] and two CRC bytes at a minimum
+	if len(tele) < 6 {
+		return MessagePrimitive{}, fmt.Errorf("%w: %d bytes between start and end, need at least 6", ErrFraming, len(tele))
+	}
+
 	// pop the CRC bytes
 	fidx := len(tele) - 2
 	crcBytesRecv := tele[fidx:]
@@ -203,7 +220,7 @@ func DecodeTelegram(tele []byte) (MessagePrimitive, error) {
 	// compute the CRC and ensure we match
 	crcBytesCompute := crcHelper(tele)
 	if !bytes.Equal(crcBytesRecv, crcBytesCompute) {
-		return MessagePrimitive{}, errors.New("CRC mismatch, significant data lost in transmission.  NKT device state unknown")
+		return MessagePrimitive{}, ErrCRCMismatch
 	}
 
 	// we have passed all the checks;
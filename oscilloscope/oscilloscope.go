@@ -4,6 +4,7 @@ package oscilloscope
 import (
 	"bufio"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strconv"
@@ -11,11 +12,16 @@ import (
 	"time"
 )
 
-// Waveform describes a waveform recording from a scope
+// Waveform describes a waveform recording from a scope.  The time of sample i
+// relative to the trigger is TriggerOffset + i*DT
 type Waveform struct {
-	// DT is the temporal sample spacing in seconds
+	// DT is the temporal sample spacing (the sample interval) in seconds
 	DT float64 `json:"dt"`
 
+	// TriggerOffset is the time of the first sample relative to the trigger,
+	// in seconds.  It is negative when there are pre-trigger samples
+	TriggerOffset float64 `json:"triggerOffset"`
+
 	// Channels holds named data streams
 	Channels map[string]Channel
 }
@@ -35,6 +41,10 @@ type Channel struct {
 
 	// Reference is the reference value for the given channel in DN
 	Reference float64
+
+	// Units are the physical units of the data after scaling, e.g. V.
+	// Empty if unknown
+	Units string
 }
 
 // Physical computes the data scaled to real units
@@ -121,48 +131,239 @@ func (c Channel) Physical() []float64 {
 // numerical type
 type Data interface{}
 
+// csvChunk is the number of rows of a waveform converted and written at once
+// by EncodeCSV
+const csvChunk = 4096
+
+// flusher is implemented by writers which buffer, such as http.ResponseWriter
+type flusher interface {
+	Flush()
+}
+
+// Len returns the number of samples in the channel.  A channel with no data
+// has zero length
+func (c Channel) Len() int {
+	switch v := c.Data.(type) {
+	case []uint8:
+		return len(v)
+	case []uint16:
+		return len(v)
+	case []uint32:
+		return len(v)
+	case []uint64:
+		return len(v)
+	case []int8:
+		return len(v)
+	case []int16:
+		return len(v)
+	case []int32:
+		return len(v)
+	case []int64:
+		return len(v)
+	case []float32:
+		return len(v)
+	case []float64:
+		return len(v)
+	case nil:
+		return 0
+	default:
+		panic("attempt to take the length of non numerical data")
+	}
+}
+
+// slice returns the channel with its data limited to samples [start, end)
+func (c Channel) slice(start, end int) Channel {
+	switch v := c.Data.(type) {
+	case []uint8:
+		c.Data = v[start:end]
+	case []uint16:
+		c.Data = v[start:end]
+	case []uint32:
+		c.Data = v[start:end]
+	case []uint64:
+		c.Data = v[start:end]
+	case []int8:
+		c.Data = v[start:end]
+	case []int16:
+		c.Data = v[start:end]
+	case []int32:
+		c.Data = v[start:end]
+	case []int64:
+		c.Data = v[start:end]
+	case []float32:
+		c.Data = v[start:end]
+	case []float64:
+		c.Data = v[start:end]
+	default:
+		panic("attempt to slice non numerical data")
+	}
+	return c
+}
+
 // EncodeCSV converts the waveform data to physical units
-// and writes it to a CSV in streaming fashion
+// and writes it to a CSV in streaming fashion.
+//
+// The CSV is preceded by comment lines beginning with # which give the sample
+// interval, trigger offset, and the units of each column.  The time column is
+// i*DT and does not include the trigger offset.
+//
+// The data is converted and written csvChunk rows at a time, so memory use
+// does not grow with the length of the waveform.  If w is an http.Flusher (or
+// any other writer with a Flush method) it is flushed after each chunk so the
+// client receives data as it is encoded.
 func (wav *Waveform) EncodeCSV(w io.Writer) error {
-	// first, assemble the floating point data and timestamps
-	// so we have definite length to work with
-	labels := make([]string, len(wav.Channels))
-	i := 0
+	labels := make([]string, 0, len(wav.Channels))
 	for k := range wav.Channels {
-		labels[i] = k
-		i++
+		labels = append(labels, k)
+	}
+	if len(labels) == 0 {
+		return fmt.Errorf("waveform has no channels")
 	}
-	data := make([][]float64, i) // i is leaked from the loop above and can be reused
-	for j := 0; j < i; j++ {
-		data[j] = wav.Channels[labels[j]].Physical()
+	n := wav.Channels[labels[0]].Len()
+	for _, k := range labels[1:] {
+		if l := wav.Channels[k].Len(); l < n {
+			n = l
+		}
 	}
-	timestamps := make([]string, len(data[0]))
-	for i := 0; i < len(data[0]); i++ {
-		timestamps[i] = strconv.FormatFloat(float64(i)*wav.DT, 'G', -1, 64)
+	units := make([]string, len(labels)+1)
+	units[0] = "s"
+	for j, k := range labels {
+		units[j+1] = wav.Channels[k].Units
 	}
-	labels = append([]string{"time"}, labels...)
+	row := append([]string{"time"}, labels...)
 
-	// use a shitload of atomic writes through a buffer
-	w2 := bufio.NewWriter(w)
-	w3 := csv.NewWriter(w2)
-	writer := csv.NewWriter(bufio.NewWriter(w))
-	err := writer.Write(labels)
+	bw := bufio.NewWriter(w)
+	_, err := fmt.Fprintf(bw, "# sample interval: %s s\n# trigger offset: %s s\n# units: %s\n",
+		strconv.FormatFloat(wav.DT, 'G', -1, 64),
+		strconv.FormatFloat(wav.TriggerOffset, 'G', -1, 64),
+		strings.Join(units, ","))
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(bw)
+	err = writer.Write(row)
 	if err != nil {
 		return err
 	}
-	for i := 0; i < len(data[0]); i++ {
-		labels[0] = timestamps[i]
-		for j := 0; j < len(data); j++ {
-			labels[j+1] = strconv.FormatFloat(data[j][i], 'G', -1, 64)
+	data := make([][]float64, len(labels))
+	for start := 0; start < n; start += csvChunk {
+		end := start + csvChunk
+		if end > n {
+			end = n
 		}
-		err := w3.Write(labels)
-		if err != nil {
+		for j, k := range labels {
+			data[j] = wav.Channels[k].slice(start, end).Physical()
+		}
+		for i := start; i < end; i++ {
+			row[0] = strconv.FormatFloat(float64(i)*wav.DT, 'G', -1, 64)
+			for j := range data {
+				row[j+1] = strconv.FormatFloat(data[j][i-start], 'G', -1, 64)
+			}
+			err = writer.Write(row)
+			if err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		if err = writer.Error(); err != nil {
+			return err
+		}
+		if err = bw.Flush(); err != nil {
 			return err
 		}
+		if f, ok := w.(flusher); ok {
+			f.Flush()
+		}
+	}
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Decimate returns a copy of the waveform reduced by a factor of n, in
+// physical units.
+//
+// If envelope is false, every nth sample is kept.  If envelope is true, each
+// bin of n samples is reduced to its minimum and maximum, in the order they
+// occur, so that spikes narrower than a bin are not hidden; the sample
+// interval of the result is then n*DT/2.  A trailing partial bin is kept.
+func (wav *Waveform) Decimate(n int, envelope bool) (Waveform, error) {
+	if n < 1 {
+		return Waveform{}, fmt.Errorf("decimation factor must be at least 1, got %d", n)
+	}
+	if envelope && n < 2 {
+		return Waveform{}, fmt.Errorf("envelope decimation factor must be at least 2, got %d", n)
+	}
+	out := Waveform{
+		DT:            wav.DT * float64(n),
+		TriggerOffset: wav.TriggerOffset,
+		Channels:      make(map[string]Channel, len(wav.Channels)),
+	}
+	if envelope {
+		out.DT /= 2
+	}
+	for k, c := range wav.Channels {
+		data := c.Physical()
+		var dec []float64
+		if envelope {
+			dec = make([]float64, 0, 2*((len(data)+n-1)/n))
+			for start := 0; start < len(data); start += n {
+				end := start + n
+				if end > len(data) {
+					end = len(data)
+				}
+				imin, imax := start, start
+				for i := start + 1; i < end; i++ {
+					if data[i] < data[imin] {
+						imin = i
+					}
+					if data[i] > data[imax] {
+						imax = i
+					}
+				}
+				if imin <= imax {
+					dec = append(dec, data[imin], data[imax])
+				} else {
+					dec = append(dec, data[imax], data[imin])
+				}
+			}
+		} else {
+			dec = make([]float64, 0, (len(data)+n-1)/n)
+			for i := 0; i < len(data); i += n {
+				dec = append(dec, data[i])
+			}
+		}
+		out.Channels[k] = Channel{Data: dec, Scale: 1, Units: c.Units}
+	}
+	return out, nil
+}
+
+// waveformJSON is the JSON representation of a Waveform, in physical units
+type waveformJSON struct {
+	SampleInterval float64                `json:"sampleInterval"`
+	TriggerOffset  float64                `json:"triggerOffset"`
+	Channels       map[string]channelJSON `json:"channels"`
+}
+
+type channelJSON struct {
+	Units string    `json:"units"`
+	Data  []float64 `json:"data"`
+}
+
+// EncodeJSON converts the waveform data to physical units and writes it as
+// JSON, {"sampleInterval": s, "triggerOffset": s, "channels": {name: {"units": ..., "data": [...]}}}
+func (wav *Waveform) EncodeJSON(w io.Writer) error {
+	out := waveformJSON{
+		SampleInterval: wav.DT,
+		TriggerOffset:  wav.TriggerOffset,
+		Channels:       make(map[string]channelJSON, len(wav.Channels)),
+	}
+	for k, c := range wav.Channels {
+		out.Channels[k] = channelJSON{Units: c.Units, Data: c.Physical()}
 	}
-	w3.Flush()
-	w2.Flush()
-	return nil
+	return json.NewEncoder(w).Encode(out)
 }
 
 // Recording is a sequence of data from the DAQ
@@ -56,19 +56,20 @@ const (
 
 // ControllerNetwork is a network of daisy chained controllers
 type ControllerNetwork struct {
-	pool        *comm.Pool
+	pool        comm.Leaser
 	Controllers map[int]PIController
 }
 
 // NewNetwork creates a controller network with a shared pool
 func NewNetwork(addr string, serial bool) *ControllerNetwork {
-	var maker comm.CreationFunc
+	var pool comm.Leaser
 	if serial {
-		maker = comm.SerialConnMaker(makeSerConf(addr))
+		conf := makeSerConf(addr)
+		pool = comm.NewSerialConn(comm.SerialConnMaker(conf), conf.ReadTimeout)
 	} else {
-		maker = comm.BackingOffTCPConnMaker(addr, 3*time.Second)
+		maker := comm.BackingOffTCPConnMaker(addr, 3*time.Second)
+		pool = comm.NewPool(1, 30*time.Second, maker)
 	}
-	pool := comm.NewPool(1, 30*time.Second, maker)
 	return &ControllerNetwork{pool: pool, Controllers: map[int]PIController{}}
 }
 
@@ -97,11 +98,14 @@ type PIController interface {
 type Controller struct {
 	index int
 
-	pool *comm.Pool
+	pool comm.Leaser
 
 	// Timeout controls how long to wait for.
 	Timeout time.Duration
 
+	// Terminators frame each message to and from the controller
+	Terminators comm.Terminators
+
 	// Handshaking controls if commands check for errors.  Higher throughput can
 	// be achieved without error checking in exchange for reduced safety
 	Handshaking bool
@@ -120,12 +124,13 @@ type Controller struct {
 //
 // handshaking=true will check for errors after all commnads.  False does no error
 // checking.
-func NewController(pool *comm.Pool, index int, handshaking bool) *Controller {
+func NewController(pool comm.Leaser, index int, handshaking bool) *Controller {
 	return &Controller{
 		index:       index,
 		pool:        pool,
 		Handshaking: handshaking,
 		Timeout:     30 * time.Second,
+		Terminators: comm.LF,
 	}
 }
 
@@ -149,7 +154,7 @@ func (c *Controller) write(msgs ...string) error {
 	if err != nil {
 		return err
 	}
-	wrap = comm.NewTerminator(wrap, '\n', '\n')
+	wrap = c.Terminators.Wrap(wrap)
 
 	for i := range msgs {
 		msg := msgs[i]
@@ -205,7 +210,7 @@ func (c *Controller) query(msg string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	wrap = comm.NewTerminator(wrap, '\n', '\n')
+	wrap = c.Terminators.Wrap(wrap)
 
 	// part of a daisy chain, prepend controller ID and send query
 	if c.index > 0 {
@@ -0,0 +1,169 @@
+// Package poller periodically reads from a device and caches the result, so
+// that many clients may be served without each one hitting the device.
+//
+// This decouples the rate at which clients poll the server from the rate at
+// which the server polls the hardware, which matters for slow serial links
+// shared between several consumers.
+package poller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// ErrInterval is generated when a poller is made with an interval that is not
+// strictly positive
+var ErrInterval = errors.New("poll interval must be greater than zero")
+
+// ReadFunc reads a value from a device
+type ReadFunc func() (interface{}, error)
+
+// FromFloat adapts a float reading function to a ReadFunc
+func FromFloat(fcn func() (float64, error)) ReadFunc {
+	return func() (interface{}, error) {
+		return fcn()
+	}
+}
+
+// FromBool adapts a bool reading function to a ReadFunc
+func FromBool(fcn func() (bool, error)) ReadFunc {
+	return func() (interface{}, error) {
+		return fcn()
+	}
+}
+
+// Reading is a cached value along with information about its staleness
+type Reading struct {
+	// Value is the result of the last successful read
+	Value interface{} `json:"value"`
+
+	// Time is when the last successful read completed
+	Time time.Time `json:"time"`
+
+	// Age is the number of seconds since the last successful read
+	Age float64 `json:"age"`
+
+	// Err is the error from the most recent read, if it failed
+	Err string `json:"error,omitempty"`
+}
+
+// Poller calls a ReadFunc on an interval and caches the latest value.
+// It is safe for concurrent use.
+type Poller struct {
+	mu sync.RWMutex
+
+	read     ReadFunc
+	interval time.Duration
+
+	value   interface{}
+	lastOK  time.Time
+	lastErr error
+
+	stop chan struct{}
+}
+
+// New returns a new Poller which has not yet been started
+func New(read ReadFunc, interval time.Duration) (*Poller, error) {
+	if interval <= 0 {
+		return nil, ErrInterval
+	}
+	return &Poller{read: read, interval: interval}, nil
+}
+
+// Start begins polling in the background.  The first read happens immediately.
+// Calling Start on a running poller does nothing.
+func (p *Poller) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		return
+	}
+	p.stop = make(chan struct{})
+	go p.loop(p.stop)
+}
+
+// Stop halts polling.  The cached value remains available.
+func (p *Poller) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	p.stop = nil
+}
+
+// Close stops polling, so that a Poller may be shut down with the devices it
+// reads.  The error is always nil
+func (p *Poller) Close() error {
+	p.Stop()
+	return nil
+}
+
+func (p *Poller) loop(stop chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.poll()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll does a single read and updates the cache
+func (p *Poller) poll() {
+	v, err := p.read()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastErr = err
+	if err == nil {
+		p.value = v
+		p.lastOK = time.Now()
+	}
+}
+
+// Latest returns the most recent reading.  If no read has yet succeeded,
+// Value is nil and Time is the zero time.
+func (p *Poller) Latest() Reading {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	r := Reading{Value: p.value, Time: p.lastOK}
+	if !p.lastOK.IsZero() {
+		r.Age = time.Since(p.lastOK).Seconds()
+	}
+	if p.lastErr != nil {
+		r.Err = p.lastErr.Error()
+	}
+	return r
+}
+
+// Handler returns an HTTP handler which responds with the latest Reading as
+// JSON.  If no read has ever succeeded, the status is 503 Service Unavailable.
+func (p *Poller) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reading := p.Latest()
+		w.Header().Set("Content-Type", "application/json")
+		if reading.Time.IsZero() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		err := json.NewEncoder(w).Encode(reading)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// Inject adds a GET route at path to the table serving the cached reading
+func (p *Poller) Inject(table generichttp.RouteTable, path string) {
+	table[generichttp.MethodPath{Method: http.MethodGet, Path: path}] = p.Handler()
+}
@@ -0,0 +1,129 @@
+package poller_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/poller"
+)
+
+// counter is a device whose reading is the number of times it was read
+type counter struct {
+	mu  sync.Mutex
+	n   float64
+	err error
+}
+
+func (c *counter) read() (float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return 0, c.err
+	}
+	c.n++
+	return c.n, nil
+}
+
+func (c *counter) fail(err error) {
+	c.mu.Lock()
+	c.err = err
+	c.mu.Unlock()
+}
+
+func waitFor(t *testing.T, p *poller.Poller, ok func(poller.Reading) bool) poller.Reading {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if r := p.Latest(); ok(r) {
+			return r
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for reading, last was %+v", p.Latest())
+	return poller.Reading{}
+}
+
+func TestNewRejectsNonPositiveInterval(t *testing.T) {
+	c := &counter{}
+	for _, d := range []time.Duration{0, -time.Second} {
+		_, err := poller.New(poller.FromFloat(c.read), d)
+		if !errors.Is(err, poller.ErrInterval) {
+			t.Errorf("New with interval %v: expected ErrInterval, got %v", d, err)
+		}
+	}
+}
+
+func TestPollerCachesLatest(t *testing.T) {
+	c := &counter{}
+	p, err := poller.New(poller.FromFloat(c.read), time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := p.Latest(); r.Value != nil || !r.Time.IsZero() {
+		t.Errorf("expected no reading before Start, got %+v", r)
+	}
+	p.Start()
+	defer p.Close()
+	waitFor(t, p, func(r poller.Reading) bool { return r.Value != nil && r.Value.(float64) >= 2 })
+}
+
+func TestPollerKeepsValueOnError(t *testing.T) {
+	c := &counter{}
+	p, err := poller.New(poller.FromFloat(c.read), time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Start()
+	defer p.Close()
+	waitFor(t, p, func(r poller.Reading) bool { return r.Value != nil })
+	c.fail(errors.New("bus timeout"))
+	r := waitFor(t, p, func(r poller.Reading) bool { return r.Err != "" })
+	if r.Value == nil || r.Time.IsZero() {
+		t.Errorf("expected the last good value to be kept, got %+v", r)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	c := &counter{}
+	c.fail(errors.New("not connected"))
+	p, err := poller.New(poller.FromFloat(c.read), time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(p.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("before any read: expected 503, got %d", resp.StatusCode)
+	}
+
+	c.fail(nil)
+	p.Start()
+	defer p.Close()
+	waitFor(t, p, func(r poller.Reading) bool { return r.Value != nil })
+	resp, err = http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("after a read: expected 200, got %d", resp.StatusCode)
+	}
+	var r poller.Reading
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Value == nil || r.Time.IsZero() {
+		t.Errorf("expected a value and time, got %+v", r)
+	}
+}
@@ -21,12 +21,24 @@ const (
 
 // SCPI is a type for encapsulating SCPI communication
 type SCPI struct {
-	Pool *comm.Pool
+	Pool comm.Leaser
 
 	// Handshaking indicates if the communication shall use handshaking,
 	// where an error query is sent with every message
 	// to ensure the device accepted the input
 	Handshaking bool
+
+	// Terminators frame each message to and from the device.  If empty,
+	// messages are terminated with a newline in both directions
+	Terminators comm.Terminators
+}
+
+// terminators returns the terminators in use
+func (s *SCPI) terminators() comm.Terminators {
+	if len(s.Terminators.Tx) == 0 && len(s.Terminators.Rx) == 0 {
+		return comm.LF
+	}
+	return s.Terminators
 }
 
 // Write sends a command to the device.  if f.Handshaking == true,
@@ -39,7 +51,7 @@ func (s *SCPI) Write(cmds ...string) error {
 	}
 	defer func() { s.Pool.ReturnWithError(conn, err) }()
 	var wrap io.ReadWriter
-	wrap = comm.NewTerminator(conn, '\n', '\n')
+	wrap = s.terminators().Wrap(conn)
 	wrap, err = comm.NewTimeout(wrap, timeout)
 	if err != nil {
 		return err
@@ -78,7 +90,7 @@ func (s *SCPI) WriteRead(cmds ...string) ([]byte, error) {
 	}
 	defer func() { s.Pool.ReturnWithError(conn, err) }()
 	var wrap io.ReadWriter
-	wrap = comm.NewTerminator(conn, '\n', '\n')
+	wrap = s.terminators().Wrap(conn)
 	wrap, err = comm.NewTimeout(wrap, timeout)
 	if err != nil {
 		return resp, err
@@ -0,0 +1,220 @@
+// Package jobs tracks the progress of long-running operations, such as camera
+// bursts, laser ramps, and stage scans, so that clients can follow any of them
+// the same way, at /jobs and /jobs/{id}
+package jobs
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// State is the state of a job
+type State string
+
+const (
+	// Running is the state of a job which has not finished
+	Running State = "running"
+
+	// Done is the state of a job which finished without error
+	Done State = "done"
+
+	// Failed is the state of a job which finished with an error
+	Failed State = "failed"
+)
+
+// DefaultKeep is the number of finished jobs a Registry remembers by default
+const DefaultKeep = 100
+
+// Job is a snapshot of a long-running operation
+type Job struct {
+	// ID uniquely identifies the job within its Registry
+	ID string `json:"id"`
+
+	// Kind is what the job is doing, e.g. "burst"
+	Kind string `json:"kind"`
+
+	// State is the state of the job
+	State State `json:"state"`
+
+	// Percent is how complete the job is, 0 to 100
+	Percent float64 `json:"percent"`
+
+	// Error is the error which ended the job, if it failed
+	Error string `json:"error,omitempty"`
+
+	// Started is when the job began
+	Started time.Time `json:"started"`
+
+	// Finished is when the job ended, zero if it is running
+	Finished time.Time `json:"finished"`
+}
+
+// Registry holds jobs by ID.  Finished jobs are forgotten, oldest first, once
+// there are more than Keep of them.  Registries must be created with
+// NewRegistry.
+type Registry struct {
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	order []string
+	next  int
+
+	// Keep is the number of finished jobs to remember
+	Keep int
+}
+
+// NewRegistry returns a new, empty Registry
+func NewRegistry() *Registry {
+	return &Registry{jobs: map[string]*Job{}, Keep: DefaultKeep}
+}
+
+// Default is the Registry used by the HTTP handlers in this repository.
+// Servers expose it by binding Default.RT() at their root.
+var Default = NewRegistry()
+
+// Handle is used by the code doing a job to report on it.  The methods of a
+// nil Handle do nothing, so optional tracking needs no special casing.
+type Handle struct {
+	r  *Registry
+	id string
+}
+
+// Start registers a new running job of the given kind
+func (r *Registry) Start(kind string) *Handle {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next++
+	id := strconv.Itoa(r.next)
+	r.jobs[id] = &Job{ID: id, Kind: kind, State: Running, Started: time.Now()}
+	r.order = append(r.order, id)
+	return &Handle{r: r, id: id}
+}
+
+// ID returns the ID of the job
+func (h *Handle) ID() string {
+	if h == nil {
+		return ""
+	}
+	return h.id
+}
+
+// Progress updates the percent complete of the job
+func (h *Handle) Progress(percent float64) {
+	if h == nil {
+		return
+	}
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+	if j, ok := h.r.jobs[h.id]; ok && j.State == Running {
+		j.Percent = percent
+	}
+}
+
+// Finish ends the job, successfully if err is nil.  Calls after the first
+// have no effect
+func (h *Handle) Finish(err error) {
+	if h == nil {
+		return
+	}
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+	j, ok := h.r.jobs[h.id]
+	if !ok || j.State != Running {
+		return
+	}
+	j.Finished = time.Now()
+	if err != nil {
+		j.State = Failed
+		j.Error = err.Error()
+	} else {
+		j.State = Done
+		j.Percent = 100
+	}
+	h.r.prune()
+}
+
+// prune forgets the oldest finished jobs beyond Keep.  The caller must hold
+// the lock
+func (r *Registry) prune() {
+	finished := 0
+	for _, id := range r.order {
+		if r.jobs[id].State != Running {
+			finished++
+		}
+	}
+	if finished <= r.Keep {
+		return
+	}
+	drop := finished - r.Keep
+	kept := r.order[:0]
+	for _, id := range r.order {
+		if drop > 0 && r.jobs[id].State != Running {
+			delete(r.jobs, id)
+			drop--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	r.order = kept
+}
+
+// List returns all of the jobs, oldest first
+func (r *Registry) List() []Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Job, len(r.order))
+	for i, id := range r.order {
+		out[i] = *r.jobs[id]
+	}
+	return out
+}
+
+// Get returns the job with the given ID
+func (r *Registry) Get(id string) (Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j, ok := r.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// GetJobs responds with all of the jobs as a JSON array
+func (r *Registry) GetJobs(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(w).Encode(r.List())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GetJob responds with one job as JSON, or 404 if there is no such job
+func (r *Registry) GetJob(w http.ResponseWriter, req *http.Request) {
+	id := chi.URLParam(req, "id")
+	j, ok := r.Get(id)
+	if !ok {
+		http.Error(w, "no job with ID "+id, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err := json.NewEncoder(w).Encode(j)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// RT returns the route table of the registry, satisfying generichttp.HTTPer
+func (r *Registry) RT() generichttp.RouteTable {
+	return generichttp.RouteTable{
+		generichttp.MethodPath{Method: http.MethodGet, Path: "/jobs"}:      r.GetJobs,
+		generichttp.MethodPath{Method: http.MethodGet, Path: "/jobs/{id}"}: r.GetJob,
+	}
+}
@@ -0,0 +1,157 @@
+package jobs_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/nasa-jpl/golaborate/server/jobs"
+)
+
+func TestHandleLifecycle(t *testing.T) {
+	cases := []struct {
+		name    string
+		run     func(h *jobs.Handle)
+		state   jobs.State
+		percent float64
+		err     string
+	}{
+		{"running", func(h *jobs.Handle) { h.Progress(40) }, jobs.Running, 40, ""},
+		{"done", func(h *jobs.Handle) { h.Progress(40); h.Finish(nil) }, jobs.Done, 100, ""},
+		{"failed", func(h *jobs.Handle) { h.Progress(40); h.Finish(errors.New("boom")) }, jobs.Failed, 40, "boom"},
+		{"progress after finish", func(h *jobs.Handle) { h.Finish(nil); h.Progress(10) }, jobs.Done, 100, ""},
+		{"second finish ignored", func(h *jobs.Handle) { h.Finish(errors.New("boom")); h.Finish(nil) }, jobs.Failed, 0, "boom"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := jobs.NewRegistry()
+			h := r.Start("test")
+			c.run(h)
+			j, ok := r.Get(h.ID())
+			if !ok {
+				t.Fatalf("job %s not found", h.ID())
+			}
+			if j.Kind != "test" || j.State != c.state || j.Percent != c.percent || j.Error != c.err {
+				t.Errorf("expected test %s %v%% %q, got %+v", c.state, c.percent, c.err, j)
+			}
+			if j.Started.IsZero() || (c.state == jobs.Running) != j.Finished.IsZero() {
+				t.Errorf("bad start or finish time, got %+v", j)
+			}
+		})
+	}
+}
+
+func TestNilHandle(t *testing.T) {
+	var h *jobs.Handle
+	h.Progress(50)
+	h.Finish(nil)
+	if h.ID() != "" {
+		t.Errorf("expected an empty ID, got %q", h.ID())
+	}
+}
+
+func TestPrune(t *testing.T) {
+	cases := []struct {
+		name     string
+		keep     int
+		finished int
+		running  int
+		want     int
+	}{
+		{"under keep", 3, 2, 1, 3},
+		{"at keep", 3, 3, 0, 3},
+		{"over keep", 2, 5, 0, 2},
+		{"running kept", 1, 3, 2, 3},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := jobs.NewRegistry()
+			r.Keep = c.keep
+			var running []string
+			for i := 0; i < c.running; i++ {
+				running = append(running, r.Start("running").ID())
+			}
+			var last string
+			for i := 0; i < c.finished; i++ {
+				h := r.Start("finished")
+				h.Finish(nil)
+				last = h.ID()
+			}
+			list := r.List()
+			if len(list) != c.want {
+				t.Fatalf("expected %d jobs, got %d: %+v", c.want, len(list), list)
+			}
+			for _, id := range running {
+				if _, ok := r.Get(id); !ok {
+					t.Errorf("running job %s was pruned", id)
+				}
+			}
+			if _, ok := r.Get(last); c.finished > 0 && !ok {
+				t.Errorf("newest finished job %s was pruned", last)
+			}
+			for i := 1; i < len(list); i++ {
+				prev, _ := strconv.Atoi(list[i-1].ID)
+				cur, _ := strconv.Atoi(list[i].ID)
+				if prev >= cur {
+					t.Errorf("jobs not listed oldest first: %+v", list)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	reg := jobs.NewRegistry()
+	a := reg.Start("burst")
+	b := reg.Start("scan")
+	b.Finish(errors.New("stage fault"))
+	r := chi.NewRouter()
+	reg.RT().Bind(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	cases := []struct {
+		path string
+		code int
+		kind string
+	}{
+		{"/jobs/" + a.ID(), http.StatusOK, "burst"},
+		{"/jobs/" + b.ID(), http.StatusOK, "scan"},
+		{"/jobs/nope", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		resp, err := http.Get(srv.URL + c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != c.code {
+			t.Errorf("GET %s: expected %d, got %d", c.path, c.code, resp.StatusCode)
+		}
+		if c.code == http.StatusOK {
+			var j jobs.Job
+			if err := json.NewDecoder(resp.Body).Decode(&j); err != nil {
+				t.Errorf("GET %s: %v", c.path, err)
+			} else if j.Kind != c.kind {
+				t.Errorf("GET %s: expected kind %s, got %+v", c.path, c.kind, j)
+			}
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(srv.URL + "/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var list []jobs.Job
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != a.ID() || list[1].ID != b.ID() || list[1].State != jobs.Failed {
+		t.Errorf("expected [burst running, scan failed], got %+v", list)
+	}
+}
@@ -0,0 +1,188 @@
+// Package timeout provides an HTTP middleware which bounds the time a handler
+// may take, returning 504 (gateway timeout) when it is exceeded.
+//
+// Instruments differ by orders of magnitude in how long they take to respond,
+// so timeouts are configured per device and per route rather than once for
+// the whole server.  When a request times out its context is canceled and the
+// client is answered immediately, but the device call itself is only
+// interrupted if it honors the context; the handler's late response is
+// discarded.
+package timeout
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/generichttp"
+)
+
+// Timeouts holds the timeouts for the routes of one device
+type Timeouts struct {
+	// Default applies to routes without an entry in Routes.  Zero is no limit
+	Default time.Duration
+
+	// Routes overrides Default for particular routes.  Zero is no limit.
+	// Routes which stream their response are only timed until they first
+	// flush, see Wrap
+	Routes map[generichttp.MethodPath]time.Duration
+}
+
+// For returns the timeout for a route
+func (t Timeouts) For(mp generichttp.MethodPath) time.Duration {
+	if d, ok := t.Routes[mp]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// Inject wraps every handler in the route table of other with its timeout
+func Inject(other generichttp.HTTPer, t Timeouts) {
+	rt := other.RT()
+	for mp, h := range rt {
+		rt[mp] = Wrap(t.For(mp), h)
+	}
+}
+
+// ParseMethodPath converts a string such as "GET /position" to a MethodPath
+func ParseMethodPath(s string) (generichttp.MethodPath, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return generichttp.MethodPath{}, fmt.Errorf("route %q must be formatted as \"METHOD /path\"", s)
+	}
+	return generichttp.MethodPath{Method: strings.ToUpper(fields[0]), Path: fields[1]}, nil
+}
+
+// Wrap returns a handler which answers 504 if h does not finish within d.  If
+// d is zero, h is returned unchanged.
+//
+// The response is buffered so that it can be replaced by the 504.  A handler
+// which streams its response by calling Flush gives that up: the buffered
+// response is sent when it first flushes, and from then on it is not timed.
+func Wrap(d time.Duration, h http.HandlerFunc) http.HandlerFunc {
+	if d <= 0 {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		tw := &bufferedWriter{w: w, hdr: http.Header{}, timer: timer}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			h(tw, r.WithContext(ctx))
+			close(done)
+		}()
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.send()
+		case <-timer.C:
+			tw.mu.Lock()
+			if tw.streaming {
+				// the handler flushed as the timer fired
+				tw.mu.Unlock()
+				select {
+				case p := <-panicked:
+					panic(p)
+				case <-done:
+				}
+				return
+			}
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			cancel()
+			http.Error(w, fmt.Sprintf("%s %s did not finish within %v", r.Method, r.URL.Path, d), http.StatusGatewayTimeout)
+		}
+	}
+}
+
+// bufferedWriter holds a handler's response until it is known whether the
+// handler finished in time, or the handler flushes
+type bufferedWriter struct {
+	mu       sync.Mutex
+	w        http.ResponseWriter
+	timer    *time.Timer
+	hdr      http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+
+	// streaming is true once the handler has flushed, after which writes go
+	// directly to w
+	streaming bool
+}
+
+// send writes the buffered response to w.  The caller must hold the lock.
+func (b *bufferedWriter) send() {
+	if b.streaming {
+		return
+	}
+	for k, v := range b.hdr {
+		b.w.Header()[k] = v
+	}
+	if b.code == 0 {
+		b.code = http.StatusOK
+	}
+	b.w.WriteHeader(b.code)
+	b.w.Write(b.buf.Bytes())
+	b.buf.Reset()
+	b.streaming = true
+}
+
+func (b *bufferedWriter) Header() http.Header {
+	return b.hdr
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if b.streaming {
+		return b.w.Write(p)
+	}
+	if b.code == 0 {
+		b.code = http.StatusOK
+	}
+	return b.buf.Write(p)
+}
+
+func (b *bufferedWriter) WriteHeader(code int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timedOut || b.code != 0 {
+		return
+	}
+	b.code = code
+}
+
+// Flush sends the response so far and stops the timeout, so that a handler
+// which streams is not cut off
+func (b *bufferedWriter) Flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timedOut {
+		return
+	}
+	b.timer.Stop()
+	b.send()
+	if f, ok := b.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,85 @@
+package timeout_test
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nasa-jpl/golaborate/server/middleware/timeout"
+)
+
+func TestWrapPassesFastResponse(t *testing.T) {
+	h := timeout.Wrap(time.Second, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "yes")
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprint(w, "hello")
+	})
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusTeapot || rec.Body.String() != "hello" || rec.Header().Get("X-Test") != "yes" {
+		t.Errorf("expected 418 hello with X-Test, got %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+}
+
+func TestWrapTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	h := timeout.Wrap(10*time.Millisecond, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+		fmt.Fprint(w, "late")
+	})
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d", rec.Code)
+	}
+}
+
+func TestWrapStreamsFlushedResponse(t *testing.T) {
+	const lines = 5
+	h := timeout.Wrap(20*time.Millisecond, func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		for i := 0; i < lines; i++ {
+			fmt.Fprintf(w, "%d\n", i)
+			f.Flush()
+			// the stream as a whole takes longer than the timeout
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/csv" {
+		t.Fatalf("expected 200 text/csv, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	// the first line must arrive before the handler is done
+	br := bufio.NewReader(resp.Body)
+	first, err := br.ReadString('\n')
+	if err != nil || first != "0\n" {
+		t.Fatalf("expected the first line to be streamed, got %q, %v", first, err)
+	}
+	rest, err := ioutil.ReadAll(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := first + string(rest); got != "0\n1\n2\n3\n4\n" {
+		t.Errorf("expected every line, got %q", got)
+	}
+}
@@ -0,0 +1,40 @@
+// Package server contains utilities shared by the servers in cmd
+package server
+
+import (
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// CloserFunc adapts an ordinary function to an io.Closer
+type CloserFunc func() error
+
+// Close calls f
+func (f CloserFunc) Close() error {
+	return f()
+}
+
+// GracefulShutdown installs a handler for SIGINT, SIGTERM, and SIGABRT which
+// closes each of closers, in order, and then exits the process.  An error
+// from one closer is logged and does not stop the rest from being closed.
+//
+// It should be called once the devices are set up and before the server
+// begins listening
+func GracefulShutdown(closers ...io.Closer) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM, syscall.SIGABRT)
+	go func() {
+		sig := <-ch
+		log.Printf("received %v, shutting down\n", sig)
+		for _, c := range closers {
+			err := c.Close()
+			if err != nil {
+				log.Println("error during shutdown:", err)
+			}
+		}
+		os.Exit(0)
+	}()
+}
@@ -142,15 +142,14 @@ func makeSerConf(addr string) *serial.Config {
 
 // T257P talks to the chiller of the same model name
 type T257P struct {
-	pool *comm.Pool
+	pool comm.Leaser
 	// TODO: a semaphore to pace commands approp
 }
 
 // New257P creates a new T257P instance
 func NewT257P(addr string) *T257P {
 	maker := comm.SerialConnMaker(makeSerConf(addr))
-	pool := comm.NewPool(1, time.Hour, maker)
-	return &T257P{pool: pool}
+	return &T257P{pool: comm.NewSerialConn(maker, comm.DefaultTimeout)}
 }
 
 func (t *T257P) WriteRead(msg []byte) ([]byte, error) {
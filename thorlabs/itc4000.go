@@ -5,8 +5,11 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/nasa-jpl/golaborate/generichttp/laser"
 	"github.com/nasa-jpl/golaborate/usbtmc"
+	"github.com/nasa-jpl/golaborate/util"
 )
 
 /* unlike the remotedevice classes, this package assumes the connection to the
@@ -18,6 +21,9 @@ const (
 
 	// LDC4001PID is the LDC4001 product ID
 	LDC4001PID = 0x804a
+
+	// RampInterval is the time between steps of a current ramp
+	RampInterval = 50 * time.Millisecond
 )
 
 // LDCError is a formattable error code from the XPS
@@ -38,6 +44,8 @@ type ITC4000 struct {
 	sync.Mutex
 
 	dev usbtmc.USBDevice
+
+	ramp util.Ramper
 }
 
 // NewITC4000 creates a new ITC4000 instance absorbing the first one seen on the USB[us]
@@ -47,6 +55,13 @@ func NewITC4000() (*ITC4000, error) {
 }
 
 var (
+	// ModulationSources maps modulation source names to SCPI mnemonics
+	ModulationSources = map[string]string{
+		"internal": "INTERNAL",
+		"external": "EXTERNAL",
+		"both":     "INTERNAL,EXTERNAL",
+	}
+
 	// ITC4000Errors maps ITC4000 error codes to strings
 	ITC4000Errors = map[int]string{
 		-100: "COMMAND ERROR",
@@ -139,6 +154,8 @@ func (ldc *ITC4000) writeReadBus(cmd string) (string, error) {
 }
 
 func (ldc *ITC4000) writeOnlyBus(cmd string) error {
+	ldc.Lock()
+	defer ldc.Unlock()
 	return ldc.dev.Write(append([]byte(cmd), '\n'))
 }
 
@@ -147,16 +164,20 @@ func (ldc *ITC4000) EmissionOn() error {
 	return ldc.writeOnlyBus("OUTPUT ON")
 }
 
-// EmissionOff turns the LD off
+// EmissionOff turns the LD off, aborting any current ramp in progress
 func (ldc *ITC4000) EmissionOff() error {
+	ldc.ramp.Stop()
 	return ldc.writeOnlyBus("OUTPUT OFF")
 }
 
-// SetEmission turns emission on or off
+// SetEmission turns emission on or off.  Turning emission off aborts any
+// current ramp in progress.
 func (ldc *ITC4000) SetEmission(on bool) error {
 	predicate := "OFF"
 	if on {
 		predicate = "ON"
+	} else {
+		ldc.ramp.Stop()
 	}
 	return ldc.writeOnlyBus("OUTPUT " + predicate)
 }
@@ -206,6 +227,140 @@ func (ldc *ITC4000) GetCurrent() (float64, error) {
 	return f * 1e3, err
 }
 
+// GetCurrentLimit gets the output current limit in mA
+func (ldc *ITC4000) GetCurrentLimit() (float64, error) {
+	resp, err := ldc.writeReadBus("SOURCE:CURRENT:LIMIT?")
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(resp, 64)
+	return f * 1e3, err
+}
+
+// RampCurrent ramps the output current from its present value to target (mA)
+// at ratePerSec (mA/s).  The target is checked against the current limit of
+// the controller before the ramp begins.  The ramp runs in the background;
+// a new ramp, StopRamp, or turning emission off aborts it.
+func (ldc *ITC4000) RampCurrent(target, ratePerSec float64) error {
+	if target < 0 {
+		return fmt.Errorf("target current %f mA is negative", target)
+	}
+	limit, err := ldc.GetCurrentLimit()
+	if err != nil {
+		return err
+	}
+	if target > limit {
+		return fmt.Errorf("target current %f mA exceeds the limit of %f mA", target, limit)
+	}
+	start, err := ldc.GetCurrent()
+	if err != nil {
+		return err
+	}
+	return ldc.ramp.Ramp(start, target, ratePerSec, RampInterval, ldc.SetCurrent)
+}
+
+// StopRamp aborts the current ramp in progress, if any
+func (ldc *ITC4000) StopRamp() error {
+	ldc.ramp.Stop()
+	return nil
+}
+
+// RampStatus returns the status of the most recent ramp
+func (ldc *ITC4000) RampStatus() util.RampStatus {
+	return ldc.ramp.Status()
+}
+
+// SetModulation enables or disables modulation of the laser diode output and
+// selects its source, one of the keys of ModulationSources
+func (ldc *ITC4000) SetModulation(enabled bool, source string) error {
+	src, ok := ModulationSources[strings.ToLower(source)]
+	if !ok {
+		return fmt.Errorf("modulation source %s not understood, must be internal, external, or both", source)
+	}
+	err := ldc.writeOnlyBus("SOURCE:AM:SOURCE " + src)
+	if err != nil {
+		return err
+	}
+	predicate := "OFF"
+	if enabled {
+		predicate = "ON"
+	}
+	return ldc.writeOnlyBus("SOURCE:AM:STATE " + predicate)
+}
+
+// GetModulation queries if modulation is enabled and its source
+func (ldc *ITC4000) GetModulation() (laser.Modulation, error) {
+	var ret laser.Modulation
+	resp, err := ldc.writeReadBus("SOURCE:AM:STATE?")
+	if err != nil {
+		return ret, err
+	}
+	ret.Enabled = resp == "1"
+	resp, err = ldc.writeReadBus("SOURCE:AM:SOURCE?")
+	if err != nil {
+		return ret, err
+	}
+	switch resp {
+	case "INT":
+		ret.Source = "internal"
+	case "EXT":
+		ret.Source = "external"
+	case "INT,EXT", "EXT,INT":
+		ret.Source = "both"
+	default:
+		ret.Source = resp
+	}
+	return ret, nil
+}
+
+// GetTECTemperature gets the temperature measured by the TEC sensor in Celsius
+func (ldc *ITC4000) GetTECTemperature() (float64, error) {
+	resp, err := ldc.writeReadBus("MEASURE:TEMPERATURE?")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(resp, 64)
+}
+
+// GetPhotodiodeCurrent gets the current measured by the monitor photodiode in mA
+func (ldc *ITC4000) GetPhotodiodeCurrent() (float64, error) {
+	resp, err := ldc.writeReadBus("MEASURE:CURRENT2?")
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(resp, 64)
+	return f * 1e3, err
+}
+
+// identity queries *IDN? and returns the field at idx of the reply, which is
+// manufacturer,model,serial,firmware
+func (ldc *ITC4000) identity(idx int) (string, error) {
+	resp, err := ldc.writeReadBus("*IDN?")
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Split(resp, ",")
+	if len(fields) != 4 {
+		return "", fmt.Errorf("malformed *IDN? response %q", resp)
+	}
+	return strings.TrimSpace(fields[idx]), nil
+}
+
+// GetModel returns the model of the controller
+func (ldc *ITC4000) GetModel() (string, error) {
+	return ldc.identity(1)
+}
+
+// GetSerialNumber returns the serial number of the controller
+func (ldc *ITC4000) GetSerialNumber() (string, error) {
+	return ldc.identity(2)
+}
+
+// GetFirmwareVersion returns the firmware version of the controller
+func (ldc *ITC4000) GetFirmwareVersion() (string, error) {
+	return ldc.identity(3)
+}
+
 // Raw sends a command and retrieves the reply if there is a question mark in the command, else returns "", err
 func (ldc *ITC4000) Raw(cmd string) (string, error) {
 	if !strings.Contains(cmd, "?") {
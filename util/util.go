@@ -2,10 +2,12 @@
 package util
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -148,20 +150,99 @@ func (l *Limiter) Check(input float64) bool {
 	return true
 }
 
-// MergeErrors converts many errors to a single one, newline separated
+// MergeErrors converts many errors to a single one, newline separated.  nil
+// errors are skipped, and nil is returned if there are no others.  The
+// result is a MultiError, so the individual errors may still be inspected
 func MergeErrors(errs []error) error {
-	var strs []string
-	for idx := 0; idx < len(errs); idx++ {
-		err := errs[idx]
+	return NewMultiError(errs)
+}
+
+// KeyedError associates an error with the key, such as a feature name, of the
+// operation which generated it
+type KeyedError struct {
+	Key string
+	Err error
+}
+
+func (e KeyedError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e KeyedError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError holds several errors, such as one for each setting which failed
+// to be applied, as a single error.  errors.Is and errors.As match it if they
+// match any of the errors it holds
+type MultiError struct {
+	Errors []error
+}
+
+// NewMultiError returns a MultiError holding the errors in errs which are not
+// nil, or nil if they all are
+func NewMultiError(errs []error) error {
+	var kept []error
+	for _, err := range errs {
 		if err != nil {
-			strs = append(strs, err.Error())
+			kept = append(kept, err)
 		}
 	}
-	err := fmt.Errorf(strings.Join(strs, "\n"))
-	if err.Error() == "" {
+	if len(kept) == 0 {
+		return nil
+	}
+	return MultiError{Errors: kept}
+}
+
+// Error joins the messages of the errors with newlines
+func (m MultiError) Error() string {
+	strs := make([]string, len(m.Errors))
+	for i, err := range m.Errors {
+		strs[i] = err.Error()
+	}
+	return strings.Join(strs, "\n")
+}
+
+// Unwrap returns the first error.  Is and As consider all of them
+func (m MultiError) Unwrap() error {
+	if len(m.Errors) == 0 {
 		return nil
 	}
-	return err
+	return m.Errors[0]
+}
+
+// Is reports whether any of the errors matches target
+func (m MultiError) Is(target error) bool {
+	for _, err := range m.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors which matches target, and if one does,
+// sets target to it and returns true
+func (m MultiError) As(target interface{}) bool {
+	for _, err := range m.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Keys returns the keys of the errors which are KeyedErrors, in order
+func (m MultiError) Keys() []string {
+	var keys []string
+	for _, err := range m.Errors {
+		var ke KeyedError
+		if errors.As(err, &ke) {
+			keys = append(keys, ke.Key)
+		}
+	}
+	return keys
 }
 
 // ClosestIndex returns the index of the closest element in the slice to the given value
@@ -194,3 +275,151 @@ func UintToFloat(vals []uint16, zero uint16, max uint16) []float64 {
 	}
 	return out
 }
+
+// ErrRampRate is generated when a ramp is requested with a rate that is not
+// strictly positive
+var ErrRampRate = errors.New("ramp rate must be greater than zero")
+
+// Ramper steps a setter from one value to another at a fixed rate in the
+// background, so devices see many small changes instead of one large one.
+// Starting a new ramp aborts any ramp already in progress.
+// The zero value is ready to use.
+type Ramper struct {
+	// ctl serializes Ramp and Stop, so that stopping one ramp and starting
+	// the next is a single step.  mu guards the remaining fields; it is taken
+	// by the ramp goroutine, so it cannot be held while waiting for it to exit
+	ctl sync.Mutex
+	mu  sync.Mutex
+
+	stop    chan struct{}
+	done    chan struct{}
+	err     error
+	active  bool
+	stopped bool
+	start   float64
+	target  float64
+	value   float64
+}
+
+// RampStatus describes the most recent ramp of a Ramper
+type RampStatus struct {
+	// Active is true while the ramp is in progress
+	Active bool
+
+	// Stopped is true if the ramp was aborted before reaching its target
+	Stopped bool
+
+	// Fraction is how far the ramp has gone from its start to its target,
+	// 0 to 1
+	Fraction float64
+
+	// Err is the error which ended the ramp, if any
+	Err error
+}
+
+// Ramp begins a ramp from start to target at ratePerSec (units per second),
+// calling set every interval with the next value.  The final call to set is
+// always made with exactly target.  Ramp returns immediately; if set returns
+// an error the ramp ends and the error is available from Err.
+func (r *Ramper) Ramp(start, target, ratePerSec float64, interval time.Duration, set func(float64) error) error {
+	if ratePerSec <= 0 {
+		return ErrRampRate
+	}
+	r.ctl.Lock()
+	defer r.ctl.Unlock()
+	r.halt()
+	step := ratePerSec * interval.Seconds()
+	if target < start {
+		step = -step
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	r.mu.Lock()
+	r.stop = stop
+	r.done = done
+	r.err = nil
+	r.active = true
+	r.stopped = false
+	r.start, r.target, r.value = start, target, start
+	r.mu.Unlock()
+	go func() {
+		var err error
+		defer func() {
+			r.mu.Lock()
+			r.err = err
+			r.active = false
+			r.mu.Unlock()
+			close(done)
+		}()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		v := start
+		for v != target {
+			select {
+			case <-stop:
+				r.mu.Lock()
+				r.stopped = true
+				r.mu.Unlock()
+				return
+			case <-ticker.C:
+			}
+			v += step
+			if (step > 0 && v > target) || (step < 0 && v < target) {
+				v = target
+			}
+			err = set(v)
+			if err != nil {
+				return
+			}
+			r.mu.Lock()
+			r.value = v
+			r.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// Stop aborts the ramp in progress, if any, and waits for it to exit
+func (r *Ramper) Stop() {
+	r.ctl.Lock()
+	defer r.ctl.Unlock()
+	r.halt()
+}
+
+// halt is Stop without locking ctl.  The caller must hold it
+func (r *Ramper) halt() {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop = nil
+	r.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Active returns true if a ramp is in progress
+func (r *Ramper) Active() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active
+}
+
+// Err returns the error which ended the most recent ramp, if any
+func (r *Ramper) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Status returns the status of the most recent ramp
+func (r *Ramper) Status() RampStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	frac := 1.
+	if r.target != r.start {
+		frac = (r.value - r.start) / (r.target - r.start)
+	}
+	return RampStatus{Active: r.active, Stopped: r.stopped, Fraction: frac, Err: r.err}
+}
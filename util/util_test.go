@@ -1,7 +1,9 @@
 package util_test
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -102,3 +104,70 @@ func TestSecsToDuration(t *testing.T) {
 		t.Errorf("expected SecsToDuration to round trip, output %v != expected %v", out, dur)
 	}
 }
+
+func TestMultiErrorInspection(t *testing.T) {
+	sentinel := errors.New("sentinel")
+	err := util.MergeErrors([]error{
+		nil,
+		util.KeyedError{Key: "FanSpeed", Err: errors.New("bad value")},
+		util.KeyedError{Key: "AOIWidth", Err: fmt.Errorf("wrapped: %w", sentinel)},
+	})
+	if !errors.Is(err, sentinel) {
+		t.Errorf("errors.Is did not find the sentinel in %v", err)
+	}
+	var ke util.KeyedError
+	if !errors.As(err, &ke) || ke.Key != "FanSpeed" {
+		t.Errorf("errors.As found %+v, expected the FanSpeed error", ke)
+	}
+	var me util.MultiError
+	if !errors.As(err, &me) {
+		t.Fatalf("%T is not a MultiError", err)
+	}
+	keys := me.Keys()
+	if len(keys) != 2 || keys[0] != "FanSpeed" || keys[1] != "AOIWidth" {
+		t.Errorf("Keys() = %v, expected [FanSpeed AOIWidth]", keys)
+	}
+	if err.Error() != "FanSpeed: bad value\nAOIWidth: wrapped: sentinel" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+	if util.MergeErrors([]error{nil, nil}) != nil {
+		t.Error("MergeErrors of only nil errors was not nil")
+	}
+}
+
+func TestRamperConcurrentRampsLeaveOneRunning(t *testing.T) {
+	var (
+		r     util.Ramper
+		mu    sync.Mutex
+		calls int
+	)
+	set := func(float64) error {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Ramp(0, 1e6, 1, time.Millisecond, set)
+		}()
+	}
+	wg.Wait()
+	r.Stop()
+	if r.Active() {
+		t.Fatal("ramp still active after Stop")
+	}
+	mu.Lock()
+	before := calls
+	mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	after := calls
+	mu.Unlock()
+	if after != before {
+		t.Errorf("set was called %d times after Stop, a ramp was left running", after-before)
+	}
+}